@@ -0,0 +1,437 @@
+package caca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"sort"
+	"time"
+)
+
+// defaultFrameDelay is the delay used for frames without an explicit delay.
+const defaultFrameDelay = 100 * time.Millisecond
+
+// AnimationOptions configures ExportGIF() and ExportAPNG().
+type AnimationOptions struct {
+	// Font is used to render the frames. If it is not set, the first font
+	// returned by Fonts() is used.
+	Font Font
+
+	// Delays holds the display duration of each frame. Frames without an
+	// entry use the duration set with Canvas.SetFrameDuration(), or
+	// DefaultDelay if they have none.
+	Delays []time.Duration
+
+	// DefaultDelay is the display duration of frames without a delay or
+	// duration. Zero means 100ms.
+	DefaultDelay time.Duration
+
+	// Loops is the number of times the animation is played. Zero loops
+	// forever.
+	Loops int
+}
+
+// delay returns the display duration of the frame, given the duration stored
+// in the frame itself.
+func (o *AnimationOptions) delay(frame int, stored time.Duration) time.Duration {
+	if frame < len(o.Delays) {
+		return o.Delays[frame]
+	}
+
+	if stored > 0 {
+		return stored
+	}
+
+	if o.DefaultDelay > 0 {
+		return o.DefaultDelay
+	}
+
+	return defaultFrameDelay
+}
+
+// animFrame is a rendered canvas frame. bounds is the area that changed since
+// the previous frame, or the whole image for the first frame.
+type animFrame struct {
+	img    *image.Paletted
+	bounds image.Rectangle
+	delay  time.Duration
+}
+
+// renderFrames renders every frame of the canvas into paletted images sharing
+// a single palette. The canvas' current frame is restored afterwards.
+func renderFrames(cv Canvas, opts *AnimationOptions) ([]animFrame, color.Palette, error) {
+	if opts == nil {
+		opts = &AnimationOptions{}
+	}
+
+	font := opts.Font
+	if font.h == nil {
+		names := Fonts()
+		if len(names) == 0 {
+			return nil, nil, &Error{Op: "renderFrames", Err: ErrNotSupported}
+		}
+
+		var err error
+		if font, err = LoadFont(names[0]); err != nil {
+			return nil, nil, err
+		}
+
+		defer font.Free()
+	}
+
+	current := currentFrame(cv)
+	defer func() { _ = cv.SetFrame(current) }()
+
+	count := cv.GetFrameCount()
+	images := make([]*image.RGBA, 0, count)
+	durations := make([]time.Duration, 0, count)
+
+	for i := 0; i < count; i++ {
+		if err := cv.SetFrame(i); err != nil {
+			return nil, nil, err
+		}
+
+		img, err := RenderCanvas(cv, font)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		images = append(images, img)
+		durations = append(durations, cv.GetFrameDuration())
+	}
+
+	pal := buildPalette(images)
+	frames := make([]animFrame, 0, count)
+
+	for i, img := range images {
+		f := animFrame{img: toPaletted(img, pal), bounds: img.Bounds(), delay: opts.delay(i, durations[i])}
+		if i > 0 {
+			f.bounds = changedBounds(frames[i-1].img, f.img)
+		}
+
+		frames = append(frames, f)
+	}
+
+	return frames, pal, nil
+}
+
+// currentFrame returns the index of the canvas' active frame. libcaca has no
+// function for this, so the index is tracked by the frame functions of Canvas.
+func currentFrame(cv Canvas) int {
+	cv.mustLive()
+
+	return cv.h.frame
+}
+
+// buildPalette returns a palette holding the colours of all images. If there
+// are more than 256 colours, the most frequent ones are kept.
+func buildPalette(images []*image.RGBA) color.Palette {
+	counts := make(map[color.RGBA]int)
+
+	for _, img := range images {
+		for i := 0; i < len(img.Pix); i += 4 {
+			c := color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}
+			counts[c]++
+		}
+	}
+
+	colors := make([]color.RGBA, 0, len(counts))
+	for c := range counts {
+		colors = append(colors, c)
+	}
+
+	sort.Slice(colors, func(i, j int) bool {
+		if counts[colors[i]] != counts[colors[j]] {
+			return counts[colors[i]] > counts[colors[j]]
+		}
+
+		a, b := colors[i], colors[j]
+
+		return uint32(a.R)<<24|uint32(a.G)<<16|uint32(a.B)<<8|uint32(a.A) < uint32(b.R)<<24|uint32(b.G)<<16|uint32(b.B)<<8|uint32(b.A)
+	})
+
+	if len(colors) > 256 {
+		colors = colors[:256]
+	}
+
+	pal := make(color.Palette, 0, len(colors)+1)
+	for _, c := range colors {
+		pal = append(pal, c)
+	}
+
+	if len(pal) == 0 {
+		pal = append(pal, color.RGBA{A: 0xff})
+	}
+
+	return pal
+}
+
+// toPaletted converts img to the palette, mapping every colour to the nearest
+// palette entry.
+func toPaletted(img *image.RGBA, pal color.Palette) *image.Paletted {
+	p := image.NewPaletted(img.Bounds(), pal)
+	index := make(map[color.RGBA]uint8)
+
+	for i, j := 0, 0; i < len(img.Pix); i, j = i+4, j+1 {
+		c := color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}
+
+		idx, ok := index[c]
+		if !ok {
+			idx = uint8(pal.Index(c))
+			index[c] = idx
+		}
+
+		p.Pix[j] = idx
+	}
+
+	return p
+}
+
+// changedBounds returns the smallest rectangle containing all pixels that
+// differ between prev and cur. If nothing changed, a single pixel is returned
+// so that the frame can still carry its delay.
+func changedBounds(prev *image.Paletted, cur *image.Paletted) image.Rectangle {
+	b := cur.Bounds()
+	changed := image.Rectangle{}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if prev.ColorIndexAt(x, y) != cur.ColorIndexAt(x, y) {
+				changed = changed.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	if changed.Empty() {
+		return image.Rect(b.Min.X, b.Min.Y, b.Min.X+1, b.Min.Y+1)
+	}
+
+	return changed
+}
+
+// ExportGIF renders every frame of the canvas with a bitmap font and writes
+// them to w as an animated GIF. All frames share one global palette, and only
+// the area that changed since the previous frame is stored for each frame.
+//
+// The canvas' current frame is restored afterwards. opts may be nil to use the
+// defaults.
+func ExportGIF(w io.Writer, cv Canvas, opts *AnimationOptions) error {
+	if opts == nil {
+		opts = &AnimationOptions{}
+	}
+
+	frames, pal, err := renderFrames(cv, opts)
+	if err != nil {
+		return err
+	}
+
+	anim := &gif.GIF{LoopCount: gifLoopCount(opts.Loops)}
+
+	for i, f := range frames {
+		sub, _ := f.img.SubImage(f.bounds).(*image.Paletted)
+		anim.Image = append(anim.Image, sub)
+		anim.Delay = append(anim.Delay, int(f.delay/(10*time.Millisecond)))
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+
+		if i == 0 {
+			anim.Config = image.Config{ColorModel: pal, Width: f.img.Rect.Dx(), Height: f.img.Rect.Dy()}
+		}
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// gifLoopCount converts a number of plays into the loop count of the GIF
+// format, which counts the repetitions after the first play.
+func gifLoopCount(loops int) int {
+	switch {
+	case loops <= 0:
+		return 0
+	case loops == 1:
+		return -1
+	default:
+		return loops - 1
+	}
+}
+
+// ExportAPNG renders every frame of the canvas with a bitmap font and writes
+// them to w as an animated PNG. Like ExportGIF(), all frames share one palette
+// and only the changed area of each frame is stored.
+//
+// The canvas' current frame is restored afterwards. opts may be nil to use the
+// defaults.
+func ExportAPNG(w io.Writer, cv Canvas, opts *AnimationOptions) error {
+	if opts == nil {
+		opts = &AnimationOptions{}
+	}
+
+	frames, _, err := renderFrames(cv, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(frames) == 0 {
+		return &Error{Op: "ExportAPNG", Err: ErrInvalidArgument}
+	}
+
+	apng := &apngWriter{w: w}
+	apng.write([]byte("\x89PNG\r\n\x1a\n"))
+
+	for i, f := range frames {
+		var buf bytes.Buffer
+
+		sub, _ := f.img.SubImage(f.bounds).(*image.Paletted)
+		if err := png.Encode(&buf, sub); err != nil {
+			return err
+		}
+
+		chunks, err := readPNGChunks(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			apng.writeFirstFrame(chunks, len(frames), opts.Loops, f)
+		} else {
+			apng.writeFrame(chunks, f)
+		}
+	}
+
+	apng.chunk("IEND", nil)
+
+	return apng.err
+}
+
+// pngChunk is a chunk of a PNG stream.
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// readPNGChunks splits an encoded PNG image into its chunks.
+func readPNGChunks(b []byte) ([]pngChunk, error) {
+	const sigLen = 8
+
+	var chunks []pngChunk
+
+	b = b[sigLen:]
+
+	for len(b) >= 12 {
+		n := int(binary.BigEndian.Uint32(b))
+		if len(b) < 12+n {
+			break
+		}
+
+		chunks = append(chunks, pngChunk{typ: string(b[4:8]), data: b[8 : 8+n]})
+		b = b[12+n:]
+	}
+
+	if len(b) != 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return chunks, nil
+}
+
+// apngWriter writes the chunks of an animated PNG and keeps the sequence
+// number shared by the fcTL and fdAT chunks.
+type apngWriter struct {
+	w   io.Writer
+	seq uint32
+	err error
+}
+
+func (a *apngWriter) write(b []byte) {
+	if a.err == nil {
+		_, a.err = a.w.Write(b)
+	}
+}
+
+func (a *apngWriter) chunk(typ string, data []byte) {
+	var hdr [8]byte
+
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(data)))
+	copy(hdr[4:], typ)
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(hdr[4:])
+	_, _ = crc.Write(data)
+
+	var sum [4]byte
+
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+
+	a.write(hdr[:])
+	a.write(data)
+	a.write(sum[:])
+}
+
+// writeFirstFrame writes the header chunks of the first frame followed by the
+// animation control chunk and the first frame control chunk.
+func (a *apngWriter) writeFirstFrame(chunks []pngChunk, frames int, loops int, f animFrame) {
+	controlled := false
+
+	for _, c := range chunks {
+		switch c.typ {
+		case "IEND":
+			continue
+		case "IDAT":
+			if !controlled {
+				actl := make([]byte, 8)
+				binary.BigEndian.PutUint32(actl[0:], uint32(frames))
+				binary.BigEndian.PutUint32(actl[4:], uint32(maxInt(loops, 0)))
+				a.chunk("acTL", actl)
+				a.frameControl(f)
+
+				controlled = true
+			}
+		}
+
+		a.chunk(c.typ, c.data)
+	}
+}
+
+// writeFrame writes the frame control chunk and the image data of a
+// following frame.
+func (a *apngWriter) writeFrame(chunks []pngChunk, f animFrame) {
+	a.frameControl(f)
+
+	for _, c := range chunks {
+		if c.typ != "IDAT" {
+			continue
+		}
+
+		data := make([]byte, 4+len(c.data))
+		binary.BigEndian.PutUint32(data, a.seq)
+		copy(data[4:], c.data)
+		a.seq++
+
+		a.chunk("fdAT", data)
+	}
+}
+
+func (a *apngWriter) frameControl(f animFrame) {
+	const (
+		disposeNone = 0
+		blendSource = 0
+	)
+
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], a.seq)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(f.bounds.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(f.bounds.Dy()))
+	binary.BigEndian.PutUint32(fctl[12:], uint32(f.bounds.Min.X))
+	binary.BigEndian.PutUint32(fctl[16:], uint32(f.bounds.Min.Y))
+	binary.BigEndian.PutUint16(fctl[20:], uint16(minInt(int(f.delay/time.Millisecond), 0xffff)))
+	binary.BigEndian.PutUint16(fctl[22:], 1000)
+	fctl[24] = disposeNone
+	fctl[25] = blendSource
+	a.seq++
+
+	a.chunk("fcTL", fctl)
+}
@@ -0,0 +1,303 @@
+package caca
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// LoopMode selects what an Animator does when it reaches the last frame.
+type LoopMode int
+
+const (
+	// LoopOnce stops playback after the last frame.
+	LoopOnce LoopMode = iota
+	// LoopRepeat continues with the first frame.
+	LoopRepeat
+	// LoopPingPong reverses the direction of playback at either end.
+	LoopPingPong
+)
+
+// Animator plays the frames of a display's canvas. Run() selects each frame
+// with Canvas.SetFrame() and shows it with Display.Refresh(), using
+// Display.SetTime() so that every frame stays on screen for its duration.
+//
+// Frame durations are taken from Canvas.SetFrameDuration(). Frames without a
+// duration use the animator's default duration.
+//
+// The control methods (Play(), Pause(), Seek() and so on) may be called from
+// any goroutine while Run() is active. All libcaca calls are made by Run().
+type Animator struct {
+	dp Display
+
+	mu       sync.Mutex
+	wake     chan struct{}
+	frame    int
+	forward  bool
+	playing  bool
+	seeked   bool
+	loop     LoopMode
+	speed    float64
+	fallback time.Duration
+}
+
+// NewAnimator creates an animator for the canvas attached to the display. The
+// animator starts paused on the first frame, with LoopRepeat, normal speed
+// and a default frame duration of 100ms.
+func NewAnimator(dp Display) *Animator {
+	dp.mustLive()
+
+	return &Animator{
+		dp:       dp,
+		wake:     make(chan struct{}, 1),
+		forward:  true,
+		seeked:   true,
+		loop:     LoopRepeat,
+		speed:    1,
+		fallback: defaultFrameDelay,
+	}
+}
+
+// notify wakes up Run() after the state has changed.
+func (a *Animator) notify() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Play starts or resumes playback.
+func (a *Animator) Play() {
+	a.mu.Lock()
+	a.playing = true
+	a.mu.Unlock()
+
+	a.notify()
+}
+
+// Pause pauses playback on the current frame.
+func (a *Animator) Pause() {
+	a.mu.Lock()
+	a.playing = false
+	a.mu.Unlock()
+
+	a.notify()
+}
+
+// Playing reports whether the animator is playing.
+func (a *Animator) Playing() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.playing
+}
+
+// Frame returns the index of the frame currently shown.
+func (a *Animator) Frame() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.frame
+}
+
+// Seek jumps to the given frame. It is shown immediately, even while
+// playback is paused.
+//
+// If the frame index is outside the canvas' frame range, ErrOutOfRange is
+// returned.
+func (a *Animator) Seek(frame int) error {
+	if frame < 0 || frame >= a.dp.GetCanvas().GetFrameCount() {
+		return &Error{Op: "Animator.Seek", Err: ErrOutOfRange}
+	}
+
+	a.mu.Lock()
+	a.frame = frame
+	a.seeked = true
+	a.mu.Unlock()
+
+	a.notify()
+
+	return nil
+}
+
+// SetLoop sets what happens when playback reaches the last frame.
+func (a *Animator) SetLoop(mode LoopMode) {
+	a.mu.Lock()
+	a.loop = mode
+	a.mu.Unlock()
+}
+
+// SetSpeed sets the playback speed. A speed of 2 plays the animation twice as
+// fast, 0.5 half as fast.
+//
+// If the speed is not positive, ErrInvalidArgument is returned.
+func (a *Animator) SetSpeed(speed float64) error {
+	if speed <= 0 {
+		return &Error{Op: "Animator.SetSpeed", Err: ErrInvalidArgument}
+	}
+
+	a.mu.Lock()
+	a.speed = speed
+	a.mu.Unlock()
+
+	return nil
+}
+
+// SetDefaultDuration sets the duration of frames that have none.
+//
+// If the duration is not positive, ErrInvalidArgument is returned.
+func (a *Animator) SetDefaultDuration(d time.Duration) error {
+	if d <= 0 {
+		return &Error{Op: "Animator.SetDefaultDuration", Err: ErrInvalidArgument}
+	}
+
+	a.mu.Lock()
+	a.fallback = d
+	a.mu.Unlock()
+
+	return nil
+}
+
+// advance moves to the next frame according to the loop mode and returns
+// false if playback ended. It must be called with the lock held.
+func (a *Animator) advance(count int) bool {
+	if count <= 1 {
+		return a.loop != LoopOnce
+	}
+
+	next := a.frame - 1
+	if a.forward {
+		next = a.frame + 1
+	}
+
+	if next >= 0 && next < count {
+		a.frame = next
+
+		return true
+	}
+
+	switch a.loop {
+	case LoopRepeat:
+		a.frame = 0
+	case LoopPingPong:
+		a.forward = !a.forward
+
+		return a.advance(count)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// Run plays the animation until ctx is cancelled, the display is freed or,
+// with LoopOnce, the last frame was shown. It shows the current frame right
+// away and then waits for Play() or Seek() while paused.
+//
+// Run locks the calling goroutine to its OS thread. Like the event functions,
+// it must not run concurrently with other functions using the display. The
+// display's refresh delay set with SetTime() is reset to zero on return.
+func (a *Animator) Run(ctx context.Context) error {
+	a.dp.ptr()
+	stop, done := startPump(a.dp.h)
+	defer done()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cv := a.dp.GetCanvas()
+
+	defer func() { _ = a.dp.SetTime(0) }()
+
+	// delay is the time the previously shown frame stays on screen. Refresh()
+	// waits for it before showing the next frame.
+	delay := time.Duration(0)
+
+	for {
+		a.mu.Lock()
+		show := a.playing || a.seeked
+		a.mu.Unlock()
+
+		if !show {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-stop:
+				return context.Canceled
+			case <-a.wake:
+			}
+
+			delay = 0
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stop:
+			return context.Canceled
+		default:
+		}
+
+		a.mu.Lock()
+		frame, seeked, speed, fallback := a.frame, a.seeked, a.speed, a.fallback
+		a.seeked = false
+		a.mu.Unlock()
+
+		if seeked {
+			delay = 0
+		}
+
+		if err := cv.SetFrame(frame); err != nil {
+			return err
+		}
+
+		if err := a.dp.SetTime(displayTime(delay)); err != nil {
+			return err
+		}
+
+		a.dp.Refresh()
+
+		d := cv.GetFrameDuration()
+		if d <= 0 {
+			d = fallback
+		}
+
+		delay = time.Duration(float64(d) / speed)
+
+		a.mu.Lock()
+		if a.playing && !a.seeked && !a.advance(cv.GetFrameCount()) {
+			a.playing = false
+			a.mu.Unlock()
+
+			// Keep the last frame on screen for its duration.
+			_ = a.dp.SetTime(displayTime(delay))
+			a.dp.Refresh()
+
+			return nil
+		}
+		a.mu.Unlock()
+	}
+}
+
+// maxDisplayDelay is the longest delay Display.SetTime() can express, libcaca
+// takes it in microseconds as a C int.
+const maxDisplayDelay = math.MaxInt32 * time.Microsecond
+
+// displayTime converts a frame delay to the microseconds passed to
+// Display.SetTime(). Longer delays are clamped to maxDisplayDelay, about 35
+// minutes, instead of wrapping around to a negative value.
+func displayTime(delay time.Duration) int {
+	if delay > maxDisplayDelay {
+		delay = maxDisplayDelay
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	return int(delay / time.Microsecond)
+}
@@ -0,0 +1,29 @@
+package caca
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDisplayTime(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  int
+	}{
+		{0, 0},
+		{40 * time.Millisecond, 40000},
+		{1500 * time.Nanosecond, 1},
+		{-time.Second, 0},
+		{math.MaxInt32 * time.Microsecond, math.MaxInt32},
+		{(math.MaxInt32 + 1) * time.Microsecond, math.MaxInt32},
+		{(1 << 32) * time.Microsecond, math.MaxInt32},
+		{24 * time.Hour, math.MaxInt32},
+	}
+
+	for _, tt := range tests {
+		if got := displayTime(tt.delay); got != tt.want {
+			t.Errorf("displayTime(%v) = %d, want %d", tt.delay, got, tt.want)
+		}
+	}
+}
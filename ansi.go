@@ -0,0 +1,187 @@
+package caca
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// TermColors selects the colour escape sequences used for terminal output.
+type TermColors int
+
+const (
+	// TermColors16 uses the 16 standard ANSI colours. ARGB colours are
+	// replaced with the nearest ANSI colour.
+	TermColors16 TermColors = iota
+	// TermColors256 uses the xterm 256-colour palette for ARGB colours.
+	TermColors256
+	// TermColorsTrue uses 24-bit colours for ARGB colours.
+	TermColorsTrue
+)
+
+// ansiOrder maps libcaca's colour indices, which follow the DOS palette, to
+// the order of the ANSI colour escape sequences and back.
+var ansiOrder = [8]int{0, 4, 2, 6, 1, 5, 3, 7}
+
+// termWriter writes canvas cells as terminal output and keeps track of the
+// terminal's cursor and attribute so that only the needed escape sequences
+// are emitted.
+type termWriter struct {
+	buf    bytes.Buffer
+	colors TermColors
+	x, y   int
+	attr   Attr
+	known  bool
+	sgr    map[Attr]string
+}
+
+func newTermWriter(colors TermColors) *termWriter {
+	return &termWriter{colors: colors, sgr: make(map[Attr]string)}
+}
+
+// reset forgets the terminal state, so that the next cell emits a cursor
+// move and a full attribute.
+func (t *termWriter) reset() {
+	t.known = false
+}
+
+// clear writes a full screen clear and resets the terminal state.
+func (t *termWriter) clear() {
+	t.buf.WriteString("\x1b[0m\x1b[H\x1b[2J")
+	t.x, t.y, t.attr, t.known = 0, 0, 0, false
+}
+
+// moveTo moves the cursor to the given cell.
+func (t *termWriter) moveTo(x int, y int) {
+	if t.known && x == t.x && y == t.y {
+		return
+	}
+
+	switch {
+	case t.known && y == t.y && x > t.x && x-t.x <= 4:
+		t.buf.WriteString("\x1b[" + strconv.Itoa(x-t.x) + "C")
+	case t.known && y == t.y && x == 0:
+		t.buf.WriteByte('\r')
+	default:
+		t.buf.WriteString("\x1b[" + strconv.Itoa(y+1) + ";" + strconv.Itoa(x+1) + "H")
+	}
+
+	t.x, t.y = x, y
+}
+
+// setAttr switches to the given attribute.
+func (t *termWriter) setAttr(a Attr) {
+	if t.known && a == t.attr {
+		return
+	}
+
+	s, ok := t.sgr[a]
+	if !ok {
+		s = sgrSequence(a, t.colors)
+		t.sgr[a] = s
+	}
+
+	t.buf.WriteString(s)
+	t.attr = a
+}
+
+// putCell writes the cell at the given coordinates. width is 2 for fullwidth
+// characters.
+func (t *termWriter) putCell(x int, y int, ch rune, a Attr, width int) {
+	t.moveTo(x, y)
+	t.setAttr(a)
+	t.known = true
+
+	if ch < 0x20 || ch == 0x7f {
+		ch = ' '
+	}
+
+	t.buf.WriteRune(ch)
+	t.x += width
+}
+
+// putRow writes the cells of a row from x0 to x1 (exclusive) whose character
+// or attribute differs from the previous state. prevChars and prevAttrs may
+// be nil to write every cell.
+func (t *termWriter) putRow(y int, x0 int, x1 int, chars []rune, attrs []Attr, prevChars []rune, prevAttrs []Attr) {
+	changed := func(x int) bool {
+		return prevChars == nil || chars[x] != prevChars[x] || attrs[x] != prevAttrs[x]
+	}
+
+	// Start on the left half of a fullwidth character cut by the range.
+	if x0 > 0 && x0 < len(chars) && chars[x0] == MagicFullwidth {
+		x0--
+	}
+
+	for x := x0; x < x1 && x < len(chars); x++ {
+		if chars[x] == MagicFullwidth {
+			continue
+		}
+
+		width := 1
+		if x+1 < len(chars) && chars[x+1] == MagicFullwidth {
+			width = 2
+		}
+
+		if !changed(x) && (width == 1 || !changed(x+1)) {
+			continue
+		}
+
+		t.putCell(x, y, chars[x], attrs[x], width)
+	}
+}
+
+// sgrSequence returns the escape sequence selecting the attribute.
+func sgrSequence(a Attr, colors TermColors) string {
+	seq := []byte("\x1b[0")
+
+	if a.Bold() {
+		seq = append(seq, ";1"...)
+	}
+
+	if a.Italics() {
+		seq = append(seq, ";3"...)
+	}
+
+	if a.Underline() {
+		seq = append(seq, ";4"...)
+	}
+
+	if a.Blink() {
+		seq = append(seq, ";5"...)
+	}
+
+	seq = appendColor(seq, a.FgIsAnsi(), a.FgIsDefault() || a.FgIsTransparent(), a.FgAnsi(), a.FgRGB12(), colors, 30)
+	seq = appendColor(seq, a.BgIsAnsi(), a.BgIsDefault() || a.BgIsTransparent(), a.BgAnsi(), a.BgRGB12(), colors, 40)
+
+	return string(append(seq, 'm'))
+}
+
+// appendColor appends the parameters selecting a foreground (base 30) or
+// background (base 40) colour.
+func appendColor(seq []byte, isAnsi bool, isDefault bool, ansi uint8, rgb12 uint16, colors TermColors, base int) []byte {
+	if isDefault {
+		return append(seq, ";"+strconv.Itoa(base+9)...)
+	}
+
+	if isAnsi || colors == TermColors16 {
+		if ansi >= 16 {
+			return append(seq, ";"+strconv.Itoa(base+9)...)
+		}
+
+		if ansi < 8 {
+			return append(seq, ";"+strconv.Itoa(base+ansiOrder[ansi])...)
+		}
+
+		return append(seq, ";"+strconv.Itoa(base+60+ansiOrder[ansi-8])...)
+	}
+
+	r, g, b := int(rgb12>>8&0xf), int(rgb12>>4&0xf), int(rgb12&0xf)
+
+	if colors == TermColors256 {
+		cube := func(v int) int { return (v*5 + 7) / 15 }
+
+		return append(seq, ";"+strconv.Itoa(base+8)+";5;"+strconv.Itoa(16+36*cube(r)+6*cube(g)+cube(b))...)
+	}
+
+	return append(seq, ";"+strconv.Itoa(base+8)+";2;"+strconv.Itoa(r*17)+";"+strconv.Itoa(g*17)+";"+strconv.Itoa(b*17)...)
+}
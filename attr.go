@@ -0,0 +1,205 @@
+package caca
+
+// Attr is a libcaca character attribute as returned by Canvas.GetAttr(). It
+// packs the foreground and background colours and the style flags into 32
+// bits, see GetAttr() for the exact layout.
+//
+// Each colour is stored on 14 bits, either as one of the ANSI colours defined
+// in common.go (ColorRed, ColorDefault, ColorTransparent...) or as a 12-bit
+// RGB value with a 3-bit alpha channel, as set by Canvas.SetColorARGB().
+type Attr uint32
+
+// Cell is the content of a single canvas cell: a character and its
+// attribute.
+type Cell struct {
+	Rune rune
+	Attr Attr
+}
+
+const (
+	attrStyleMask = 0xf
+	attrColorMask = 0x3fff
+	attrFgShift   = 4
+	attrBgShift   = 18
+
+	// ANSI colours are stored as the colour index with this bit set. Like
+	// libcaca's _caca_attr_to_rgb24fg(), a colour is decoded as ANSI if it is
+	// below attrAnsiFlag|0x10 or is one of the ColorDefault and
+	// ColorTransparent values; every other value is an ARGB colour. A few
+	// ARGB colours with a zero alpha channel, such as 0x1080, encode to the
+	// same values as ANSI colours and are decoded as such, by libcaca too.
+	attrAnsiFlag = 0x40
+)
+
+// NewAttrAnsi builds an attribute from an ANSI colour pair and a combination
+// (bitwise OR) of style values such as StyleBold. The colours are those
+// accepted by Canvas.SetColorAnsi(). Values other than the 16 colours,
+// ColorDefault and ColorTransparent are replaced with ColorDefault.
+func NewAttrAnsi(fg uint8, bg uint8, style uint8) Attr {
+	return Attr(style&attrStyleMask) | ansiColor(fg)<<attrFgShift | ansiColor(bg)<<attrBgShift
+}
+
+// NewAttrARGB builds an attribute from a 16-bit ARGB colour pair and a
+// combination (bitwise OR) of style values such as StyleBold. The colours are
+// those accepted by Canvas.SetColorARGB().
+func NewAttrARGB(fg uint16, bg uint16, style uint8) Attr {
+	return Attr(style&attrStyleMask) | argbColor(fg)<<attrFgShift | argbColor(bg)<<attrBgShift
+}
+
+// ansiColor encodes an ANSI colour index as a 14-bit attribute colour.
+func ansiColor(c uint8) Attr {
+	if c > ColorWhite && c != ColorTransparent {
+		c = ColorDefault
+	}
+
+	return Attr(c) | attrAnsiFlag
+}
+
+// argbColor encodes a 16-bit ARGB colour as a 14-bit attribute colour, the
+// same way libcaca's caca_set_color_argb() does.
+func argbColor(c uint16) Attr {
+	if c < 0x100 {
+		c += 0x100
+	}
+
+	return Attr((c>>1)&0x7ff | (c>>13)<<11)
+}
+
+func (a Attr) fg() Attr {
+	return (a >> attrFgShift) & attrColorMask
+}
+
+func (a Attr) bg() Attr {
+	return (a >> attrBgShift) & attrColorMask
+}
+
+// isAnsiColor reports whether a 14-bit attribute colour holds an ANSI colour,
+// ColorDefault or ColorTransparent.
+func isAnsiColor(c Attr) bool {
+	return c < attrAnsiFlag|0x10 || c == attrAnsiFlag|ColorDefault || c == attrAnsiFlag|ColorTransparent
+}
+
+// Style returns the style flags of the attribute, a combination (bitwise OR)
+// of StyleBold, StyleItalics, StyleUnderline and StyleBlink.
+func (a Attr) Style() uint8 {
+	return uint8(a & attrStyleMask)
+}
+
+// Bold reports whether the bold flag is set.
+func (a Attr) Bold() bool {
+	return a&StyleBold != 0
+}
+
+// Italics reports whether the italics flag is set.
+func (a Attr) Italics() bool {
+	return a&StyleItalics != 0
+}
+
+// Underline reports whether the underline flag is set.
+func (a Attr) Underline() bool {
+	return a&StyleUnderline != 0
+}
+
+// Blink reports whether the blink flag is set.
+func (a Attr) Blink() bool {
+	return a&StyleBlink != 0
+}
+
+// WithStyle returns a copy of the attribute with the style flags replaced by
+// style.
+func (a Attr) WithStyle(style uint8) Attr {
+	return a&^attrStyleMask | Attr(style&attrStyleMask)
+}
+
+// WithFgAnsi returns a copy of the attribute with the foreground colour
+// replaced by the given ANSI colour.
+func (a Attr) WithFgAnsi(fg uint8) Attr {
+	return a&^(attrColorMask<<attrFgShift) | ansiColor(fg)<<attrFgShift
+}
+
+// WithBgAnsi returns a copy of the attribute with the background colour
+// replaced by the given ANSI colour.
+func (a Attr) WithBgAnsi(bg uint8) Attr {
+	return a&^(attrColorMask<<attrBgShift) | ansiColor(bg)<<attrBgShift
+}
+
+// WithFgARGB returns a copy of the attribute with the foreground colour
+// replaced by the given 16-bit ARGB colour.
+func (a Attr) WithFgARGB(fg uint16) Attr {
+	return a&^(attrColorMask<<attrFgShift) | argbColor(fg)<<attrFgShift
+}
+
+// WithBgARGB returns a copy of the attribute with the background colour
+// replaced by the given 16-bit ARGB colour.
+func (a Attr) WithBgARGB(bg uint16) Attr {
+	return a&^(attrColorMask<<attrBgShift) | argbColor(bg)<<attrBgShift
+}
+
+// FgIsAnsi reports whether the foreground colour is an ANSI colour rather
+// than an ARGB colour.
+func (a Attr) FgIsAnsi() bool {
+	return isAnsiColor(a.fg())
+}
+
+// BgIsAnsi reports whether the background colour is an ANSI colour rather
+// than an ARGB colour.
+func (a Attr) BgIsAnsi() bool {
+	return isAnsiColor(a.bg())
+}
+
+// FgIsDefault reports whether the foreground colour is the media's default
+// foreground colour (ColorDefault).
+func (a Attr) FgIsDefault() bool {
+	return a.fg() == attrAnsiFlag|ColorDefault
+}
+
+// BgIsDefault reports whether the background colour is the media's default
+// background colour (ColorDefault).
+func (a Attr) BgIsDefault() bool {
+	return a.bg() == attrAnsiFlag|ColorDefault
+}
+
+// FgIsTransparent reports whether the foreground colour is ColorTransparent.
+func (a Attr) FgIsTransparent() bool {
+	return a.fg() == attrAnsiFlag|ColorTransparent
+}
+
+// BgIsTransparent reports whether the background colour is ColorTransparent.
+func (a Attr) BgIsTransparent() bool {
+	return a.bg() == attrAnsiFlag|ColorTransparent
+}
+
+// Ansi returns the ANSI colour pair of the attribute, see AttrToAnsi().
+func (a Attr) Ansi() uint8 {
+	return AttrToAnsi(uint32(a))
+}
+
+// FgAnsi returns the ANSI foreground colour of the attribute, see
+// AttrToAnsiFg().
+func (a Attr) FgAnsi() uint8 {
+	return AttrToAnsiFg(uint32(a))
+}
+
+// BgAnsi returns the ANSI background colour of the attribute, see
+// AttrToAnsiBg().
+func (a Attr) BgAnsi() uint8 {
+	return AttrToAnsiBg(uint32(a))
+}
+
+// FgRGB12 returns the 12-bit foreground colour of the attribute, see
+// AttrToRGB12Fg().
+func (a Attr) FgRGB12() uint16 {
+	return AttrToRGB12Fg(uint32(a))
+}
+
+// BgRGB12 returns the 12-bit background colour of the attribute, see
+// AttrToRGB12Bg().
+func (a Attr) BgRGB12() uint16 {
+	return AttrToRGB12Bg(uint32(a))
+}
+
+// ARGB64 returns the foreground and background ARGB components of the
+// attribute, see AttrToARGB64().
+func (a Attr) ARGB64() [8]uint8 {
+	return AttrToARGB64(uint32(a))
+}
@@ -0,0 +1,59 @@
+package caca
+
+import "testing"
+
+func TestAttrColorKinds(t *testing.T) {
+	tests := []struct {
+		name        string
+		attr        Attr
+		ansi        bool
+		def         bool
+		transparent bool
+	}{
+		{"ansi black", NewAttrAnsi(ColorBlack, ColorBlack, 0), true, false, false},
+		{"ansi white", NewAttrAnsi(ColorWhite, ColorWhite, 0), true, false, false},
+		{"default", NewAttrAnsi(ColorDefault, ColorDefault, 0), true, true, false},
+		{"transparent", NewAttrAnsi(ColorTransparent, ColorTransparent, 0), true, false, true},
+		{"out of range ansi", NewAttrAnsi(0x11, 0x11, 0), true, true, false},
+		{"opaque argb", NewAttrARGB(0xf123, 0xf123, 0), false, false, false},
+		// 0x10a2 encodes to 0x51, just above the ANSI colours.
+		{"argb above ansi", NewAttrARGB(0x10a2, 0x10a2, 0), false, false, false},
+		// 0x1080 encodes to 0x40, the same value as ANSI black, and libcaca
+		// decodes it as such.
+		{"argb aliasing ansi black", NewAttrARGB(0x1080, 0x1080, 0), true, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.attr.FgIsAnsi(); got != tt.ansi {
+			t.Errorf("%s: FgIsAnsi() = %v, want %v", tt.name, got, tt.ansi)
+		}
+
+		if got := tt.attr.BgIsAnsi(); got != tt.ansi {
+			t.Errorf("%s: BgIsAnsi() = %v, want %v", tt.name, got, tt.ansi)
+		}
+
+		if got := tt.attr.FgIsDefault(); got != tt.def {
+			t.Errorf("%s: FgIsDefault() = %v, want %v", tt.name, got, tt.def)
+		}
+
+		if got := tt.attr.BgIsTransparent(); got != tt.transparent {
+			t.Errorf("%s: BgIsTransparent() = %v, want %v", tt.name, got, tt.transparent)
+		}
+	}
+}
+
+func TestAttrMatchesLibcaca(t *testing.T) {
+	cv, err := CreateCanvas(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	for _, c := range []uint16{0x1080, 0x10a2, 0xf123, 0x8fff, 0x0000} {
+		cv.SetColorARGB(int16(c), int16(c))
+
+		if got, want := NewAttrARGB(c, c, 0), cv.GetAttr(-1, -1); got != want {
+			t.Errorf("NewAttrARGB(%#x) = %#x, libcaca stores %#x", c, got, want)
+		}
+	}
+}
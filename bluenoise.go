@@ -0,0 +1,144 @@
+package caca
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// blueNoiseSize is the width and height of the blue noise threshold matrix.
+const blueNoiseSize = 32
+
+var (
+	blueNoiseOnce   sync.Once
+	blueNoiseMatrix []float64
+)
+
+// blueNoiseThreshold returns the blue noise threshold for the given cell. The
+// matrix is generated on first use and tiled over the canvas.
+func blueNoiseThreshold(x int, y int) float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoiseMatrix = makeBlueNoise(blueNoiseSize, 1.5)
+	})
+
+	return blueNoiseMatrix[(y%blueNoiseSize)*blueNoiseSize+x%blueNoiseSize]
+}
+
+// makeBlueNoise generates an n×n blue noise threshold matrix with the
+// void-and-cluster method, using a Gaussian energy filter with the given
+// standard deviation. The thresholds are between 0 and 1.
+func makeBlueNoise(n int, sigma float64) []float64 {
+	size := n * n
+
+	// kernel[dy*n+dx] is the energy a point contributes at a toroidal
+	// distance of (dx, dy).
+	kernel := make([]float64, size)
+
+	for dy := 0; dy < n; dy++ {
+		for dx := 0; dx < n; dx++ {
+			ddx, ddy := math.Min(float64(dx), float64(n-dx)), math.Min(float64(dy), float64(n-dy))
+			kernel[dy*n+dx] = math.Exp(-(ddx*ddx + ddy*ddy) / (2 * sigma * sigma))
+		}
+	}
+
+	field := &noiseField{n: n, kernel: kernel, on: make([]bool, size), energy: make([]float64, size)}
+
+	// Start with a random pattern of about a tenth of the points and relax it
+	// by moving the tightest cluster into the largest void until that no
+	// longer changes anything.
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec // reproducible noise, not security sensitive
+	initial := size / 10
+
+	for _, p := range rnd.Perm(size)[:initial] {
+		field.toggle(p)
+	}
+
+	for {
+		cluster := field.extreme(true)
+		field.toggle(cluster)
+
+		void := field.extreme(false)
+		if void == cluster {
+			field.toggle(cluster)
+
+			break
+		}
+
+		field.toggle(void)
+	}
+
+	prototype := append([]bool(nil), field.on...)
+	ranks := make([]int, size)
+
+	// Rank the initial points by removing the tightest cluster first.
+	for rank := initial - 1; rank >= 0; rank-- {
+		p := field.extreme(true)
+		field.toggle(p)
+		ranks[p] = rank
+	}
+
+	// Rank the remaining points by filling the largest void first.
+	for p, on := range prototype {
+		if on {
+			field.toggle(p)
+		}
+	}
+
+	for rank := initial; rank < size; rank++ {
+		p := field.extreme(false)
+		field.toggle(p)
+		ranks[p] = rank
+	}
+
+	matrix := make([]float64, size)
+	for p, rank := range ranks {
+		matrix[p] = (float64(rank) + 0.5) / float64(size)
+	}
+
+	return matrix
+}
+
+// noiseField is the binary pattern used by makeBlueNoise() together with the
+// energy of every point.
+type noiseField struct {
+	n      int
+	kernel []float64
+	on     []bool
+	energy []float64
+}
+
+// toggle sets or clears the point p and updates the energy of all points.
+func (f *noiseField) toggle(p int) {
+	sign := 1.0
+	if f.on[p] {
+		sign = -1
+	}
+
+	f.on[p] = !f.on[p]
+	px, py := p%f.n, p/f.n
+
+	for q := range f.energy {
+		dx := (q%f.n - px + f.n) % f.n
+		dy := (q/f.n - py + f.n) % f.n
+		f.energy[q] += sign * f.kernel[dy*f.n+dx]
+	}
+}
+
+// extreme returns the set point with the highest energy (the tightest
+// cluster) if on is true, or the unset point with the lowest energy (the
+// largest void) otherwise.
+func (f *noiseField) extreme(on bool) int {
+	best := -1
+
+	for p, e := range f.energy {
+		if f.on[p] != on {
+			continue
+		}
+
+		if best == -1 || (on && e > f.energy[best]) || (!on && e < f.energy[best]) {
+			best = p
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,464 @@
+// Package cacafmt reads and writes libcaca's native "caca" canvas format in
+// pure Go, without cgo or libcaca. It can be used to inspect, validate and
+// transform caca files on machines where libcaca is not installed; the caca
+// package converts the in-memory representation to and from its Canvas type.
+//
+// A caca file holds one canvas with one or more frames of equal size:
+//
+//     magic        "\xCA\xCA" "CV"
+//     control section:
+//         control size    uint32, size of the control section
+//         data size       uint32, size of the cell data
+//         version         uint16, 1
+//         frames          uint32
+//         flags           uint16, 0
+//         frame info, for every frame:
+//             width, height    uint32
+//             duration         uint32, in milliseconds, 0 if unset,
+//                              at most 0x80000000
+//             attr             uint32, current attribute
+//             x, y             int32, cursor position
+//             handlex, handley int32, handle position
+//     cell data, for every frame and cell, row by row:
+//         char, attr      uint32
+//
+// All numbers are big-endian. libcaca skips any bytes at the end of the
+// control section, which this package uses to store frame names after a
+// "NAME" tag, as a uint16 length and UTF-8 bytes for every frame. Files
+// written by this package can therefore be imported by libcaca unchanged.
+package cacafmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	headerSize    = 12
+	canvasHdrSize = 16
+	frameInfoSize = 32
+	cellSize      = 8
+	version       = 0x0001
+
+	// maxSize bounds the size of a file accepted by Decode() and Read().
+	maxSize = 1 << 30
+)
+
+// MaxDuration is the longest frame duration that can be stored. libcaca
+// refuses to import files with longer durations.
+const MaxDuration = 0x80000000 * time.Millisecond
+
+var (
+	magic   = []byte{0xca, 0xca, 'C', 'V'}
+	nameTag = []byte("NAME")
+)
+
+var (
+	// ErrInvalid is returned for data that is not a valid caca file.
+	ErrInvalid = errors.New("cacafmt: invalid caca data")
+	// ErrShort is returned by Decode() if the data ends before the canvas
+	// is complete. More data may complete it.
+	ErrShort = errors.New("cacafmt: incomplete caca data")
+)
+
+// Canvas is a canvas in the caca format. All frames have the size of the
+// canvas.
+type Canvas struct {
+	Width  int
+	Height int
+	Frames []Frame
+}
+
+// Frame is a single frame of a Canvas.
+type Frame struct {
+	// Name is the frame name. It is stored in an extension that libcaca
+	// ignores.
+	Name string
+	// Duration is the display duration of the frame, rounded to
+	// milliseconds. Zero means unset.
+	Duration time.Duration
+	// Attr is the current attribute of the frame, used for drawing.
+	Attr uint32
+	// CursorX and CursorY are the cursor position.
+	CursorX, CursorY int
+	// HandleX and HandleY are the position of the frame's handle.
+	HandleX, HandleY int
+	// Chars and Attrs hold Width*Height cells row by row.
+	Chars []rune
+	Attrs []uint32
+}
+
+// Validate checks that the canvas can be encoded: it needs at least one
+// frame, every frame must hold Width*Height cells and names must fit into the
+// name extension.
+func (c *Canvas) Validate() error {
+	if c.Width < 0 || c.Height < 0 || len(c.Frames) == 0 {
+		return fmt.Errorf("%w: size %dx%d with %d frames", ErrInvalid, c.Width, c.Height, len(c.Frames))
+	}
+
+	n := c.Width * c.Height
+
+	for i, f := range c.Frames {
+		if len(f.Chars) != n || len(f.Attrs) != n {
+			return fmt.Errorf("%w: frame %d holds %d chars and %d attrs, want %d", ErrInvalid, i, len(f.Chars), len(f.Attrs), n)
+		}
+
+		if len(f.Name) > 0xffff {
+			return fmt.Errorf("%w: name of frame %d is too long", ErrInvalid, i)
+		}
+
+		if f.Duration < 0 || f.Duration > MaxDuration {
+			return fmt.Errorf("%w: duration of frame %d out of range", ErrInvalid, i)
+		}
+	}
+
+	return nil
+}
+
+// named reports whether any frame has a name.
+func (c *Canvas) named() bool {
+	for _, f := range c.Frames {
+		if f.Name != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Encode returns the canvas in the caca format.
+func (c *Canvas) Encode() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	control := canvasHdrSize + frameInfoSize*len(c.Frames)
+
+	if c.named() {
+		control += len(nameTag)
+		for _, f := range c.Frames {
+			control += 2 + len(f.Name)
+		}
+	}
+
+	data := cellSize * c.Width * c.Height * len(c.Frames)
+	if len(magic)+control+data > maxSize {
+		return nil, fmt.Errorf("%w: canvas too large", ErrInvalid)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(magic)+control+data))
+	buf.Write(magic)
+
+	put := func(v interface{}) { _ = binary.Write(buf, binary.BigEndian, v) }
+
+	put(uint32(control))
+	put(uint32(data))
+	put(uint16(version))
+	put(uint32(len(c.Frames)))
+	put(uint16(0))
+
+	for _, f := range c.Frames {
+		put([8]uint32{
+			uint32(c.Width), uint32(c.Height), uint32(f.Duration / time.Millisecond), f.Attr,
+			uint32(int32(f.CursorX)), uint32(int32(f.CursorY)), uint32(int32(f.HandleX)), uint32(int32(f.HandleY)),
+		})
+	}
+
+	if c.named() {
+		buf.Write(nameTag)
+
+		for _, f := range c.Frames {
+			put(uint16(len(f.Name)))
+			buf.WriteString(f.Name)
+		}
+	}
+
+	cells := make([]uint32, 2*c.Width*c.Height)
+
+	for _, f := range c.Frames {
+		for i := range f.Chars {
+			cells[2*i] = uint32(f.Chars[i])
+			cells[2*i+1] = f.Attrs[i]
+		}
+
+		put(cells)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MarshalBinary encodes the canvas like Encode() does.
+func (c *Canvas) MarshalBinary() ([]byte, error) {
+	return c.Encode()
+}
+
+// UnmarshalBinary decodes a canvas like Decode() does. Trailing data after the
+// canvas is an error.
+func (c *Canvas) UnmarshalBinary(data []byte) error {
+	d, n, err := Decode(data)
+	if err != nil {
+		return err
+	}
+
+	if n != len(data) {
+		return fmt.Errorf("%w: %d bytes of trailing data", ErrInvalid, len(data)-n)
+	}
+
+	*c = *d
+
+	return nil
+}
+
+// WriteTo writes the canvas to w in the caca format.
+func (c *Canvas) WriteTo(w io.Writer) (int64, error) {
+	data, err := c.Encode()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+
+	return int64(n), err
+}
+
+// Len returns the length of the caca canvas at the start of data, as declared
+// by its header. If data is shorter than the header, ErrShort is returned.
+func Len(data []byte) (int, error) {
+	if len(data) < headerSize {
+		if !bytes.HasPrefix(magic, data[:minInt(len(data), len(magic))]) {
+			return 0, fmt.Errorf("%w: bad magic", ErrInvalid)
+		}
+
+		return 0, ErrShort
+	}
+
+	if !bytes.HasPrefix(data, magic) {
+		return 0, fmt.Errorf("%w: bad magic", ErrInvalid)
+	}
+
+	control := uint64(binary.BigEndian.Uint32(data[4:]))
+	cells := uint64(binary.BigEndian.Uint32(data[8:]))
+	n := uint64(len(magic)) + control + cells
+
+	if control < canvasHdrSize+frameInfoSize || n > maxSize {
+		return 0, fmt.Errorf("%w: bad section sizes", ErrInvalid)
+	}
+
+	return int(n), nil
+}
+
+// Decode decodes the canvas at the start of data and returns it together with
+// the number of bytes it took up. If the data ends before the canvas does,
+// ErrShort is returned; data that is not a caca canvas gives ErrInvalid.
+func Decode(data []byte) (*Canvas, int, error) {
+	n, err := Len(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(data) < n {
+		return nil, 0, ErrShort
+	}
+
+	control := int(binary.BigEndian.Uint32(data[4:]))
+	cells := int(binary.BigEndian.Uint32(data[8:]))
+	ctl := data[len(magic)+8 : len(magic)+control]
+	frames := int(binary.BigEndian.Uint32(ctl[2:]))
+
+	if frames == 0 || frames > (control-canvasHdrSize)/frameInfoSize {
+		return nil, 0, fmt.Errorf("%w: bad frame count %d", ErrInvalid, frames)
+	}
+
+	c := &Canvas{Frames: make([]Frame, frames)}
+	info := ctl[canvasHdrSize-8:]
+
+	for i := range c.Frames {
+		var v [8]uint32
+
+		for j := range v {
+			v[j] = binary.BigEndian.Uint32(info[i*frameInfoSize+4*j:])
+		}
+
+		w, h := int(v[0]), int(v[1])
+
+		if i == 0 {
+			c.Width, c.Height = w, h
+		} else if w != c.Width || h != c.Height {
+			return nil, 0, fmt.Errorf("%w: frame %d is %dx%d, frame 0 is %dx%d", ErrInvalid, i, w, h, c.Width, c.Height)
+		}
+
+		if time.Duration(v[2])*time.Millisecond > MaxDuration {
+			return nil, 0, fmt.Errorf("%w: duration of frame %d out of range", ErrInvalid, i)
+		}
+
+		c.Frames[i] = Frame{
+			Duration: time.Duration(v[2]) * time.Millisecond,
+			Attr:     v[3],
+			CursorX:  int(int32(v[4])),
+			CursorY:  int(int32(v[5])),
+			HandleX:  int(int32(v[6])),
+			HandleY:  int(int32(v[7])),
+		}
+	}
+
+	if uint64(c.Width)*uint64(c.Height)*cellSize*uint64(frames) != uint64(cells) {
+		return nil, 0, fmt.Errorf("%w: data size %d does not match %d frames of %dx%d", ErrInvalid, cells, frames, c.Width, c.Height)
+	}
+
+	decodeNames(c, info[frames*frameInfoSize:])
+
+	body := data[len(magic)+control : n]
+	size := c.Width * c.Height
+
+	for i := range c.Frames {
+		f := &c.Frames[i]
+		f.Chars = make([]rune, size)
+		f.Attrs = make([]uint32, size)
+
+		for j := 0; j < size; j++ {
+			off := (i*size + j) * cellSize
+			f.Chars[j] = rune(binary.BigEndian.Uint32(body[off:]))
+			f.Attrs[j] = binary.BigEndian.Uint32(body[off+4:])
+		}
+	}
+
+	return c, n, nil
+}
+
+// decodeNames reads the frame names from the extension data after the frame
+// info. Unknown or malformed extensions are ignored, like libcaca does.
+func decodeNames(c *Canvas, ext []byte) {
+	if !bytes.HasPrefix(ext, nameTag) {
+		return
+	}
+
+	ext = ext[len(nameTag):]
+	names := make([]string, len(c.Frames))
+
+	for i := range names {
+		if len(ext) < 2 {
+			return
+		}
+
+		n := int(binary.BigEndian.Uint16(ext))
+		if len(ext) < 2+n {
+			return
+		}
+
+		names[i] = string(ext[2 : 2+n])
+		ext = ext[2+n:]
+	}
+
+	for i, name := range names {
+		c.Frames[i].Name = name
+	}
+}
+
+// frameInfo returns the frame info section of the caca canvas at the start of
+// data and its number of frames.
+func frameInfo(data []byte) ([]byte, int, error) {
+	n, err := Len(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(data) < n {
+		return nil, 0, ErrShort
+	}
+
+	control := int(binary.BigEndian.Uint32(data[4:]))
+	ctl := data[len(magic)+8 : len(magic)+control]
+	frames := int(binary.BigEndian.Uint32(ctl[2:]))
+
+	if frames == 0 || frames > (control-canvasHdrSize)/frameInfoSize {
+		return nil, 0, fmt.Errorf("%w: bad frame count %d", ErrInvalid, frames)
+	}
+
+	return ctl[canvasHdrSize-8 : canvasHdrSize-8+frames*frameInfoSize], frames, nil
+}
+
+// Durations returns the frame durations of the caca canvas at the start of
+// data without decoding its cells.
+func Durations(data []byte) ([]time.Duration, error) {
+	info, frames, err := frameInfo(data)
+	if err != nil {
+		return nil, err
+	}
+
+	durations := make([]time.Duration, frames)
+	for i := range durations {
+		durations[i] = time.Duration(binary.BigEndian.Uint32(info[i*frameInfoSize+8:])) * time.Millisecond
+	}
+
+	return durations, nil
+}
+
+// SetDurations overwrites the frame durations of the caca canvas at the start
+// of data in place, such as a canvas exported by libcaca, which always
+// writes zero. durations must hold one valid duration for every frame, see
+// Validate(); otherwise data is left unchanged.
+func SetDurations(data []byte, durations []time.Duration) error {
+	info, frames, err := frameInfo(data)
+	if err != nil {
+		return err
+	}
+
+	if len(durations) != frames {
+		return fmt.Errorf("%w: %d durations for %d frames", ErrInvalid, len(durations), frames)
+	}
+
+	for i, d := range durations {
+		if d < 0 || d > MaxDuration {
+			return fmt.Errorf("%w: duration of frame %d out of range", ErrInvalid, i)
+		}
+	}
+
+	for i, d := range durations {
+		binary.BigEndian.PutUint32(info[i*frameInfoSize+8:], uint32(d/time.Millisecond))
+	}
+
+	return nil
+}
+
+// Read reads a single canvas from r. Only the bytes of the canvas are
+// consumed. If r ends before the canvas is complete, io.ErrUnexpectedEOF is
+// returned; if it is empty, io.EOF is returned.
+func Read(r io.Reader) (*Canvas, error) {
+	hdr := make([]byte, headerSize)
+
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+
+	n, err := Len(hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, n)
+	copy(data, hdr)
+
+	if _, err := io.ReadFull(r, data[headerSize:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	c, _, err := Decode(data)
+
+	return c, err
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
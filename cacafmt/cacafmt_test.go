@@ -0,0 +1,341 @@
+package cacafmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Offsets into an encoded canvas.
+const (
+	offControl = 4
+	offData    = 8
+	offFrames  = 14
+	offInfo    = 20
+)
+
+// testCanvas returns a canvas with every field of its frames set.
+func testCanvas() *Canvas {
+	c := &Canvas{Width: 3, Height: 2}
+
+	for i, name := range []string{"intro", "", "日本"} {
+		f := Frame{
+			Name:     name,
+			Duration: time.Duration(i*40) * time.Millisecond,
+			Attr:     0x01800500 + uint32(i),
+			CursorX:  i,
+			CursorY:  -i,
+			HandleX:  -1 - i,
+			HandleY:  2 * i,
+			Chars:    make([]rune, 6),
+			Attrs:    make([]uint32, 6),
+		}
+
+		for j := range f.Chars {
+			f.Chars[j] = rune('a' + i*6 + j)
+			f.Attrs[j] = uint32(i<<16 | j)
+		}
+
+		c.Frames = append(c.Frames, f)
+	}
+
+	return c
+}
+
+func encode(t *testing.T, c *Canvas) []byte {
+	t.Helper()
+
+	data, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range []*Canvas{
+		testCanvas(),
+		{Width: 0, Height: 0, Frames: []Frame{{Chars: []rune{}, Attrs: []uint32{}}}},
+		{Width: 1, Height: 1, Frames: []Frame{{Duration: MaxDuration, Chars: []rune{'x'}, Attrs: []uint32{0}}}},
+	} {
+		data := encode(t, c)
+
+		got, n, err := Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != len(data) {
+			t.Errorf("Decode() consumed %d bytes of %d", n, len(data))
+		}
+
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("Decode(Encode()) = %+v, want %+v", got, c)
+		}
+
+		var u Canvas
+		if err := u.UnmarshalBinary(data); err != nil || !reflect.DeepEqual(&u, c) {
+			t.Errorf("UnmarshalBinary() = %+v, %v, want %+v", u, err, c)
+		}
+	}
+}
+
+func TestEncodeUnnamed(t *testing.T) {
+	c := testCanvas()
+	for i := range c.Frames {
+		c.Frames[i].Name = ""
+	}
+
+	data := encode(t, c)
+
+	// Without names, the control section holds no extension.
+	if control := binary.BigEndian.Uint32(data[offControl:]); control != canvasHdrSize+frameInfoSize*3 {
+		t.Errorf("control size = %d, want %d", control, canvasHdrSize+frameInfoSize*3)
+	}
+
+	if !bytes.HasPrefix(data, magic) {
+		t.Errorf("data starts with % x", data[:4])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cell := func(f Frame) *Canvas {
+		if f.Chars == nil {
+			f.Chars, f.Attrs = []rune{' '}, []uint32{0}
+		}
+
+		return &Canvas{Width: 1, Height: 1, Frames: []Frame{f}}
+	}
+
+	tests := []struct {
+		name string
+		c    *Canvas
+		ok   bool
+	}{
+		{"valid", cell(Frame{}), true},
+		{"longest duration", cell(Frame{Duration: MaxDuration}), true},
+		{"longest name", cell(Frame{Name: strings.Repeat("n", 0xffff)}), true},
+		{"no frames", &Canvas{Width: 1, Height: 1}, false},
+		{"negative size", &Canvas{Width: -1, Height: 1, Frames: []Frame{{}}}, false},
+		{"missing cells", cell(Frame{Chars: []rune{}, Attrs: []uint32{0}}), false},
+		{"missing attrs", cell(Frame{Chars: []rune{' '}, Attrs: []uint32{}}), false},
+		{"name too long", cell(Frame{Name: strings.Repeat("n", 0x10000)}), false},
+		{"negative duration", cell(Frame{Duration: -time.Millisecond}), false},
+		{"duration too long", cell(Frame{Duration: MaxDuration + time.Millisecond}), false},
+	}
+
+	for _, tt := range tests {
+		err := tt.c.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+
+		if !tt.ok && !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalid", tt.name, err)
+		}
+
+		if _, err2 := tt.c.Encode(); (err2 == nil) != tt.ok {
+			t.Errorf("%s: Encode() = %v, Validate() = %v", tt.name, err2, err)
+		}
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	data := encode(t, testCanvas())
+
+	for n := 0; n < len(data); n++ {
+		if _, _, err := Decode(data[:n]); err != ErrShort {
+			t.Fatalf("Decode() of %d bytes of %d = %v, want ErrShort", n, len(data), err)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	put := func(off int, v uint32) func([]byte) {
+		return func(data []byte) { binary.BigEndian.PutUint32(data[off:], v) }
+	}
+
+	tests := []struct {
+		name   string
+		modify func([]byte)
+	}{
+		{"bad magic", func(data []byte) { data[2] = 'X' }},
+		{"control too small", put(offControl, canvasHdrSize+frameInfoSize-1)},
+		{"huge control", put(offControl, 0xffffffff)},
+		{"huge data", put(offData, 0xffffffff)},
+		{"no frames", put(offFrames, 0)},
+		{"too many frames", put(offFrames, 4)},
+		{"data size mismatch", put(offData, 3*6*cellSize-cellSize)},
+		{"frame size mismatch", put(offInfo+frameInfoSize, 2)},
+		{"duration too long", put(offInfo+8, 0x80000001)},
+	}
+
+	for _, tt := range tests {
+		data := encode(t, testCanvas())
+		tt.modify(data)
+
+		if _, _, err := Decode(data); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Decode() = %v, want ErrInvalid", tt.name, err)
+		}
+	}
+
+	data := encode(t, testCanvas())
+	binary.BigEndian.PutUint32(data[offInfo+8:], 0x80000000)
+
+	if _, _, err := Decode(data); err != nil {
+		t.Errorf("Decode() with the longest duration = %v", err)
+	}
+}
+
+func TestDecodeBadNames(t *testing.T) {
+	c := testCanvas()
+	data := encode(t, c)
+
+	// Cut the last name short: the extension is ignored as a whole.
+	ext := offInfo + frameInfoSize*len(c.Frames)
+	last := ext + len(nameTag) + 2 + len("intro") + 2
+	binary.BigEndian.PutUint16(data[last:], 0xff)
+
+	got, _, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, f := range got.Frames {
+		if f.Name != "" {
+			t.Errorf("frame %d has name %q", i, f.Name)
+		}
+	}
+}
+
+func TestUnmarshalTrailing(t *testing.T) {
+	data := append(encode(t, testCanvas()), 0)
+
+	var c Canvas
+	if err := c.UnmarshalBinary(data); !errors.Is(err, ErrInvalid) {
+		t.Errorf("UnmarshalBinary() = %v, want ErrInvalid", err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	a := testCanvas()
+	b := &Canvas{Width: 1, Height: 1, Frames: []Frame{{Chars: []rune{'b'}, Attrs: []uint32{1}}}}
+
+	var buf bytes.Buffer
+
+	for _, c := range []*Canvas{a, b} {
+		if _, err := c.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream := buf.Bytes()
+	r := bytes.NewReader(stream)
+
+	for _, want := range []*Canvas{a, b} {
+		got, err := Read(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Read() = %+v, want %+v", got, want)
+		}
+	}
+
+	if _, err := Read(r); err != io.EOF {
+		t.Errorf("Read() at the end = %v, want io.EOF", err)
+	}
+
+	// A truncated header or canvas, including the second one of the stream.
+	for _, n := range []int{headerSize - 1, headerSize + 1, len(stream) - 1} {
+		r := bytes.NewReader(stream[:n])
+
+		var err error
+		for err == nil {
+			_, err = Read(r)
+		}
+
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("Read() of %d bytes = %v, want io.ErrUnexpectedEOF", n, err)
+		}
+	}
+}
+
+func TestDurations(t *testing.T) {
+	c := testCanvas()
+	data := encode(t, c)
+
+	got, err := Durations(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Duration{0, 40 * time.Millisecond, 80 * time.Millisecond}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Durations() = %v, want %v", got, want)
+	}
+
+	if _, err := Durations(data[:len(data)-1]); err != ErrShort {
+		t.Errorf("Durations() of short data = %v, want ErrShort", err)
+	}
+
+	bad := append([]byte{}, data...)
+	binary.BigEndian.PutUint32(bad[offFrames:], 0)
+
+	if _, err := Durations(bad); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Durations() without frames = %v, want ErrInvalid", err)
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	c := testCanvas()
+	data := encode(t, c)
+
+	want := []time.Duration{MaxDuration, 0, 1500 * time.Millisecond}
+	if err := SetDurations(data, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing but the durations changed.
+	for i := range c.Frames {
+		c.Frames[i].Duration = want[i]
+	}
+
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("Decode() after SetDurations() = %+v, want %+v", got, c)
+	}
+
+	orig := append([]byte{}, data...)
+
+	for _, durations := range [][]time.Duration{
+		{0, 0},
+		{0, 0, 0, 0},
+		{0, -time.Millisecond, 0},
+		{0, 0, MaxDuration + time.Millisecond},
+	} {
+		if err := SetDurations(data, durations); !errors.Is(err, ErrInvalid) {
+			t.Errorf("SetDurations(%v) = %v, want ErrInvalid", durations, err)
+		}
+	}
+
+	// Durations are checked before anything is written.
+	if !bytes.Equal(data, orig) {
+		t.Error("rejected durations were written")
+	}
+
+	if err := SetDurations(data[:len(data)-1], want); err != ErrShort {
+		t.Errorf("SetDurations() on short data = %v, want ErrShort", err)
+	}
+}
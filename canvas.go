@@ -6,12 +6,64 @@ package caca
 import "C"
 
 import (
+	"os"
+	"runtime"
+	"time"
 	"unsafe"
 )
 
-// Canvas is a libcaca canvas.
+// Canvas is a handle to a libcaca canvas. Copies of a Canvas refer to the same
+// underlying canvas, so freeing any copy frees it for all of them. Using a
+// canvas after it was freed panics.
+//
+// Canvases created by CreateCanvas() are owned by the caller and are freed by
+// a finalizer if Free() is never called. Canvases created by a display are
+// owned by that display, see Display.GetCanvas().
 type Canvas struct {
-	Cv *C.struct_caca_canvas
+	h *canvasHandle
+}
+
+type canvasHandle struct {
+	cv    *C.struct_caca_canvas
+	owned bool
+
+	// frame is the index of the active frame. libcaca does not expose it,
+	// so the frame functions of Canvas keep track of it the same way
+	// libcaca updates it internally.
+	frame int
+
+	// durations holds the frame durations, see frameDurations().
+	durations []time.Duration
+}
+
+// newCanvas wraps a C canvas pointer in a Canvas handle. Owned canvases get a
+// finalizer that frees them once they become unreachable.
+func newCanvas(cPtr *C.struct_caca_canvas, owned bool) Canvas {
+	h := &canvasHandle{cv: cPtr, owned: owned}
+
+	if owned {
+		runtime.SetFinalizer(h, func(h *canvasHandle) {
+			if h.cv != nil {
+				C.caca_free_canvas(h.cv)
+			}
+		})
+	}
+
+	return Canvas{h: h}
+}
+
+// ptr returns the underlying C canvas and panics if the canvas was freed.
+func (cv Canvas) ptr() *C.struct_caca_canvas {
+	cv.mustLive()
+
+	return cv.h.cv
+}
+
+// mustLive panics if the canvas was freed or never initialised.
+func (cv Canvas) mustLive() {
+	if cv.h == nil || cv.h.cv == nil {
+		panic("caca: use of freed or uninitialised Canvas")
+	}
 }
 
 // CreateCanvas initialises internal libcaca structures and the backend that
@@ -27,10 +79,10 @@ func CreateCanvas(width int, height int) (Canvas, error) {
 	cPtr, err := C.caca_create_canvas(C.int(width), C.int(height))
 
 	if cPtr == nil {
-		return Canvas{}, err
+		return Canvas{}, wrapErr("CreateCanvas", err)
 	}
 
-	return Canvas{Cv: cPtr}, nil
+	return newCanvas(cPtr, true), nil
 }
 
 // SetSize sets the canvas' width and height, in character cells.
@@ -52,10 +104,12 @@ func CreateCanvas(width int, height int) (Canvas, error) {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) SetSize(width int, height int) error {
-	ret, err := C.caca_set_canvas_size(cv.Cv, C.int(width), C.int(height))
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_set_canvas_size(cv.ptr(), C.int(width), C.int(height))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.SetSize", err)
 	}
 
 	return nil
@@ -63,29 +117,39 @@ func (cv Canvas) SetSize(width int, height int) error {
 
 // GetWidth returns the canvas' width, in character cells.
 func (cv Canvas) GetWidth() int {
-	return int(C.caca_get_canvas_width(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_get_canvas_width(cv.ptr()))
 }
 
 // GetHeight returns the canvas' height, in character cells.
 func (cv Canvas) GetHeight() int {
-	return int(C.caca_get_canvas_height(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_get_canvas_height(cv.ptr()))
 }
 
 // GoToXY puts the cursor at the given coordinates. Functions making use of the
 // cursor will use the new values. Setting the cursor position outside the
 // canvas is legal but the cursor will not be shown.
 func (cv Canvas) GoToXY(x int, y int) {
-	C.caca_gotoxy(cv.Cv, C.int(x), C.int(y))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_gotoxy(cv.ptr(), C.int(x), C.int(y))
 }
 
 // WhereX retrieves the X coordinate of the cursor's position.
 func (cv Canvas) WhereX() int {
-	return int(C.caca_wherex(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_wherex(cv.ptr()))
 }
 
 // WhereY retrieves the Y coordinate of the cursor's position.
 func (cv Canvas) WhereY() int {
-	return int(C.caca_wherey(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_wherey(cv.ptr()))
 }
 
 // PutChar prints an ASCII or Unicode character at the given coordinates, using
@@ -103,7 +167,9 @@ func (cv Canvas) WhereY() int {
 // This function returns the width of the printed character. If it is a
 // fullwidth character, 2 is returned. Otherwise, 1 is returned.
 func (cv Canvas) PutChar(x int, y int, ch rune) int {
-	return int(C.caca_put_char(cv.Cv, C.int(x), C.int(y), C.uint32_t(ch)))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_put_char(cv.ptr(), C.int(x), C.int(y), C.uint32_t(ch)))
 }
 
 // GetChar gets the ASCII or Unicode value of the character at the given
@@ -117,7 +183,9 @@ func (cv Canvas) PutChar(x int, y int, ch rune) int {
 // is guaranteed not to be a valid Unicode character, and indicates that the
 // character at the left of the requested one is a fullwidth character.
 func (cv Canvas) GetChar(x int, y int) rune {
-	return rune(C.caca_get_char(cv.Cv, C.int(x), C.int(y)))
+	defer runtime.KeepAlive(cv.h)
+
+	return rune(C.caca_get_char(cv.ptr(), C.int(x), C.int(y)))
 }
 
 // PutStr prints an UTF-8 string at the given coordinates, using the default
@@ -132,28 +200,46 @@ func (cv Canvas) GetChar(x int, y int) rune {
 // the number of characters printed, because fullwidth characters account for
 // two cells.
 func (cv Canvas) PutStr(x int, y int, str string) int {
-	return int(C.caca_put_str(cv.Cv, C.int(x), C.int(y), C.CString(str)))
+	defer runtime.KeepAlive(cv.h)
+
+	cStr := cString(str)
+	defer cFree(unsafe.Pointer(cStr))
+
+	return int(C.caca_put_str(cv.ptr(), C.int(x), C.int(y), cStr))
 }
 
 // Clear clears the canvas using the current foreground and background colours.
 func (cv Canvas) Clear() {
-	C.caca_clear_canvas(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_clear_canvas(cv.ptr())
 }
 
 // SetHandle sets the canvas' handle. Blitting functions will use the handle
 // value to put the canvas at the proper coordinates.
 func (cv Canvas) SetHandle(x int, y int) {
-	C.caca_set_canvas_handle(cv.Cv, C.int(x), C.int(y))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_set_canvas_handle(cv.ptr(), C.int(x), C.int(y))
 }
 
 // GetHandleX retrieves the X coordinate of the canvas' handle.
 func (cv Canvas) GetHandleX() int {
-	return int(C.caca_get_canvas_handle_x(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_get_canvas_handle_x(cv.ptr()))
 }
 
 // GetHandleY retrieves the Y coordinate of the canvas' handle.
 func (cv Canvas) GetHandleY() int {
-	return int(C.caca_get_canvas_handle_y(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_get_canvas_handle_y(cv.ptr()))
+}
+
+// Handle returns the canvas' handle.
+func (cv Canvas) Handle() Point {
+	return Point{X: cv.GetHandleX(), Y: cv.GetHandleY()}
 }
 
 // Blit blits a canvas onto another one at the given coordinates. An optional
@@ -161,38 +247,66 @@ func (cv Canvas) GetHandleY() int {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) Blit(x int, y int, src Canvas, mask *Canvas) error {
+	defer runtime.KeepAlive(cv.h)
+	defer runtime.KeepAlive(src.h)
+	defer runtime.KeepAlive(mask)
+
 	var ret C.int
 
 	var err error
 
 	if mask == nil {
-		ret, err = C.caca_blit(cv.Cv, C.int(x), C.int(y), src.Cv, nil)
+		ret, err = C.caca_blit(cv.ptr(), C.int(x), C.int(y), src.ptr(), nil)
 	} else {
-		ret, err = C.caca_blit(cv.Cv, C.int(x), C.int(y), src.Cv, mask.Cv)
+		ret, err = C.caca_blit(cv.ptr(), C.int(x), C.int(y), src.ptr(), mask.ptr())
 	}
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.Blit", err)
 	}
 
 	return nil
 }
 
+// BlitAt blits a canvas onto another one at the given point, like Blit()
+// does.
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) BlitAt(p Point, src Canvas, mask *Canvas) error {
+	return cv.Blit(p.X, p.Y, src, mask)
+}
+
 // SetBoundaries sets new boundaries for a canvas. This function can be used to
 // crop a canvas, to expand it or for combinations of both actions. All frames
 // are affected by this function.
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) SetBoundaries(x int, y int, w int, h int) error {
-	ret, err := C.caca_set_canvas_boundaries(cv.Cv, C.int(x), C.int(y), C.int(w), C.int(h))
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_set_canvas_boundaries(cv.ptr(), C.int(x), C.int(y), C.int(w), C.int(h))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.SetBoundaries", err)
 	}
 
 	return nil
 }
 
+// Bounds returns the rectangle covered by the canvas, with its top-left corner
+// at the origin.
+func (cv Canvas) Bounds() Rect {
+	return Rect{Width: cv.GetWidth(), Height: cv.GetHeight()}
+}
+
+// SetBounds sets new boundaries for a canvas, like SetBoundaries() does. The
+// new canvas shows the area r of the old one.
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) SetBounds(r Rect) error {
+	return cv.SetBoundaries(r.X, r.Y, r.Width, r.Height)
+}
+
 // DisableDirtyRect disables dirty rectangle handling for all libcaca graphic
 // calls. This is handy when the calling application needs to do slow operations
 // within a known area. Just call AddDirtyRect() afterwards.
@@ -200,13 +314,17 @@ func (cv Canvas) SetBoundaries(x int, y int, w int, h int) error {
 // This function is recursive. Dirty rectangles are only reenabled when
 // EnableDirtyRect() is called as many times.
 func (cv Canvas) DisableDirtyRect() {
-	C.caca_disable_dirty_rect(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_disable_dirty_rect(cv.ptr())
 }
 
 // EnableDirtyRect enables dirty rectangles.
 // This function can only be called after DisableDirtyRect() was called.
 func (cv Canvas) EnableDirtyRect() {
-	C.caca_enable_dirty_rect(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_enable_dirty_rect(cv.ptr())
 }
 
 // GetDirtyRectCount gets the number of dirty rectangles in a canvas. Dirty
@@ -218,28 +336,62 @@ func (cv Canvas) EnableDirtyRect() {
 //
 // Dirty rectangles are guaranteed not to overlap.
 func (cv Canvas) GetDirtyRectCount() int {
-	return int(C.caca_get_dirty_rect_count(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_get_dirty_rect_count(cv.ptr()))
 }
 
-// GetDirtyRect gets the canvas's given dirty rectangle coordinates. The index
-// must be within the dirty rectangle count. See caca_get_dirty_rect_count() for
-// how to compute this count.
+// DirtyRect gets the canvas's given dirty rectangle. The index must be within
+// the dirty rectangle count, see GetDirtyRectCount().
 //
 // If an error occurs the according errno is returned.
-func (cv Canvas) GetDirtyRect(idx int) (map[string]int, error) {
+func (cv Canvas) DirtyRect(idx int) (Rect, error) {
+	defer runtime.KeepAlive(cv.h)
+
 	var x, y, width, height C.int
 
-	ret, err := C.caca_get_dirty_rect(cv.Cv, C.int(idx), &x, &y, &width, &height)
+	ret, err := C.caca_get_dirty_rect(cv.ptr(), C.int(idx), &x, &y, &width, &height)
 
 	if int(ret) == -1 {
+		return Rect{}, wrapErr("Canvas.DirtyRect", err)
+	}
+
+	return Rect{X: int(x), Y: int(y), Width: int(width), Height: int(height)}, nil
+}
+
+// DirtyRects returns all dirty rectangles of the canvas. See
+// GetDirtyRectCount() for more information about dirty rectangles.
+func (cv Canvas) DirtyRects() []Rect {
+	n := cv.GetDirtyRectCount()
+	rects := make([]Rect, 0, n)
+
+	for i := 0; i < n; i++ {
+		r, err := cv.DirtyRect(i)
+		if err != nil {
+			break
+		}
+
+		rects = append(rects, r)
+	}
+
+	return rects
+}
+
+// GetDirtyRect gets the canvas's given dirty rectangle coordinates as a map
+// with the keys "x", "y", "width" and "height".
+//
+// Deprecated: use DirtyRect() or DirtyRects() instead.
+func (cv Canvas) GetDirtyRect(idx int) (map[string]int, error) {
+	r, err := cv.DirtyRect(idx)
+	if err != nil {
 		return map[string]int{}, err
 	}
 
-	return map[string]int{"x": int(x), "y": int(y), "width": int(width), "height": int(height)}, nil
+	return map[string]int{"x": r.X, "y": r.Y, "width": r.Width, "height": r.Height}, nil
 }
 
 // AddDirtyRect adds an invalidating zone to the canvas's dirty rectangle list.
-// For more information about the dirty rectangles, see GetDirtyRect().
+// For more information about the dirty rectangles, see GetDirtyRectCount().
 //
 // This function may be useful to force refresh of a given zone of the canvas
 // even if the dirty rectangle tracking indicates that it is unchanged. This may
@@ -247,43 +399,67 @@ func (cv Canvas) GetDirtyRect(idx int) (map[string]int, error) {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) AddDirtyRect(x int, y int, width int, height int) error {
-	ret, err := C.caca_add_dirty_rect(cv.Cv, C.int(x), C.int(y), C.int(width), C.int(height))
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_add_dirty_rect(cv.ptr(), C.int(x), C.int(y), C.int(width), C.int(height))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.AddDirtyRect", err)
 	}
 
 	return nil
 }
 
+// AddDirty adds the rectangle r to the canvas's dirty rectangle list, like
+// AddDirtyRect() does.
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) AddDirty(r Rect) error {
+	return cv.AddDirtyRect(r.X, r.Y, r.Width, r.Height)
+}
+
 // RemovesDirtyRect removes an area from the dirty rectangle list.
 //
 // Mark a cell area in the canvas as not dirty. For more information about the
-// dirty rectangles, see GetDirtyRect().
+// dirty rectangles, see GetDirtyRectCount().
 //
 // Values such that xmin > xmax or ymin > ymax indicate that the dirty rectangle
 // is empty. They will be silently ignored.
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) RemoveDirtyRect(x int, y int, width int, height int) error {
-	ret, err := C.caca_remove_dirty_rect(cv.Cv, C.int(x), C.int(y), C.int(width), C.int(height))
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_remove_dirty_rect(cv.ptr(), C.int(x), C.int(y), C.int(width), C.int(height))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.RemoveDirtyRect", err)
 	}
 
 	return nil
 }
 
+// RemoveDirty removes the rectangle r from the canvas's dirty rectangle list,
+// like RemoveDirtyRect() does.
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) RemoveDirty(r Rect) error {
+	return cv.RemoveDirtyRect(r.X, r.Y, r.Width, r.Height)
+}
+
 // ClearDirtyRectList empties the canvas' dirty rectabgle list.
 func (cv Canvas) ClearDirtyRectList() {
-	C.caca_clear_dirty_rect_list(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_clear_dirty_rect_list(cv.ptr())
 }
 
 // Invert a canvas' colours (black becomes white, red becomes cyan, etc.)
 // without changing the characters in it.
 func (cv Canvas) Invert() {
-	C.caca_invert(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_invert(cv.ptr())
 }
 
 // Flip flips a canvas horizontally, choosing characters that look like the
@@ -291,7 +467,9 @@ func (cv Canvas) Invert() {
 // process, but the operation is guaranteed to be involutive: performing it
 // again gives back the original canvas.
 func (cv Canvas) Flip() {
-	C.caca_flip(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_flip(cv.ptr())
 }
 
 // Flop flips a canvas vertically, choosing characters that look like the mirrored
@@ -299,7 +477,9 @@ func (cv Canvas) Flip() {
 // but the operation is guaranteed to be involutive: performing it again gives
 // back the original canvas.
 func (cv Canvas) Flop() {
-	C.caca_flop(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_flop(cv.ptr())
 }
 
 // Rotate applies a 180-degree transformation to a canvas, choosing characters
@@ -307,7 +487,9 @@ func (cv Canvas) Flop() {
 // stay unchanged by the process, but the operation is guaranteed to be
 // involutive: performing it again gives back the original canvas.
 func (cv Canvas) Rotate180() {
-	C.caca_rotate_180(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_rotate_180(cv.ptr())
 }
 
 // RotateLeft rotates a canvas, 90 degrees counterclockwise.
@@ -325,10 +507,12 @@ func (cv Canvas) Rotate180() {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) RotateLeft() error {
-	ret, err := C.caca_rotate_left(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_rotate_left(cv.ptr())
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.RotateLeft", err)
 	}
 
 	return nil
@@ -349,10 +533,12 @@ func (cv Canvas) RotateLeft() error {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) RotateRight() error {
-	ret, err := C.caca_rotate_right(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_rotate_right(cv.ptr())
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.RotateRight", err)
 	}
 
 	return nil
@@ -371,10 +557,12 @@ func (cv Canvas) RotateRight() error {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) StretchLeft() error {
-	ret, err := C.caca_stretch_left(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_stretch_left(cv.ptr())
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.StretchLeft", err)
 	}
 
 	return nil
@@ -393,10 +581,12 @@ func (cv Canvas) StretchLeft() error {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) StretchRight() error {
-	ret, err := C.caca_stretch_right(cv.Cv)
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_stretch_right(cv.ptr())
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.StretchRight", err)
 	}
 
 	return nil
@@ -416,10 +606,14 @@ func (cv Canvas) StretchRight() error {
 //    3 bits for the foreground blue component
 //    4 bits for the bold, italics, underline and blink flags
 //
+// The Attr methods decode these fields, see the Attr documentation.
+//
 // If the coordinates are outside the canvas boundaries, the current attribute is
 // returned.
-func (cv Canvas) GetAttr(x int, y int) rune {
-	return rune(C.caca_get_attr(cv.Cv, C.int(x), C.int(y)))
+func (cv Canvas) GetAttr(x int, y int) Attr {
+	defer runtime.KeepAlive(cv.h)
+
+	return Attr(C.caca_get_attr(cv.ptr(), C.int(x), C.int(y)))
 }
 
 // SetAttr sets the default character attribute for drawing. Attributes define
@@ -436,8 +630,10 @@ func (cv Canvas) GetAttr(x int, y int) rune {
 //      modify the current colour information.
 //
 // To retrieve the current attribute value, use GetAttr(-1,-1).
-func (cv Canvas) SetAttr(attr rune) {
-	C.caca_set_attr(cv.Cv, C.uint32_t(attr))
+func (cv Canvas) SetAttr(attr Attr) {
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_set_attr(cv.ptr(), C.uint32_t(attr))
 }
 
 // UnsetAttr unsets flags in the default character attribute for drawing.
@@ -450,8 +646,10 @@ func (cv Canvas) SetAttr(attr rune) {
 // attributes does not modify the current colour information.
 //
 // To retrieve the current attribute value, use caca_get_attr(-1,-1).
-func (cv Canvas) UnsetAttr(attr rune) {
-	C.caca_unset_attr(cv.Cv, C.uint32_t(attr))
+func (cv Canvas) UnsetAttr(attr Attr) {
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_unset_attr(cv.ptr(), C.uint32_t(attr))
 }
 
 // ToggleAttr toggles flags in the default character attribute for drawing.
@@ -464,8 +662,10 @@ func (cv Canvas) UnsetAttr(attr rune) {
 // attributes does not modify the current colour information.
 //
 // To retrieve the current attribute value, use caca_get_attr(-1,-1).
-func (cv Canvas) ToggleAttr(attr rune) {
-	C.caca_toggle_attr(cv.Cv, C.uint32_t(attr))
+func (cv Canvas) ToggleAttr(attr Attr) {
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_toggle_attr(cv.ptr(), C.uint32_t(attr))
 }
 
 // PutAttr sets the character attribute, without changing the character's value.
@@ -479,8 +679,10 @@ func (cv Canvas) ToggleAttr(attr rune) {
 //     - a combination (bitwise OR) of style values (CACA_UNDERLINE, CACA_BLINK,
 //       CACA_BOLD and CACA_ITALICS), in which case setting the attribute does not
 //       modify the current colour information.
-func (cv Canvas) PutAttr(x int, y int, attr rune) {
-	C.caca_put_attr(cv.Cv, C.int(x), C.int(y), C.uint32_t(attr))
+func (cv Canvas) PutAttr(x int, y int, attr Attr) {
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_put_attr(cv.ptr(), C.int(x), C.int(y), C.uint32_t(attr))
 }
 
 // SetColorAnsi sets the default ANSI colour pair for text drawing. String
@@ -492,13 +694,15 @@ func (cv Canvas) PutAttr(x int, y int, attr rune) {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) SetColorAnsi(fg byte, bg byte) error {
-	ret, err := C.caca_set_color_ansi(cv.Cv, C.uint8_t(fg), C.uint8_t(bg))
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_set_color_ansi(cv.ptr(), C.uint8_t(fg), C.uint8_t(bg))
 
 	if int(ret) != -1 {
 		return nil
 	}
 
-	return err
+	return wrapErr("Canvas.SetColorAnsi", err)
 }
 
 // SetColorARGB sets the default ARGB colour pair for text drawing. String
@@ -509,113 +713,200 @@ func (cv Canvas) SetColorAnsi(fg byte, bg byte) error {
 // instance, 0xf088 is solid dark cyan (A=15 R=0 G=8 B=8), and 0x8fff is white
 // with 50% alpha (A=8 R=15 G=15 B=15).
 func (cv Canvas) SetColorARGB(fg int16, bg int16) {
-	C.caca_set_color_argb(cv.Cv, C.uint16_t(fg), C.uint16_t(bg))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_set_color_argb(cv.ptr(), C.uint16_t(fg), C.uint16_t(bg))
 }
 
 // DrawLine draws a line on the canvas using the given character.
 func (cv Canvas) DrawLine(x1 int, y1 int, x2 int, y2 int, ch rune) {
-	C.caca_draw_line(cv.Cv, C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.uint32_t(ch))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_line(cv.ptr(), C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.uint32_t(ch))
+}
+
+// DrawSegment draws a line from a to b on the canvas using the given
+// character.
+func (cv Canvas) DrawSegment(a Point, b Point, ch rune) {
+	cv.DrawLine(a.X, a.Y, b.X, b.Y, ch)
 }
 
 // DrawPolyline draws a polyline on the canvas using the given character and
-// coordinate slice. The first and last points are not connected, hence in order
-// to draw a polygon you need to specify the starting point at the end of the list
-// as well.
+// coordinate slice.
+//
+// Deprecated: use DrawPath() instead.
 func (cv Canvas) DrawPolyline(xy []IntPair, ch rune) {
-	l := len(xy)
-	cx := make([]C.int, 0, l)
-	cy := make([]C.int, 0, l)
+	cv.DrawPath(intPairsToPoints(xy), ch)
+}
+
+// DrawPath draws a polyline on the canvas through the given points using the
+// given character. The first and last points are not connected, hence in
+// order to draw a polygon you need to specify the starting point at the end of
+// the list as well.
+func (cv Canvas) DrawPath(pts []Point, ch rune) {
+	defer runtime.KeepAlive(cv.h)
 
-	for _, e := range xy {
-		cx = append(cx, C.int(e.First))
-		cy = append(cy, C.int(e.Second))
+	if len(pts) == 0 {
+		return
 	}
 
-	C.caca_draw_polyline(cv.Cv, &cx[0], &cy[0], C.int(len(xy)-1), C.uint32_t(ch))
+	cx, cy := pointsToC(pts)
+
+	C.caca_draw_polyline(cv.ptr(), &cx[0], &cy[0], C.int(len(pts)-1), C.uint32_t(ch))
 }
 
 // DrawThinLine draws a thin line on the canvas, using ASCII art.
 func (cv Canvas) DrawThinLine(x1 int, y1 int, x2 int, y2 int) {
-	C.caca_draw_thin_line(cv.Cv, C.int(x1), C.int(y1), C.int(x2), C.int(y2))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_thin_line(cv.ptr(), C.int(x1), C.int(y1), C.int(x2), C.int(y2))
 }
 
-// DrawThinPolyline draws a thin polyline on the canvas using the given coordinate
-// slice and with ASCII art. The first and last points are not connected, so in
-// order to draw a polygon you need to specify the starting point at the end of
-// the list as well.
+// DrawThinPolyline draws a thin polyline on the canvas using the given
+// coordinate slice and with ASCII art.
+//
+// Deprecated: use DrawThinPath() instead.
 func (cv Canvas) DrawThinPolyline(xy []IntPair) {
-	l := len(xy)
-	cx := make([]C.int, 0, l)
-	cy := make([]C.int, 0, l)
+	cv.DrawThinPath(intPairsToPoints(xy))
+}
+
+// DrawThinPath draws a thin polyline on the canvas through the given points
+// with ASCII art. The first and last points are not connected, so in order to
+// draw a polygon you need to specify the starting point at the end of the list
+// as well.
+func (cv Canvas) DrawThinPath(pts []Point) {
+	defer runtime.KeepAlive(cv.h)
+
+	if len(pts) == 0 {
+		return
+	}
+
+	cx, cy := pointsToC(pts)
 
-	for _, e := range xy {
-		cx = append(cx, C.int(e.First))
-		cy = append(cy, C.int(e.Second))
+	C.caca_draw_thin_polyline(cv.ptr(), &cx[0], &cy[0], C.int(len(pts)-1))
+}
+
+// pointsToC splits the points into the x and y coordinate arrays expected by
+// the libcaca polyline functions.
+func pointsToC(pts []Point) ([]C.int, []C.int) {
+	cx := make([]C.int, 0, len(pts))
+	cy := make([]C.int, 0, len(pts))
+
+	for _, p := range pts {
+		cx = append(cx, C.int(p.X))
+		cy = append(cy, C.int(p.Y))
 	}
 
-	C.caca_draw_thin_polyline(cv.Cv, &cx[0], &cy[0], C.int(len(xy)-1))
+	return cx, cy
 }
 
 // DrawCircle draws a circle on the canvas using the given character.
 func (cv Canvas) DrawCircle(x int, y int, r int, ch rune) {
-	C.caca_draw_circle(cv.Cv, C.int(x), C.int(y), C.int(r), C.uint32_t(ch))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_circle(cv.ptr(), C.int(x), C.int(y), C.int(r), C.uint32_t(ch))
 }
 
 // DrawEllipse draws an ellipse on the canvas using the given character.
 func (cv Canvas) DrawEllipse(xo int, yo int, a int, b int, ch rune) {
-	C.caca_draw_ellipse(cv.Cv, C.int(xo), C.int(yo), C.int(a), C.int(b), C.uint32_t(ch))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_ellipse(cv.ptr(), C.int(xo), C.int(yo), C.int(a), C.int(b), C.uint32_t(ch))
 }
 
 // DrawThinEllipse draws a thin ellipse on the canvas.
 func (cv Canvas) DrawThinEllipse(xo int, yo int, a int, b int) {
-	C.caca_draw_thin_ellipse(cv.Cv, C.int(xo), C.int(yo), C.int(a), C.int(b))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_thin_ellipse(cv.ptr(), C.int(xo), C.int(yo), C.int(a), C.int(b))
 }
 
 // FillEllipse fills an ellipse on the canvas using the given character.
 func (cv Canvas) FillEllipse(xo int, yo int, a int, b int, ch rune) {
-	C.caca_fill_ellipse(cv.Cv, C.int(xo), C.int(yo), C.int(a), C.int(b), C.uint32_t(ch))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_fill_ellipse(cv.ptr(), C.int(xo), C.int(yo), C.int(a), C.int(b), C.uint32_t(ch))
 }
 
 // DrawBox draws a box on the canvas using the given character.
 func (cv Canvas) DrawBox(x int, y int, w int, h int, ch rune) {
-	C.caca_draw_box(cv.Cv, C.int(x), C.int(y), C.int(w), C.int(h), C.uint32_t(ch))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_box(cv.ptr(), C.int(x), C.int(y), C.int(w), C.int(h), C.uint32_t(ch))
+}
+
+// DrawRect draws the outline of r on the canvas using the given character.
+func (cv Canvas) DrawRect(r Rect, ch rune) {
+	cv.DrawBox(r.X, r.Y, r.Width, r.Height, ch)
 }
 
 // DrawThinBox draws a thin box on the canvas.
 func (cv Canvas) DrawThinBox(x int, y int, w int, h int) {
-	C.caca_draw_thin_box(cv.Cv, C.int(x), C.int(y), C.int(w), C.int(h))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_thin_box(cv.ptr(), C.int(x), C.int(y), C.int(w), C.int(h))
+}
+
+// DrawThinRect draws the outline of r on the canvas with ASCII art.
+func (cv Canvas) DrawThinRect(r Rect) {
+	cv.DrawThinBox(r.X, r.Y, r.Width, r.Height)
 }
 
 // DrawCP437Box draws a box on the canvas using CP437 characters.
 func (cv Canvas) DrawCP437Box(x int, y int, w int, h int) {
-	C.caca_draw_cp437_box(cv.Cv, C.int(x), C.int(y), C.int(w), C.int(h))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_cp437_box(cv.ptr(), C.int(x), C.int(y), C.int(w), C.int(h))
+}
+
+// DrawCP437Rect draws the outline of r on the canvas using CP437 characters.
+func (cv Canvas) DrawCP437Rect(r Rect) {
+	cv.DrawCP437Box(r.X, r.Y, r.Width, r.Height)
 }
 
 // FillBox fills a box on the canvas using the given character.
 func (cv Canvas) FillBox(x int, y int, w int, h int, ch rune) {
-	C.caca_fill_box(cv.Cv, C.int(x), C.int(y), C.int(w), C.int(h), C.uint32_t(ch))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_fill_box(cv.ptr(), C.int(x), C.int(y), C.int(w), C.int(h), C.uint32_t(ch))
+}
+
+// FillRect fills r on the canvas using the given character.
+func (cv Canvas) FillRect(r Rect, ch rune) {
+	cv.FillBox(r.X, r.Y, r.Width, r.Height, ch)
 }
 
 // DrawTriangle draws a triangle on the canvas using the given character.
 func (cv Canvas) DrawTriangle(x1 int, y1 int, x2 int, y2 int, x3 int, y3 int, ch rune) {
-	C.caca_draw_triangle(cv.Cv, C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.int(x3), C.int(y3), C.uint32_t(ch))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_triangle(cv.ptr(), C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.int(x3), C.int(y3), C.uint32_t(ch))
 }
 
 // DrawThinTriangle draws a thin triangle on the canvas.
 func (cv Canvas) DrawThinTriangle(x1 int, y1 int, x2 int, y2 int, x3 int, y3 int) {
-	C.caca_draw_thin_triangle(cv.Cv, C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.int(x3), C.int(y3))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_draw_thin_triangle(cv.ptr(), C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.int(x3), C.int(y3))
 }
 
 // FillTriangle fills a triangle on the canvas using the given character.
 func (cv Canvas) FillTriangle(x1 int, y1 int, x2 int, y2 int, x3 int, y3 int, ch rune) {
-	C.caca_fill_triangle(cv.Cv, C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.int(x3), C.int(y3), C.uint32_t(ch))
+	defer runtime.KeepAlive(cv.h)
+
+	C.caca_fill_triangle(cv.ptr(), C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.int(x3), C.int(y3), C.uint32_t(ch))
 }
 
 // FillTriangleTextured fills a triangle on the canvas using an arbitrary-sized
 // texture.
 //
 // This function fails if one or both the canvas are missing.
-func (cv Canvas) FillTriangleTextured(coords [6]int, tex Canvas, uv [6]float64) int {
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) FillTriangleTextured(coords [6]int, tex Canvas, uv [6]float64) error {
+	defer runtime.KeepAlive(cv.h)
+	defer runtime.KeepAlive(tex.h)
+
 	var cCoords [6]C.int
 	for i, c := range coords {
 		cCoords[i] = C.int(c)
@@ -626,12 +917,20 @@ func (cv Canvas) FillTriangleTextured(coords [6]int, tex Canvas, uv [6]float64)
 		cUv[i] = C.float(u)
 	}
 
-	return int(C.caca_fill_triangle_textured(cv.Cv, &cCoords[0], tex.Cv, &cUv[0]))
+	ret, err := C.caca_fill_triangle_textured(cv.ptr(), &cCoords[0], tex.ptr(), &cUv[0])
+
+	if int(ret) == -1 {
+		return wrapErr("Canvas.FillTriangleTextured", err)
+	}
+
+	return nil
 }
 
 // GetFrameCount returns the current canvas' frame count.
 func (cv Canvas) GetFrameCount() int {
-	return int(C.caca_get_frame_count(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return int(C.caca_get_frame_count(cv.ptr()))
 }
 
 // SetFrame sets the active canvas frame. All subsequent drawing operations will
@@ -642,30 +941,52 @@ func (cv Canvas) GetFrameCount() int {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) SetFrame(id int) error {
-	ret, err := C.caca_set_frame(cv.Cv, C.int(id))
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_set_frame(cv.ptr(), C.int(id))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.SetFrame", err)
 	}
 
+	cv.h.frame = id
+
 	return nil
 }
 
+// syncFrame makes the frame tracked by the handle active again after an
+// import, which may create, delete and switch frames. If the import left
+// fewer frames, the last frame becomes active.
+func (cv Canvas) syncFrame() {
+	if count := int(C.caca_get_frame_count(cv.ptr())); cv.h.frame >= count {
+		cv.h.frame = count - 1
+	}
+
+	C.caca_set_frame(cv.ptr(), C.int(cv.h.frame))
+}
+
 // GetFrameName returns the current frame's name. The returned string is valid
 // until the frame is deleted or SetFrameName() is called to change the frame
 // name again.
 func (cv Canvas) GetFrameName() string {
-	return C.GoString(C.caca_get_frame_name(cv.Cv))
+	defer runtime.KeepAlive(cv.h)
+
+	return C.GoString(C.caca_get_frame_name(cv.ptr()))
 }
 
 // SetFrameName sets the current frame's name. Upon creation, a frame has a
 // default name of "frame#xxxxxxxx" where xxxxxxxx is a self-incrementing
 // hexadecimal number.
 func (cv Canvas) SetFrameName(name string) error {
-	ret, err := C.caca_set_frame_name(cv.Cv, C.CString(name))
+	defer runtime.KeepAlive(cv.h)
+
+	cName := cString(name)
+	defer cFree(unsafe.Pointer(cName))
+
+	ret, err := C.caca_set_frame_name(cv.ptr(), cName)
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.SetFrameName", err)
 	}
 
 	return nil
@@ -685,12 +1006,31 @@ func (cv Canvas) SetFrameName(name string) error {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) CreateFrame(id int) error {
-	ret, err := C.caca_create_frame(cv.Cv, C.int(id))
+	defer runtime.KeepAlive(cv.h)
+
+	durations := cv.frameDurations()
+	count := len(durations)
+	ret, err := C.caca_create_frame(cv.ptr(), C.int(id))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.CreateFrame", err)
+	}
+
+	if id < 0 {
+		id = 0
+	} else if id > count {
+		id = count
+	}
+
+	if cv.h.frame >= id {
+		cv.h.frame++
 	}
 
+	durations = append(durations, 0)
+	copy(durations[id+1:], durations[id:])
+	durations[id] = 0
+	cv.h.durations = durations
+
 	return nil
 }
 
@@ -706,10 +1046,25 @@ func (cv Canvas) CreateFrame(id int) error {
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) FreeFrame(id int) error {
-	ret, err := C.caca_free_frame(cv.Cv, C.int(id))
+	defer runtime.KeepAlive(cv.h)
+
+	durations := cv.frameDurations()
+	ret, err := C.caca_free_frame(cv.ptr(), C.int(id))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.FreeFrame", err)
+	}
+
+	if id >= len(durations) {
+		id = len(durations) - 1
+	}
+
+	cv.h.durations = append(durations[:id], durations[id+1:]...)
+
+	if cv.h.frame > id {
+		cv.h.frame--
+	} else if cv.h.frame == id {
+		cv.h.frame = 0
 	}
 
 	return nil
@@ -717,57 +1072,89 @@ func (cv Canvas) FreeFrame(id int) error {
 
 // ImportFromMemory imports a memory buffer into the given libcaca canvas's
 // current frame. The current frame is resized accordingly and its contents are
-// replaced with the imported data.
+// replaced with the imported data. Native libcaca data replaces all frames of
+// the canvas; afterwards the frame with the index that was active before the
+// import is active again, or the last frame if there are fewer frames.
 //
 // Valid values for format are:
 //
-//     "": attempt to autodetect the file format.
-//     "caca": import native libcaca files.
-//     "text": import ASCII text files.
-//     "ansi": import ANSI files.
-//     "utf8": import UTF-8 files with ANSI colour codes.
-//     "bin": import BIN files.
+//     FormatAuto: attempt to autodetect the file format.
+//     FormatCaca: import native libcaca files.
+//     FormatText: import ASCII text files.
+//     FormatANSI: import ANSI files.
+//     FormatUTF8: import UTF-8 files with ANSI colour codes.
+//     FormatBin: import BIN files.
+//
+// ImportFormats() returns the formats supported by the linked libcaca. Other
+// formats are rejected with ErrFormatUnsupported before libcaca is called.
 //
 // The number of bytes read is returned. If the file format is valid, but not
 // enough data was available, 0 is returned.
 //
 // If an error occurs -1 and the according errno is returned.
-func (cv Canvas) ImportFromMemory(data []byte, format string) (int, error) {
+func (cv Canvas) ImportFromMemory(data []byte, format Format) (int, error) {
+	return cv.importFromMemory("Canvas.ImportFromMemory", data, format)
+}
+
+// importFromMemory implements ImportFromMemory(), reporting errors for the
+// given operation.
+func (cv Canvas) importFromMemory(op string, data []byte, format Format) (int, error) {
+	defer runtime.KeepAlive(cv.h)
+
+	if err := checkImportFormat(op, format); err != nil {
+		return -1, err
+	}
+
+	cFormat := cString(string(format))
+	defer cFree(unsafe.Pointer(cFormat))
+
 	l := C.size_t(len(data))
-	ret, err := C.caca_import_canvas_from_memory(cv.Cv, unsafe.Pointer(&data[0]), l, C.CString(format))
+	ret, err := C.caca_import_canvas_from_memory(cv.ptr(), bytesPtr(data), l, cFormat)
 
 	if int(ret) == -1 {
-		return -1, err
+		return -1, wrapFormatErr(op, err)
 	}
 
+	cv.syncFrame()
+	cv.importDurations(data, format)
+
 	return int(ret), nil
 }
 
 // ImportFromFile imports a file into the given libcaca canvas's current frame.
 // The current frame is resized accordingly and its contents are replaced with
-// the imported data.
+// the imported data. Native libcaca files replace all frames of the canvas,
+// see ImportFromMemory() for which frame is active afterwards.
 //
 // Valid values for format are:
 //
-//     "": attempt to autodetect the file format.
-//     "caca": import native libcaca files.
-//     "text": import ASCII text files.
-//     "ansi": import ANSI files.
-//     "utf8": import UTF-8 files with ANSI colour codes.
-//     "bin": import BIN files.
+//     FormatAuto: attempt to autodetect the file format.
+//     FormatCaca: import native libcaca files.
+//     FormatText: import ASCII text files.
+//     FormatANSI: import ANSI files.
+//     FormatUTF8: import UTF-8 files with ANSI colour codes.
+//     FormatBin: import BIN files.
+//
+// ImportFormats() returns the formats supported by the linked libcaca. Other
+// formats are rejected with ErrFormatUnsupported before libcaca is called.
 //
 // The number of bytes read is returned. If the file format is valid, but not
 // enough data was available, 0 is returned.
 //
 // If an error occurs -1 and the according errno is returned.
-func (cv Canvas) ImportFromFile(filename string, format string) (int, error) {
-	ret, err := C.caca_import_canvas_from_file(cv.Cv, C.CString(filename), C.CString(format))
-
-	if int(ret) == -1 {
+func (cv Canvas) ImportFromFile(filename string, format Format) (int, error) {
+	if err := checkImportFormat("Canvas.ImportFromFile", format); err != nil {
 		return -1, err
 	}
 
-	return int(ret), nil
+	// The file is read once and imported from memory, so that the frame
+	// durations stored in it are taken from the same data.
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return -1, wrapErr("Canvas.ImportFromFile", err)
+	}
+
+	return cv.importFromMemory("Canvas.ImportFromFile", data, format)
 }
 
 // ImportAreaFromMemory imports a memory buffer into a canvas area.
@@ -779,17 +1166,34 @@ func (cv Canvas) ImportFromFile(filename string, format string) (int, error) {
 // enough data was available, 0 is returned.
 //
 // If an error occurs -1 and the according errno is returned.
-func (cv Canvas) ImportAreaFromMemory(x int, y int, data []byte, format string) (int, error) {
+func (cv Canvas) ImportAreaFromMemory(x int, y int, data []byte, format Format) (int, error) {
+	defer runtime.KeepAlive(cv.h)
+
+	if err := checkImportFormat("Canvas.ImportAreaFromMemory", format); err != nil {
+		return -1, err
+	}
+
+	cFormat := cString(string(format))
+	defer cFree(unsafe.Pointer(cFormat))
+
 	l := C.size_t(len(data))
-	ret, err := C.caca_import_area_from_memory(cv.Cv, C.int(x), C.int(y), unsafe.Pointer(&data[0]), l, C.CString(format))
+	ret, err := C.caca_import_area_from_memory(cv.ptr(), C.int(x), C.int(y), bytesPtr(data), l, cFormat)
 
 	if int(ret) == -1 {
-		return -1, err
+		return -1, wrapFormatErr("Canvas.ImportAreaFromMemory", err)
 	}
 
 	return int(ret), nil
 }
 
+// ImportAt imports a memory buffer into the canvas' current frame at the given
+// point, like ImportAreaFromMemory() does.
+//
+// If an error occurs -1 and the according errno is returned.
+func (cv Canvas) ImportAt(p Point, data []byte, format Format) (int, error) {
+	return cv.ImportAreaFromMemory(p.X, p.Y, data, format)
+}
+
 // ImportAreaFromFile imports a memory buffer into a canvas area.
 //
 // Import a file into the given libcaca canvas's current frame, at the
@@ -799,89 +1203,213 @@ func (cv Canvas) ImportAreaFromMemory(x int, y int, data []byte, format string)
 // enough data was available, 0 is returned.
 //
 // If an error occurs -1 and the according errno is returned.
-func (cv Canvas) ImportAreaFromFile(x int, y int, filename string, format string) (int, error) {
-	ret, err := C.caca_import_area_from_file(cv.Cv, C.int(x), C.int(y), C.CString(filename), C.CString(format))
+func (cv Canvas) ImportAreaFromFile(x int, y int, filename string, format Format) (int, error) {
+	defer runtime.KeepAlive(cv.h)
 
-	if int(ret) == -1 {
+	if err := checkImportFormat("Canvas.ImportAreaFromFile", format); err != nil {
 		return -1, err
 	}
 
+	cFilename := cString(filename)
+	defer cFree(unsafe.Pointer(cFilename))
+
+	cFormat := cString(string(format))
+	defer cFree(unsafe.Pointer(cFormat))
+
+	ret, err := C.caca_import_area_from_file(cv.ptr(), C.int(x), C.int(y), cFilename, cFormat)
+
+	if int(ret) == -1 {
+		return -1, wrapFormatErr("Canvas.ImportAreaFromFile", err)
+	}
+
 	return int(ret), nil
 }
 
+// ImportFileAt imports a file into the canvas' current frame at the given
+// point, like ImportAreaFromFile() does.
+//
+// If an error occurs -1 and the according errno is returned.
+func (cv Canvas) ImportFileAt(p Point, filename string, format Format) (int, error) {
+	return cv.ImportAreaFromFile(p.X, p.Y, filename, format)
+}
+
 // ExportToMemory This function exports a libcaca canvas into various foreign
-// formats such as ANSI art, HTML, IRC colours, etc. The returned pointer should
-// be passed to free() to release the allocated storage when it is no longer
-// needed.
+// formats such as ANSI art, HTML, IRC colours, etc. The exported data is
+// copied into a Go byte slice and the storage allocated by libcaca is released
+// before returning.
 //
 // Valid values for format are:
 //
-//     "caca": export native libcaca files.
-//     "ansi": export ANSI art (CP437 charset with ANSI colour codes).
-//     "html": export an HTML page with CSS information.
-//     "html3": export an HTML table that should be compatible with most
-//              navigators, including textmode ones.
-//     "irc": export UTF-8 text with mIRC colour codes.
-//     "ps": export a PostScript document.
-//     "svg": export an SVG vector image.
-//     "tga": export a TGA image.
-//     "troff": export a troff source.
+//     FormatCaca: export native libcaca files.
+//     FormatANSI: export ANSI art (CP437 charset with ANSI colour codes).
+//     FormatUTF8: export UTF-8 text with ANSI colour codes.
+//     FormatHTML: export an HTML page with CSS information.
+//     FormatHTML3: export an HTML table that should be compatible with most
+//                  navigators, including textmode ones.
+//     FormatIRC: export UTF-8 text with mIRC colour codes.
+//     FormatPS: export a PostScript document.
+//     FormatSVG: export an SVG vector image.
+//     FormatTGA: export a TGA image.
+//     FormatTroff: export a troff source.
+//
+// ExportFormats() returns the formats supported by the linked libcaca. Other
+// formats are rejected with ErrFormatUnsupported before libcaca is called.
 //
 // If an error occurs an empty byte slice and the according errno is returned.
-func (cv Canvas) ExportToMemory(format string) ([]byte, error) {
+func (cv Canvas) ExportToMemory(format Format) ([]byte, error) {
+	defer runtime.KeepAlive(cv.h)
+
+	if err := checkExportFormat("Canvas.ExportToMemory", format); err != nil {
+		return []byte{}, err
+	}
+
+	cFormat := cString(string(format))
+	defer cFree(unsafe.Pointer(cFormat))
+
 	var b C.size_t
 
-	ret, err := C.caca_export_canvas_to_memory(cv.Cv, C.CString(format), &b)
+	ret, err := C.caca_export_canvas_to_memory(cv.ptr(), cFormat, &b)
 
 	if ret == nil {
-		return []byte{}, err
+		return []byte{}, wrapFormatErr("Canvas.ExportToMemory", err)
+	}
+
+	defer cFree(cOwn(ret))
+
+	data := C.GoBytes(ret, C.int(b))
+
+	if format == FormatCaca {
+		if err := cv.exportDurations(data); err != nil {
+			return []byte{}, &Error{Op: "Canvas.ExportToMemory", Err: err}
+		}
 	}
 
-	return C.GoBytes(ret, C.int(b)), nil
+	return data, nil
 }
 
 // ExportAreaToMemory exports a portion of a libcaca canvas into various formats.
 // For more information, see ExportToMemory().
 //
 // If an error occurs an empty byte slice and the according errno is returned.
-func (cv Canvas) ExportAreaToMemory(x int, y int, w int, h int, format string) ([]byte, error) {
+func (cv Canvas) ExportAreaToMemory(x int, y int, w int, h int, format Format) ([]byte, error) {
+	defer runtime.KeepAlive(cv.h)
+
+	if err := checkExportFormat("Canvas.ExportAreaToMemory", format); err != nil {
+		return []byte{}, err
+	}
+
+	cFormat := cString(string(format))
+	defer cFree(unsafe.Pointer(cFormat))
+
 	var b C.size_t
 
-	ret, err := C.caca_export_area_to_memory(cv.Cv, C.int(x), C.int(y), C.int(w), C.int(h), C.CString(format), &b)
+	ret, err := C.caca_export_area_to_memory(cv.ptr(), C.int(x), C.int(y), C.int(w), C.int(h), cFormat, &b)
 
 	if ret == nil {
-		return []byte{}, err
+		return []byte{}, wrapFormatErr("Canvas.ExportAreaToMemory", err)
+	}
+
+	defer cFree(cOwn(ret))
+
+	data := C.GoBytes(ret, C.int(b))
+
+	if format == FormatCaca {
+		if err := cv.exportDurations(data); err != nil {
+			return []byte{}, &Error{Op: "Canvas.ExportAreaToMemory", Err: err}
+		}
 	}
 
-	return C.GoBytes(ret, C.int(b)), nil
+	return data, nil
 }
 
-// SetFigfont loads a figfont and attaches it to a canvas.
-func (cv Canvas) SetFigfont(filename string) int {
-	return int(C.caca_canvas_set_figfont(cv.Cv, C.CString(filename)))
+// ExportRect exports the area r of the canvas into various formats, like
+// ExportAreaToMemory() does.
+//
+// If an error occurs an empty byte slice and the according errno is returned.
+func (cv Canvas) ExportRect(r Rect, format Format) ([]byte, error) {
+	return cv.ExportAreaToMemory(r.X, r.Y, r.Width, r.Height, format)
+}
+
+// SetFigfont loads a figfont and attaches it to a canvas. To load a figfont
+// from an fs.FS, use SetFigfontFS().
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) SetFigfont(filename string) error {
+	defer runtime.KeepAlive(cv.h)
+
+	cFilename := cString(filename)
+	defer cFree(unsafe.Pointer(cFilename))
+
+	ret, err := C.caca_canvas_set_figfont(cv.ptr(), cFilename)
+
+	if int(ret) == -1 {
+		return wrapErr("Canvas.SetFigfont", err)
+	}
+
+	return nil
 }
 
 // PutFigchar pastes a character using the current figfont.
-func (cv Canvas) PutFigchar(ch rune) int {
-	return int(C.caca_put_figchar(cv.Cv, C.uint32_t(ch)))
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) PutFigchar(ch rune) error {
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_put_figchar(cv.ptr(), C.uint32_t(ch))
+
+	if int(ret) == -1 {
+		return wrapErr("Canvas.PutFigchar", err)
+	}
+
+	return nil
 }
 
 // FlushFiglet flushes the figlet context.
-func (cv Canvas) FlushFiglet() int {
-	return int(C.caca_flush_figlet(cv.Cv))
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) FlushFiglet() error {
+	defer runtime.KeepAlive(cv.h)
+
+	ret, err := C.caca_flush_figlet(cv.ptr())
+
+	if int(ret) == -1 {
+		return wrapErr("Canvas.FlushFiglet", err)
+	}
+
+	return nil
 }
 
-// Free frees all resources allocated by CreateCanvas(). The canvas pointer
-// becomes invalid and must no longer be used unless a new call to
-// CreateCanvas() is made.
+// Free frees all resources allocated by CreateCanvas(). The canvas and all of
+// its copies become invalid and must no longer be used. Calling Free() on an
+// already freed canvas does nothing.
+//
+// Canvases owned by a display cannot be freed, ErrNotOwned is returned
+// instead. If the canvas is still attached to a display, ErrDisplayAttached
+// is returned and the canvas stays valid.
 //
 // If an error occurs the according errno is returned.
 func (cv Canvas) Free() error {
-	ret, err := C.caca_free_canvas(cv.Cv)
+	if cv.h == nil || cv.h.cv == nil {
+		return nil
+	}
+
+	if !cv.h.owned {
+		return &Error{Op: "Canvas.Free", Err: ErrNotOwned}
+	}
+
+	ret, err := C.caca_free_canvas(cv.h.cv)
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Canvas.Free", err)
 	}
 
+	cv.h.cv = nil
+	runtime.SetFinalizer(cv.h, nil)
+
 	return nil
 }
+
+// Close frees the canvas like Free(). It makes Canvas satisfy io.Closer.
+func (cv Canvas) Close() error {
+	return cv.Free()
+}
@@ -0,0 +1,117 @@
+package caca
+
+import "testing"
+
+func TestCurrentFrameTracking(t *testing.T) {
+	cv, err := CreateCanvas(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	// Frames with duplicate names, which a lookup by name cannot tell apart.
+	for i := 1; i < 4; i++ {
+		if err := cv.CreateFrame(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := cv.SetFrame(i); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cv.SetFrameName("same"); err != nil {
+			t.Fatal(err)
+		}
+
+		cv.PutChar(0, 0, rune('a'+i))
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want int
+	}{
+		{"SetFrame(2)", func() error { return cv.SetFrame(2) }, 2},
+		{"CreateFrame before", func() error { return cv.CreateFrame(0) }, 3},
+		{"CreateFrame after", func() error { return cv.CreateFrame(10) }, 3},
+		{"FreeFrame before", func() error { return cv.FreeFrame(0) }, 2},
+		{"FreeFrame after", func() error { return cv.FreeFrame(3) }, 2},
+		{"FreeFrame active", func() error { return cv.FreeFrame(2) }, 0},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		if got := currentFrame(cv); got != step.want {
+			t.Fatalf("%s: current frame %d, want %d", step.name, got, step.want)
+		}
+	}
+
+	if err := cv.SetFrame(1); err != nil {
+		t.Fatal(err)
+	}
+
+	want := cv.GetChar(0, 0)
+
+	// Rendering and converting all frames must come back to the same frame.
+	cv.FrameDurations()
+
+	cv.ToNative()
+
+	if got := currentFrame(cv); got != 1 {
+		t.Errorf("current frame %d after visiting all frames, want 1", got)
+	}
+
+	if got := cv.GetChar(0, 0); got != want {
+		t.Errorf("active frame shows %q after visiting all frames, want %q", got, want)
+	}
+}
+
+func TestCurrentFrameAfterImport(t *testing.T) {
+	src, err := CreateCanvas(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Free()
+
+	if err := src.CreateFrame(1); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := src.ExportToMemory(FormatCaca)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cv, err := CreateCanvas(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	for i := 1; i < 4; i++ {
+		if err := cv.CreateFrame(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cv.SetFrame(3); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cv.ImportFromMemory(data, FormatCaca); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cv.GetFrameCount(); got != 2 {
+		t.Fatalf("%d frames after import, want 2", got)
+	}
+
+	if got := currentFrame(cv); got != 1 {
+		t.Errorf("current frame %d after import, want 1", got)
+	}
+}
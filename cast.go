@@ -0,0 +1,432 @@
+package caca
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// castVersion is the asciicast format version written and read by this
+// package.
+const castVersion = 2
+
+// castHeader is the first line of an asciicast v2 file.
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// CastOptions configures a CastRecorder.
+type CastOptions struct {
+	// Title is stored in the header of the recording.
+	Title string
+	// Colors selects the colour escape sequences of the recording. The zero
+	// value uses the 16 ANSI colours.
+	Colors TermColors
+}
+
+// CastRecorder records canvases into an asciinema asciicast v2 file. Every
+// recorded canvas is compared with the previous one and only the cells that
+// changed are written, as an output event of ANSI escape sequences.
+//
+// Use RecordCast() to record everything a Display shows, or call Record()
+// directly for canvases that are not displayed.
+type CastRecorder struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	start  time.Time
+	term   *termWriter
+	width  int
+	height int
+	chars  []rune
+	attrs  []Attr
+	remove func()
+	err    error
+}
+
+// NewCastRecorder writes the header of an asciicast recording with the given
+// terminal size to w and returns a recorder for its events. Event times are
+// measured from this call. opts may be nil to use the defaults.
+func NewCastRecorder(w io.Writer, width int, height int, opts *CastOptions) (*CastRecorder, error) {
+	if opts == nil {
+		opts = &CastOptions{}
+	}
+
+	if width <= 0 || height <= 0 {
+		return nil, &Error{Op: "NewCastRecorder", Err: ErrInvalidArgument}
+	}
+
+	rec := &CastRecorder{
+		w:      bufio.NewWriter(w),
+		start:  time.Now(),
+		term:   newTermWriter(opts.Colors),
+		width:  width,
+		height: height,
+	}
+
+	term := "xterm"
+	if opts.Colors != TermColors16 {
+		term = "xterm-256color"
+	}
+
+	hdr, err := json.Marshal(castHeader{
+		Version:   castVersion,
+		Width:     width,
+		Height:    height,
+		Timestamp: rec.start.Unix(),
+		Title:     opts.Title,
+		Env:       map[string]string{"TERM": term},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := rec.w.Write(hdr); err != nil {
+		return nil, err
+	}
+
+	if err := rec.w.WriteByte('\n'); err != nil {
+		return nil, err
+	}
+
+	if err := rec.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// RecordCast records everything the display shows into an asciicast file
+// written to w: every Refresh() adds an output event with the changes since
+// the previous refresh. The terminal size in the header is the size of the
+// display's canvas. Call Close() to stop recording.
+func RecordCast(dp Display, w io.Writer, opts *CastOptions) (*CastRecorder, error) {
+	cv := dp.GetCanvas()
+
+	rec, err := NewCastRecorder(w, cv.GetWidth(), cv.GetHeight(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rec.remove = dp.AddRefreshHook(func(cv Canvas) { _ = rec.Record(cv) })
+
+	return rec, nil
+}
+
+// event writes an event line.
+func (rec *CastRecorder) event(kind string, data string) error {
+	t := time.Since(rec.start).Seconds()
+
+	line, err := json.Marshal([]interface{}{math.Round(t*1e6) / 1e6, kind, data})
+	if err != nil {
+		return err
+	}
+
+	if _, err := rec.w.Write(line); err != nil {
+		return err
+	}
+
+	if err := rec.w.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	return rec.w.Flush()
+}
+
+// Record adds an output event with the changes of the canvas' current frame
+// since the previously recorded canvas. If the canvas size changed, a resize
+// event and a full redraw are written instead. Nothing is written if no cell
+// changed.
+//
+// The first error that occurs is returned by this and all following calls.
+func (rec *CastRecorder) Record(cv Canvas) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.err != nil {
+		return rec.err
+	}
+
+	width, height := cv.GetWidth(), cv.GetHeight()
+	chars, attrs := cv.GetChars(), cv.GetAttrs()
+	prevChars, prevAttrs := rec.chars, rec.attrs
+
+	if width != rec.width || height != rec.height {
+		rec.width, rec.height = width, height
+
+		if rec.err = rec.event("r", strconv.Itoa(width)+"x"+strconv.Itoa(height)); rec.err != nil {
+			return rec.err
+		}
+
+		prevChars, prevAttrs = nil, nil
+	}
+
+	if prevChars == nil {
+		rec.term.clear()
+	}
+
+	for y := 0; y < height; y++ {
+		var pc []rune
+
+		var pa []Attr
+
+		if prevChars != nil {
+			pc, pa = prevChars[y*width:(y+1)*width], prevAttrs[y*width:(y+1)*width]
+		}
+
+		rec.term.putRow(y, 0, width, chars[y*width:(y+1)*width], attrs[y*width:(y+1)*width], pc, pa)
+	}
+
+	rec.chars, rec.attrs = chars, attrs
+
+	if rec.term.buf.Len() == 0 {
+		return nil
+	}
+
+	rec.err = rec.event("o", rec.term.buf.String())
+	rec.term.buf.Reset()
+
+	return rec.err
+}
+
+// Close stops recording the display given to RecordCast() and returns the
+// first error that occurred while recording. It does not close the underlying
+// writer.
+func (rec *CastRecorder) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.remove != nil {
+		rec.remove()
+		rec.remove = nil
+	}
+
+	return rec.err
+}
+
+// CastEvent is an output or resize event of an asciicast recording.
+type CastEvent struct {
+	// Time is the time since the start of the recording.
+	Time time.Duration
+	// Data is the terminal output. It is empty for resize events.
+	Data string
+	// Width and Height are the new terminal size of a resize event. They are
+	// zero for output events.
+	Width, Height int
+}
+
+// ReadCast reads an asciicast v2 recording and returns the terminal size from
+// its header and its output and resize events. Events of other types, such as
+// input and markers, are skipped.
+func ReadCast(r io.Reader) (width int, height int, events []CastEvent, err error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64*1024*1024)
+
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return 0, 0, nil, err
+		}
+
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+
+	var hdr castHeader
+
+	if err := json.Unmarshal(sc.Bytes(), &hdr); err != nil {
+		return 0, 0, nil, &Error{Op: "ReadCast", Err: err}
+	}
+
+	if hdr.Version != castVersion || hdr.Width <= 0 || hdr.Height <= 0 {
+		return 0, 0, nil, &Error{Op: "ReadCast", Err: ErrFormatUnsupported}
+	}
+
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var ev []interface{}
+
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			return 0, 0, nil, &Error{Op: "ReadCast", Err: err}
+		}
+
+		if len(ev) != 3 {
+			return 0, 0, nil, &Error{Op: "ReadCast", Err: ErrFormatUnsupported}
+		}
+
+		t, ok1 := ev[0].(float64)
+		kind, ok2 := ev[1].(string)
+		data, ok3 := ev[2].(string)
+
+		if !ok1 || !ok2 || !ok3 {
+			return 0, 0, nil, &Error{Op: "ReadCast", Err: ErrFormatUnsupported}
+		}
+
+		event := CastEvent{Time: time.Duration(t * float64(time.Second))}
+
+		switch kind {
+		case "o":
+			event.Data = data
+		case "r":
+			w, h, ok := parseCastSize(data)
+			if !ok {
+				return 0, 0, nil, &Error{Op: "ReadCast", Err: ErrFormatUnsupported}
+			}
+
+			event.Width, event.Height = w, h
+		default:
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	if err := sc.Err(); err != nil {
+		return 0, 0, nil, err
+	}
+
+	return hdr.Width, hdr.Height, events, nil
+}
+
+// parseCastSize parses the "WIDTHxHEIGHT" data of a resize event.
+func parseCastSize(s string) (int, int, bool) {
+	i := strings.IndexByte(s, 'x')
+	if i == -1 {
+		return 0, 0, false
+	}
+
+	w, err1 := strconv.Atoi(s[:i])
+	h, err2 := strconv.Atoi(s[i+1:])
+
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+
+	return w, h, true
+}
+
+// ImportCast reads an asciicast v2 recording and replays its output through a
+// small VT100 terminal emulator. The returned canvas has one frame per output
+// event, holding the screen after that event. Every frame's duration, see
+// SetFrameDuration(), is the time until the next output event, so the canvas
+// can be played with an Animator or exported with ExportGIF().
+//
+// Resize events resize the emulated terminal, keeping the top-left part of
+// the screen like a terminal does. As all frames of a canvas have the same
+// size, the canvas has the largest width and height the terminal had; frames
+// drawn while the terminal was smaller are padded with blank cells.
+//
+// Only the common cursor movement, erase and SGR sequences are understood;
+// other sequences are ignored.
+func ImportCast(r io.Reader) (Canvas, error) {
+	width, height, events, err := ReadCast(r)
+	if err != nil {
+		return Canvas{}, err
+	}
+
+	// Resize events are kept separate from the output events, which
+	// become the frames.
+	var output []CastEvent
+
+	cvWidth, cvHeight := width, height
+
+	for _, ev := range events {
+		if ev.Width == 0 {
+			output = append(output, ev)
+		} else {
+			cvWidth, cvHeight = maxInt(cvWidth, ev.Width), maxInt(cvHeight, ev.Height)
+		}
+	}
+
+	if len(output) == 0 {
+		return Canvas{}, &Error{Op: "ImportCast", Err: ErrFormatUnsupported}
+	}
+
+	cv, err := CreateCanvas(cvWidth, cvHeight)
+	if err != nil {
+		return Canvas{}, err
+	}
+
+	vt := newVTScreen(width, height)
+	i := 0
+
+	for _, ev := range events {
+		if ev.Width != 0 {
+			vt.resize(ev.Width, ev.Height)
+
+			continue
+		}
+
+		vt.write(ev.Data)
+
+		if err := cv.addCastFrame(i, vt, output, ev); err != nil {
+			_ = cv.Free()
+
+			return Canvas{}, err
+		}
+
+		i++
+	}
+
+	if err := cv.SetFrame(0); err != nil {
+		_ = cv.Free()
+
+		return Canvas{}, err
+	}
+
+	return cv, nil
+}
+
+// addCastFrame stores the screen as frame i of the canvas.
+func (cv Canvas) addCastFrame(i int, vt *vtScreen, events []CastEvent, ev CastEvent) error {
+	if i > 0 {
+		if err := cv.CreateFrame(i); err != nil {
+			return err
+		}
+	}
+
+	if err := cv.SetFrame(i); err != nil {
+		return err
+	}
+
+	chars, attrs := vt.chars, vt.attrs
+	width, height := cv.GetWidth(), cv.GetHeight()
+
+	if vt.width != width || vt.height != height {
+		chars, attrs = make([]rune, width*height), make([]Attr, width*height)
+		blank := NewAttrAnsi(ColorDefault, ColorDefault, 0)
+
+		for j := range chars {
+			chars[j], attrs[j] = ' ', blank
+		}
+
+		for y := 0; y < vt.height; y++ {
+			copy(chars[y*width:], vt.chars[y*vt.width:(y+1)*vt.width])
+			copy(attrs[y*width:], vt.attrs[y*vt.width:(y+1)*vt.width])
+		}
+	}
+
+	if err := cv.SetCells(0, 0, width, height, chars, attrs); err != nil {
+		return err
+	}
+
+	if i+1 < len(events) {
+		if d := events[i+1].Time - ev.Time; d > 0 {
+			if err := cv.SetFrameDuration(d); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
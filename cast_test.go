@@ -0,0 +1,227 @@
+package caca
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCast(t *testing.T) {
+	input := `{"version": 2, "width": 10, "height": 4}
+[0.5, "o", "hi"]
+[0.75, "i", "x"]
+
+[1.0, "r", "20x5"]
+[1.5, "m", "marker"]
+[2, "o", "there"]
+`
+
+	width, height, events, err := ReadCast(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if width != 10 || height != 4 {
+		t.Errorf("size %dx%d, want 10x4", width, height)
+	}
+
+	want := []CastEvent{
+		{Time: 500 * time.Millisecond, Data: "hi"},
+		{Time: time.Second, Width: 20, Height: 5},
+		{Time: 2 * time.Second, Data: "there"},
+	}
+
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events %+v, want %+v", events, want)
+	}
+}
+
+func TestReadCastInvalid(t *testing.T) {
+	const hdr = `{"version": 2, "width": 10, "height": 4}` + "\n"
+
+	for _, input := range []string{
+		`{"version": 1, "width": 10, "height": 4}`,
+		`{"version": 2, "width": 0, "height": 4}`,
+		hdr + `[1.0, "o"]`,
+		hdr + `["1.0", "o", "x"]`,
+		hdr + `[1.0, "r", "20by5"]`,
+		hdr + `[1.0, "r", "0x5"]`,
+		hdr + `[1.0, "r", "20x-5"]`,
+	} {
+		if _, _, _, err := ReadCast(strings.NewReader(input)); !errors.Is(err, ErrFormatUnsupported) {
+			t.Errorf("ReadCast(%q) = %v, want ErrFormatUnsupported", input, err)
+		}
+	}
+}
+
+// castScreen is a canvas as recorded by a CastRecorder.
+type castScreen struct {
+	width int
+	chars []rune
+	attrs []Attr
+}
+
+// checkCastFrame compares the current frame of the canvas with the screen,
+// which is padded with blank cells to the canvas size.
+func checkCastFrame(t *testing.T, i int, cv Canvas, s castScreen) {
+	t.Helper()
+
+	blank := NewAttrAnsi(ColorDefault, ColorDefault, 0)
+	chars, attrs := cv.GetChars(), cv.GetAttrs()
+	width := cv.GetWidth()
+
+	for j := range chars {
+		x, y := j%width, j/width
+		wantCh, wantAttr := ' ', blank
+
+		if x < s.width && y < len(s.chars)/s.width {
+			wantCh, wantAttr = s.chars[y*s.width+x], s.attrs[y*s.width+x]
+		}
+
+		if chars[j] != wantCh || attrs[j] != wantAttr {
+			t.Errorf("frame %d: cell (%d, %d) = %q %#x, want %q %#x", i, x, y, chars[j], attrs[j], wantCh, wantAttr)
+		}
+	}
+}
+
+func TestCastRoundTrip(t *testing.T) {
+	for _, colors := range []TermColors{TermColors16, TermColorsTrue} {
+		cv, err := CreateCanvas(4, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+
+		rec, err := NewCastRecorder(&buf, 4, 2, &CastOptions{Colors: colors})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var screens []castScreen
+
+		record := func() {
+			t.Helper()
+
+			if err := rec.Record(cv); err != nil {
+				t.Fatal(err)
+			}
+
+			screens = append(screens, castScreen{cv.GetWidth(), cv.GetChars(), cv.GetAttrs()})
+		}
+
+		def := NewAttrAnsi(ColorDefault, ColorDefault, 0)
+
+		cv.SetAttr(def)
+		cv.Clear()
+		cv.PutStr(0, 0, "ab")
+		record()
+
+		cv.SetAttr(NewAttrAnsi(ColorRed, ColorLightblue, StyleBold|StyleUnderline))
+		cv.PutStr(1, 1, "日")
+		record()
+
+		if colors == TermColorsTrue {
+			// Only even blue values survive the 14-bit attribute colours.
+			cv.SetAttr(NewAttrARGB(0xf0a4, 0xf128, StyleItalics))
+		} else {
+			cv.SetAttr(NewAttrAnsi(ColorYellow, ColorBlack, StyleItalics))
+		}
+
+		cv.PutChar(3, 0, 'z')
+		record()
+
+		// An unchanged canvas adds no event, so no frame.
+		if err := rec.Record(cv); err != nil {
+			t.Fatal(err)
+		}
+
+		cv.SetAttr(def)
+
+		if err := cv.SetSize(6, 3); err != nil {
+			t.Fatal(err)
+		}
+
+		cv.PutStr(0, 2, "resize")
+		record()
+
+		if err := rec.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		_ = cv.Free()
+
+		imported, err := ImportCast(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if imported.GetWidth() != 6 || imported.GetHeight() != 3 {
+			t.Errorf("imported canvas is %dx%d, want 6x3", imported.GetWidth(), imported.GetHeight())
+		}
+
+		if n := imported.GetFrameCount(); n != len(screens) {
+			t.Fatalf("imported %d frames, want %d", n, len(screens))
+		}
+
+		for i, s := range screens {
+			if err := imported.SetFrame(i); err != nil {
+				t.Fatal(err)
+			}
+
+			checkCastFrame(t, i, imported, s)
+		}
+
+		_ = imported.Free()
+	}
+}
+
+func TestImportCastResize(t *testing.T) {
+	input := `{"version": 2, "width": 4, "height": 2}
+[0, "o", "abcd"]
+[1, "r", "2x1"]
+[2, "o", "\rX"]
+`
+
+	cv, err := ImportCast(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	screens := []castScreen{
+		{4, []rune("abcd    "), nil},
+		{2, []rune("Xb"), nil},
+	}
+
+	durations := []time.Duration{2 * time.Second, 0}
+
+	for i, s := range screens {
+		s.attrs = make([]Attr, len(s.chars))
+		for j := range s.attrs {
+			s.attrs[j] = NewAttrAnsi(ColorDefault, ColorDefault, 0)
+		}
+
+		if err := cv.SetFrame(i); err != nil {
+			t.Fatal(err)
+		}
+
+		checkCastFrame(t, i, cv, s)
+
+		if d := cv.GetFrameDuration(); d != durations[i] {
+			t.Errorf("frame %d lasts %v, want %v", i, d, durations[i])
+		}
+	}
+
+	// A recording with no output has no frame to import.
+	input = `{"version": 2, "width": 4, "height": 2}
+[1, "r", "2x1"]
+`
+
+	if _, err := ImportCast(strings.NewReader(input)); !errors.Is(err, ErrFormatUnsupported) {
+		t.Errorf("ImportCast() without output = %v, want ErrFormatUnsupported", err)
+	}
+}
@@ -0,0 +1,205 @@
+// Package catest provides a headless harness for testing programs built on
+// libcaca. It creates displays on libcaca's "null" or "raw" driver, so no
+// terminal is needed, lets tests push synthetic key, mouse and resize events
+// into the queue read by Display.GetEvent(), captures every Display.Refresh()
+// as a snapshot and compares canvases against golden files.
+//
+// A typical test looks like this:
+//
+//     h := catest.New(t, 40, 10)
+//     h.Type("hello")
+//     h.Key(caca.KeyReturn)
+//
+//     runApp(h.Display)
+//
+//     catest.AssertGolden(t, "hello", h.Last(), caca.FormatUTF8)
+package catest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/czwinzscher/libcaca-go"
+)
+
+// DefaultDriver is the driver used by New(). The "null" driver draws nothing
+// and never produces events of its own.
+const DefaultDriver = "null"
+
+// Harness is a display on a headless driver together with the snapshots of
+// its refreshes.
+type Harness struct {
+	// Display is the headless display. Pass it to the code under test.
+	Display caca.Display
+	// Canvas is the canvas attached to Display.
+	Canvas caca.Canvas
+
+	tb        testing.TB
+	mu        sync.Mutex
+	snaps     []Snapshot
+	refreshes int
+	raw       *rawCapture
+	remove    func()
+}
+
+// New creates a harness with a width×height canvas on the "null" driver. The
+// display and canvas are freed when the test finishes.
+func New(tb testing.TB, width int, height int) *Harness {
+	tb.Helper()
+
+	return NewWithDriver(tb, width, height, DefaultDriver)
+}
+
+// NewWithDriver creates a harness like New() does, but on the given driver.
+// The test is skipped if the linked libcaca lacks the driver.
+//
+// The "raw" driver writes every refresh to the standard output in libcaca's
+// "caca" format. While such a harness exists, file descriptor 1 of the process
+// is redirected into a pipe that is decoded with caca.RawDecoder, see
+// RawFrames(); os.Stdout keeps writing to the original output. Since the
+// redirection affects the whole process, tests using the "raw" driver must
+// not run in parallel with each other.
+func NewWithDriver(tb testing.TB, width int, height int, driver string) *Harness {
+	tb.Helper()
+
+	if !caca.HasDriver(driver) {
+		tb.Skipf("catest: libcaca has no %q driver", driver)
+	}
+
+	cv, err := caca.CreateCanvas(width, height)
+	if err != nil {
+		tb.Fatalf("catest: creating canvas: %v", err)
+	}
+
+	var raw *rawCapture
+
+	if driver == "raw" {
+		if raw, err = startRawCapture(); err != nil {
+			_ = cv.Free()
+
+			tb.Fatalf("catest: redirecting the standard output: %v", err)
+		}
+	}
+
+	dp, err := caca.CreateDisplayWithDriver(&cv, driver)
+	if err != nil {
+		if raw != nil {
+			raw.stop()
+		}
+
+		_ = cv.Free()
+
+		tb.Fatalf("catest: creating %q display: %v", driver, err)
+	}
+
+	h := &Harness{Display: dp, Canvas: cv, tb: tb, raw: raw}
+	h.remove = dp.AddRefreshHook(h.capture)
+
+	tb.Cleanup(func() {
+		h.remove()
+		dp.Free()
+
+		if raw != nil {
+			raw.stop()
+		}
+
+		if err := cv.Free(); err != nil {
+			tb.Errorf("catest: freeing canvas: %v", err)
+		}
+	})
+
+	return h
+}
+
+// push pushes the event and fails the test on error.
+func (h *Harness) push(data caca.EventData) {
+	h.tb.Helper()
+
+	if err := h.Display.PushEvent(data); err != nil {
+		h.tb.Fatalf("catest: pushing event: %v", err)
+	}
+}
+
+// KeyPress pushes a key press event. ch is either a character or one of the
+// caca.Key* constants.
+func (h *Harness) KeyPress(ch int) {
+	h.tb.Helper()
+	h.push(keyEvent(caca.EventKeyPress, ch))
+}
+
+// KeyRelease pushes a key release event. ch is either a character or one of
+// the caca.Key* constants.
+func (h *Harness) KeyRelease(ch int) {
+	h.tb.Helper()
+	h.push(keyEvent(caca.EventKeyRelease, ch))
+}
+
+// Key pushes a key press followed by a key release.
+func (h *Harness) Key(ch int) {
+	h.tb.Helper()
+	h.KeyPress(ch)
+	h.KeyRelease(ch)
+}
+
+// Type pushes a key press and release for every character of s.
+func (h *Harness) Type(s string) {
+	h.tb.Helper()
+
+	for _, ch := range s {
+		h.Key(int(ch))
+	}
+}
+
+// keyEvent returns a key event like libcaca reports it: special keys only
+// have a key code, characters also have their UTF-32 and UTF-8 values.
+func keyEvent(typ int, ch int) caca.EventData {
+	data := caca.EventData{Type: typ, KeyCh: ch}
+
+	if ch >= 0x20 && ch != caca.KeyDelete && (ch < caca.KeyUp || ch > caca.KeyF15) {
+		data.KeyUTF32 = rune(ch)
+		data.KeyUTF8 = string(rune(ch))
+	}
+
+	return data
+}
+
+// MouseMove pushes a mouse motion event to the given cell.
+func (h *Harness) MouseMove(x int, y int) {
+	h.tb.Helper()
+	h.push(caca.EventData{Type: caca.EventMouseMotion, MouseX: x, MouseY: y})
+}
+
+// MousePress pushes a mouse button press event. Buttons are numbered from 1.
+func (h *Harness) MousePress(button int) {
+	h.tb.Helper()
+	h.push(caca.EventData{Type: caca.EventMousePress, MouseButton: button})
+}
+
+// MouseRelease pushes a mouse button release event.
+func (h *Harness) MouseRelease(button int) {
+	h.tb.Helper()
+	h.push(caca.EventData{Type: caca.EventMouseRelease, MouseButton: button})
+}
+
+// Click pushes a mouse motion to the given cell followed by a press and a
+// release of the button.
+func (h *Harness) Click(x int, y int, button int) {
+	h.tb.Helper()
+	h.MouseMove(x, y)
+	h.MousePress(button)
+	h.MouseRelease(button)
+}
+
+// Resize pushes a resize event. The canvas itself is not resized, since
+// libcaca refuses to resize a canvas attached to a display; code reacting to
+// the event should only rely on the reported size.
+func (h *Harness) Resize(width int, height int) {
+	h.tb.Helper()
+	h.push(caca.EventData{Type: caca.EventResize, ResizeWidth: width, ResizeHeight: height})
+}
+
+// Quit pushes a quit event.
+func (h *Harness) Quit() {
+	h.tb.Helper()
+	h.push(caca.EventData{Type: caca.EventQuit})
+}
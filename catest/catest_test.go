@@ -0,0 +1,305 @@
+package catest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/czwinzscher/libcaca-go"
+)
+
+// nextEvent returns the next queued event matching mask without waiting.
+func nextEvent(t *testing.T, h *Harness, mask int) (caca.EventData, bool) {
+	t.Helper()
+
+	return h.Display.GetEvent(mask, nil, 0)
+}
+
+func TestEventOrder(t *testing.T) {
+	h := New(t, 10, 2)
+
+	h.Type("ab")
+	h.Key(caca.KeyReturn)
+	h.Click(3, 1, 1)
+	h.Resize(20, 4)
+	h.Quit()
+
+	want := []caca.EventData{
+		{Type: caca.EventKeyPress, KeyCh: 'a', KeyUTF32: 'a', KeyUTF8: "a"},
+		{Type: caca.EventKeyRelease, KeyCh: 'a', KeyUTF32: 'a', KeyUTF8: "a"},
+		{Type: caca.EventKeyPress, KeyCh: 'b', KeyUTF32: 'b', KeyUTF8: "b"},
+		{Type: caca.EventKeyRelease, KeyCh: 'b', KeyUTF32: 'b', KeyUTF8: "b"},
+		{Type: caca.EventKeyPress, KeyCh: caca.KeyReturn},
+		{Type: caca.EventKeyRelease, KeyCh: caca.KeyReturn},
+		{Type: caca.EventMouseMotion, MouseX: 3, MouseY: 1},
+		{Type: caca.EventMousePress, MouseButton: 1},
+		{Type: caca.EventMouseRelease, MouseButton: 1},
+		{Type: caca.EventResize, ResizeWidth: 20, ResizeHeight: 4},
+		{Type: caca.EventQuit},
+	}
+
+	for i, w := range want {
+		got, ok := nextEvent(t, h, caca.EventAny)
+		if !ok {
+			t.Fatalf("event %d: queue is empty, want %+v", i, w)
+		}
+
+		if got != w {
+			t.Fatalf("event %d: got %+v, want %+v", i, got, w)
+		}
+	}
+
+	if got, ok := nextEvent(t, h, caca.EventAny); ok {
+		t.Errorf("unexpected event %+v after the pushed ones", got)
+	}
+}
+
+func TestEventMask(t *testing.T) {
+	h := New(t, 10, 2)
+
+	h.KeyPress('x')
+	h.MouseMove(1, 1)
+	h.KeyRelease('x')
+
+	// Events not matching the mask stay queued in their order.
+	if got, ok := nextEvent(t, h, caca.EventMouseMotion); !ok || got.Type != caca.EventMouseMotion {
+		t.Fatalf("mouse motion mask: got %+v, %v", got, ok)
+	}
+
+	if got, ok := nextEvent(t, h, caca.EventMouseMotion); ok {
+		t.Fatalf("mouse motion mask: got a second event %+v", got)
+	}
+
+	if got, ok := nextEvent(t, h, caca.EventKeyRelease); !ok || got.Type != caca.EventKeyRelease {
+		t.Fatalf("key release mask: got %+v, %v", got, ok)
+	}
+
+	if got, ok := nextEvent(t, h, caca.EventKeyPress|caca.EventKeyRelease); !ok || got.Type != caca.EventKeyPress {
+		t.Fatalf("key mask: got %+v, %v", got, ok)
+	}
+
+	ev := caca.NewEvent()
+	defer ev.Free()
+
+	h.KeyPress('y')
+
+	if _, ok := h.Display.GetEvent(caca.EventKeyPress, &ev, 0); !ok {
+		t.Fatal("pushed event not returned through an allocated Event")
+	}
+
+	if ev.GetType() != caca.EventKeyPress || ev.GetKeyCh() != 'y' || ev.GetKeyUTF8() != "y" {
+		t.Errorf("Event filled with type %d, key %d, UTF-8 %q", ev.GetType(), ev.GetKeyCh(), ev.GetKeyUTF8())
+	}
+}
+
+func TestPushEventRejectsInvalidType(t *testing.T) {
+	h := New(t, 10, 2)
+
+	for _, typ := range []int{caca.EventNone, caca.EventKeyPress | caca.EventKeyRelease, caca.EventAny} {
+		if err := h.Display.PushEvent(caca.EventData{Type: typ}); err == nil {
+			t.Errorf("event type %#x accepted", typ)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	h := New(t, 5, 2)
+
+	if got := len(h.Snapshots()); got != 0 {
+		t.Fatalf("%d snapshots before the first refresh", got)
+	}
+
+	h.Canvas.PutStr(0, 0, "hi")
+	h.Display.Refresh()
+
+	h.Canvas.PutStr(0, 1, "there")
+	h.Display.Refresh()
+
+	snaps := h.Snapshots()
+	if len(snaps) != 2 {
+		t.Fatalf("%d snapshots after two refreshes, want 2", len(snaps))
+	}
+
+	if got, want := snaps[0].Text(), "hi   \n     \n"; got != want {
+		t.Errorf("first snapshot %q, want %q", got, want)
+	}
+
+	if got, want := h.Last().Text(), "hi   \nthere\n"; got != want {
+		t.Errorf("last snapshot %q, want %q", got, want)
+	}
+
+	// Snapshots are copies and do not follow the canvas.
+	h.Canvas.Clear()
+
+	if got := h.Last().Text(); got != "hi   \nthere\n" {
+		t.Errorf("snapshot changed with the canvas: %q", got)
+	}
+
+	h.Reset()
+
+	if got := len(h.Snapshots()); got != 0 {
+		t.Errorf("%d snapshots after Reset()", got)
+	}
+}
+
+func TestSnapshotFullwidthText(t *testing.T) {
+	s := Snapshot{
+		Width:  3,
+		Height: 1,
+		Chars:  []rune{'字', caca.MagicFullwidth, 'a'},
+		Attrs:  make([]caca.Attr, 3),
+	}
+
+	if got, want := s.Text(), "字a\n"; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+// helloSnapshot draws the canvas stored in testdata/hello.*.golden.
+func helloSnapshot(t *testing.T) Snapshot {
+	t.Helper()
+
+	h := New(t, 5, 2)
+	h.Canvas.PutStr(0, 0, "hello")
+	h.Canvas.PutStr(2, 1, ":)")
+	h.Display.Refresh()
+
+	return h.Last()
+}
+
+func TestAssertGolden(t *testing.T) {
+	s := helloSnapshot(t)
+
+	AssertGolden(t, "hello.utf8", s, caca.FormatUTF8)
+	AssertGolden(t, "hello.caca", s, caca.FormatCaca)
+}
+
+func TestAssertGoldenUpdate(t *testing.T) {
+	s := helloSnapshot(t)
+
+	dir := t.TempDir()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = os.Chdir(wd) }()
+
+	*update = true
+	defer func() { *update = false }()
+
+	AssertGolden(t, "new", s, caca.FormatUTF8)
+
+	got, err := os.ReadFile(filepath.Join(dir, GoldenPath("new")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile(filepath.Join(wd, GoldenPath("hello.utf8")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("-catest.update wrote %q, want %q", got, want)
+	}
+}
+
+func TestCellDiff(t *testing.T) {
+	golden, err := os.ReadFile(GoldenPath("hello.caca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := helloSnapshot(t)
+	s.Chars[1] = 'a'
+	s.Attrs[4] = caca.NewAttrAnsi(caca.ColorRed, caca.ColorBlack, 0)
+
+	diff, err := cellDiff(golden, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`- "hello"`, `+ "hallo"`, "attr at (4, 0)"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff does not contain %q:\n%s", want, diff)
+		}
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		got  string
+		diff string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"changed", "a\nb\nc", "a\nB\nc", "  \"a\"\n- \"b\"\n+ \"B\"\n  \"c\"\n"},
+		{"added", "a\nb", "a\nx\nb", "  \"a\"\n+ \"x\"\n  \"b\"\n"},
+		{"removed", "a\nx\nb", "a\nb", "  \"a\"\n- \"x\"\n  \"b\"\n"},
+		{"trailing space", "a", "a ", "- \"a\"\n+ \"a \"\n"},
+		{
+			"context",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9",
+			"1\n2\n3\n4\n5\n6\n7\n8\nX",
+			"  ...\n  \"7\"\n  \"8\"\n- \"9\"\n+ \"X\"\n",
+		},
+		{
+			"skipped middle",
+			"a\n1\n2\n3\n4\n5\n6\nb",
+			"A\n1\n2\n3\n4\n5\n6\nB",
+			"- \"a\"\n+ \"A\"\n  \"1\"\n  \"2\"\n  ...\n  \"5\"\n  \"6\"\n- \"b\"\n+ \"B\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := lineDiff(tt.want, tt.got); got != tt.diff {
+			t.Errorf("%s: lineDiff() =\n%s\nwant\n%s", tt.name, got, tt.diff)
+		}
+	}
+}
+
+func TestRawDriver(t *testing.T) {
+	h := NewWithDriver(t, 5, 2, "raw")
+
+	h.Canvas.PutStr(0, 0, "hi")
+	h.Display.Refresh()
+
+	h.Canvas.PutStr(0, 1, "there")
+	h.Display.Refresh()
+
+	frames := h.RawFrames()
+	if len(frames) != 2 {
+		t.Fatalf("%d raw frames after two refreshes, want 2", len(frames))
+	}
+
+	for i, snap := range h.Snapshots() {
+		cv, err := caca.CreateCanvas(0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := frames[i].Import(cv); err != nil {
+			t.Fatalf("raw frame %d: %v", i, err)
+		}
+
+		if got, want := TakeSnapshot(cv).Text(), snap.Text(); got != want {
+			t.Errorf("raw frame %d shows %q, want %q", i, got, want)
+		}
+
+		_ = cv.Free()
+	}
+
+	h.Reset()
+
+	if got := len(h.RawFrames()); got != 0 {
+		t.Errorf("%d raw frames after Reset()", got)
+	}
+}
@@ -0,0 +1,102 @@
+package catest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 2
+
+// lineDiff returns a line diff of want and got, or an empty string if they
+// are equal. Removed lines are prefixed with "-", added lines with "+". Lines
+// are quoted so that trailing spaces and escape sequences are visible.
+func lineDiff(want string, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+
+	var lines []line
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	// Only show unchanged lines close to a change.
+	show := make([]bool, len(lines))
+	changed := false
+
+	for k, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+
+		changed = true
+
+		for c := k - diffContext; c <= k+diffContext; c++ {
+			if c >= 0 && c < len(lines) {
+				show[c] = true
+			}
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	skipped := false
+
+	for k, l := range lines {
+		if !show[k] {
+			if !skipped {
+				sb.WriteString("  ...\n")
+			}
+
+			skipped = true
+
+			continue
+		}
+
+		skipped = false
+
+		fmt.Fprintf(&sb, "%c %q\n", l.op, l.text)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,147 @@
+package catest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/czwinzscher/libcaca-go"
+)
+
+// update makes AssertGolden() write the golden files instead of comparing
+// against them. Run "go test -catest.update" to create or refresh them.
+var update = flag.Bool("catest.update", false, "write catest golden files instead of comparing against them")
+
+// maxAttrDiffs is the number of differing attributes listed in a failure
+// message.
+const maxAttrDiffs = 10
+
+// GoldenPath returns the path of the golden file with the given name. Golden
+// files are stored in the testdata directory of the package under test.
+func GoldenPath(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+// AssertGolden exports the snapshot in the given format and compares it with
+// the golden file of that name, see GoldenPath(). On a mismatch the test fails
+// with a line diff of the exports; for FormatCaca, which is binary, the text
+// and the attributes of the differing cells are compared instead.
+//
+// If the test binary is run with -catest.update, the golden file is written
+// instead.
+func AssertGolden(tb testing.TB, name string, s Snapshot, format caca.Format) {
+	tb.Helper()
+
+	cv, err := s.Canvas()
+	if err != nil {
+		tb.Fatalf("catest: creating canvas: %v", err)
+	}
+
+	got, err := cv.ExportToMemory(format)
+	_ = cv.Free()
+
+	if err != nil {
+		tb.Fatalf("catest: exporting %s: %v", format, err)
+	}
+
+	path := GoldenPath(name)
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatalf("catest: %v", err)
+		}
+
+		if err := os.WriteFile(path, got, 0o644); err != nil { //nolint:gosec // golden files are not secret
+			tb.Fatalf("catest: %v", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		tb.Fatalf("catest: golden file %s does not exist, run the test with -catest.update to create it", path)
+	} else if err != nil {
+		tb.Fatalf("catest: %v", err)
+	}
+
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	var diff string
+
+	if format == caca.FormatCaca {
+		diff, err = cellDiff(want, s)
+		if err != nil {
+			tb.Fatalf("catest: reading golden file %s: %v", path, err)
+		}
+	} else {
+		diff = lineDiff(string(want), string(got))
+	}
+
+	tb.Errorf("catest: %s export does not match golden file %s (-want +got):\n%s", format, path, diff)
+}
+
+// AssertCanvasGolden compares the current frame of the canvas with a golden
+// file like AssertGolden() does.
+func AssertCanvasGolden(tb testing.TB, name string, cv caca.Canvas, format caca.Format) {
+	tb.Helper()
+
+	AssertGolden(tb, name, TakeSnapshot(cv), format)
+}
+
+// cellDiff describes how the snapshot differs from the golden file in "caca"
+// format.
+func cellDiff(golden []byte, got Snapshot) (string, error) {
+	cv, err := caca.CreateCanvas(0, 0)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = cv.Free() }()
+
+	if _, err := cv.ImportFromMemory(golden, caca.FormatCaca); err != nil {
+		return "", err
+	}
+
+	want := TakeSnapshot(cv)
+
+	var b strings.Builder
+
+	if want.Width != got.Width || want.Height != got.Height {
+		fmt.Fprintf(&b, "size: want %dx%d, got %dx%d\n", want.Width, want.Height, got.Width, got.Height)
+	}
+
+	if text := lineDiff(want.Text(), got.Text()); text != "" {
+		b.WriteString(text)
+	}
+
+	if want.Width != got.Width || want.Height != got.Height {
+		return b.String(), nil
+	}
+
+	n := 0
+
+	for i := range want.Attrs {
+		if want.Attrs[i] == got.Attrs[i] {
+			continue
+		}
+
+		if n == maxAttrDiffs {
+			b.WriteString("... more attributes differ\n")
+
+			break
+		}
+
+		fmt.Fprintf(&b, "attr at (%d, %d): want %#08x, got %#08x\n", i%want.Width, i/want.Width, want.Attrs[i], got.Attrs[i])
+		n++
+	}
+
+	return b.String(), nil
+}
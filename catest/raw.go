@@ -0,0 +1,146 @@
+package catest
+
+// #include <stdio.h>
+// #include <unistd.h>
+import "C"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/czwinzscher/libcaca-go"
+)
+
+// rawCapture redirects the standard output of the process, where the "raw"
+// driver writes every refresh, into a pipe and decodes the frames written to
+// it with caca.RawDecoder.
+//
+// The redirection replaces file descriptor 1 of the whole process. Output of
+// Go code is kept out of the pipe by pointing os.Stdout at a duplicate of the
+// original descriptor until the capture is stopped.
+type rawCapture struct {
+	r      *os.File
+	stdout *os.File
+	saved  *os.File
+	done   chan struct{}
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	frames  []caca.RawFrame
+	decoded int
+	err     error
+}
+
+// startRawCapture redirects the standard output into a new pipe and starts
+// decoding it.
+func startRawCapture() (*rawCapture, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	defer w.Close()
+
+	// Nothing buffered by stdio before the redirection may end up in the
+	// pipe.
+	C.fflush(nil)
+
+	fd, err := C.dup(1)
+	if fd == -1 {
+		r.Close()
+
+		return nil, err
+	}
+
+	saved := os.NewFile(uintptr(fd), "/dev/stdout")
+
+	if ret, err := C.dup2(C.int(w.Fd()), 1); ret == -1 {
+		saved.Close()
+		r.Close()
+
+		return nil, err
+	}
+
+	c := &rawCapture{r: r, stdout: os.Stdout, saved: saved, done: make(chan struct{})}
+	c.cond = sync.NewCond(&c.mu)
+	os.Stdout = saved
+
+	go c.decode()
+
+	return c, nil
+}
+
+// decode reads frames from the pipe until it is closed.
+func (c *rawCapture) decode() {
+	defer close(c.done)
+
+	dec := caca.NewRawDecoder(c.r)
+
+	for {
+		frame, err := dec.Next()
+
+		c.mu.Lock()
+
+		if err != nil {
+			c.err = err
+		} else {
+			c.frames = append(c.frames, frame)
+			c.decoded++
+		}
+
+		c.cond.Broadcast()
+		c.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wait blocks until n frames have been decoded since the capture started, or
+// until decoding stopped.
+func (c *rawCapture) wait(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.decoded < n && c.err == nil {
+		c.cond.Wait()
+	}
+}
+
+// captured returns the decoded frames and the error that stopped decoding, if
+// any other than io.EOF.
+func (c *rawCapture) captured() ([]caca.RawFrame, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if errors.Is(c.err, io.EOF) {
+		return append([]caca.RawFrame(nil), c.frames...), nil
+	}
+
+	return append([]caca.RawFrame(nil), c.frames...), c.err
+}
+
+// reset discards the frames decoded so far.
+func (c *rawCapture) reset() {
+	c.mu.Lock()
+	c.frames = nil
+	c.mu.Unlock()
+}
+
+// stop restores the standard output and waits for the decoder to consume the
+// rest of the pipe.
+func (c *rawCapture) stop() {
+	C.fflush(nil)
+
+	// Restoring descriptor 1 closes the last write end of the pipe.
+	C.dup2(C.int(c.saved.Fd()), 1)
+
+	os.Stdout = c.stdout
+	c.saved.Close()
+
+	<-c.done
+	c.r.Close()
+}
@@ -0,0 +1,133 @@
+package catest
+
+import (
+	"strings"
+
+	"github.com/czwinzscher/libcaca-go"
+)
+
+// Snapshot is a copy of the current frame of a canvas, taken after a
+// Display.Refresh(). It does not refer to any C memory.
+type Snapshot struct {
+	Width  int
+	Height int
+	// Chars and Attrs hold the cells row by row, like Canvas.GetChars() and
+	// Canvas.GetAttrs() return them.
+	Chars []rune
+	Attrs []caca.Attr
+}
+
+// TakeSnapshot copies the current frame of the canvas.
+func TakeSnapshot(cv caca.Canvas) Snapshot {
+	return Snapshot{
+		Width:  cv.GetWidth(),
+		Height: cv.GetHeight(),
+		Chars:  cv.GetChars(),
+		Attrs:  cv.GetAttrs(),
+	}
+}
+
+// Text returns the characters of the snapshot, one line per row, without
+// colours. The right halves of fullwidth characters are left out so that the
+// text lines up in a terminal.
+func (s Snapshot) Text() string {
+	var b strings.Builder
+
+	for y := 0; y < s.Height; y++ {
+		for _, ch := range s.Chars[y*s.Width : (y+1)*s.Width] {
+			if ch != caca.MagicFullwidth {
+				b.WriteRune(ch)
+			}
+		}
+
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// Canvas creates a new canvas holding the snapshot. The caller must free it.
+func (s Snapshot) Canvas() (caca.Canvas, error) {
+	cv, err := caca.CreateCanvas(s.Width, s.Height)
+	if err != nil {
+		return cv, err
+	}
+
+	if err := cv.SetCells(0, 0, s.Width, s.Height, s.Chars, s.Attrs); err != nil {
+		_ = cv.Free()
+
+		return caca.Canvas{}, err
+	}
+
+	return cv, nil
+}
+
+// capture is the refresh hook that records a snapshot of every refresh.
+// On the "raw" driver it also waits until the frame written by the refresh
+// has been decoded.
+func (h *Harness) capture(cv caca.Canvas) {
+	s := TakeSnapshot(cv)
+
+	h.mu.Lock()
+	h.snaps = append(h.snaps, s)
+	h.refreshes++
+	n := h.refreshes
+	h.mu.Unlock()
+
+	if h.raw != nil {
+		h.raw.wait(n)
+	}
+}
+
+// Snapshots returns the snapshots of all refreshes so far, oldest first.
+func (h *Harness) Snapshots() []Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]Snapshot(nil), h.snaps...)
+}
+
+// Last returns the snapshot of the latest refresh. The test fails if the
+// display was not refreshed yet.
+func (h *Harness) Last() Snapshot {
+	h.tb.Helper()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.snaps) == 0 {
+		h.tb.Fatalf("catest: the display was never refreshed")
+	}
+
+	return h.snaps[len(h.snaps)-1]
+}
+
+// RawFrames returns the frames the "raw" driver wrote to the standard output
+// since the harness was created or last reset, one per refresh, oldest first.
+// It returns nil on other drivers. The test fails if the output could not be
+// decoded.
+func (h *Harness) RawFrames() []caca.RawFrame {
+	h.tb.Helper()
+
+	if h.raw == nil {
+		return nil
+	}
+
+	frames, err := h.raw.captured()
+	if err != nil {
+		h.tb.Errorf("catest: decoding the raw driver output: %v", err)
+	}
+
+	return frames
+}
+
+// Reset discards the snapshots and raw frames taken so far.
+func (h *Harness) Reset() {
+	h.mu.Lock()
+	h.snaps = nil
+	h.mu.Unlock()
+
+	if h.raw != nil {
+		h.raw.reset()
+	}
+}
@@ -0,0 +1,160 @@
+package caca
+
+// #cgo LDFLAGS: -lcaca
+// #include <caca.h>
+//
+// static void set_cells(caca_canvas_t *cv, int x, int y, int w, int h,
+//                       uint32_t const *chars, uint32_t const *attrs)
+// {
+//     int i, j;
+//
+//     caca_disable_dirty_rect(cv);
+//
+//     for (j = 0; j < h; j++)
+//     {
+//         for (i = 0; i < w; i++)
+//         {
+//             caca_put_char(cv, x + i, y + j, chars[j * w + i]);
+//
+//             if (attrs)
+//                 caca_put_attr(cv, x + i, y + j, attrs[j * w + i]);
+//         }
+//     }
+//
+//     caca_enable_dirty_rect(cv);
+//
+//     /* Overwriting half of a fullwidth character also blanks its other
+//      * half, one column outside the rectangle. */
+//     caca_add_dirty_rect(cv, x - 1, y, w + 2, h);
+// }
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// maxCells is the size of the array type used to view libcaca's cell buffers
+// as Go slices. It is far larger than any canvas libcaca can allocate.
+const maxCells = 1 << 28
+
+// GetCell returns the character and attribute at the given coordinates, like
+// GetChar() and GetAttr() do.
+func (cv Canvas) GetCell(x int, y int) Cell {
+	return Cell{Rune: cv.GetChar(x, y), Attr: cv.GetAttr(x, y)}
+}
+
+// PutCell prints the cell's character at the given coordinates using the
+// cell's attribute instead of the default one. See PutChar() for how
+// fullwidth characters and coordinates outside the canvas are handled.
+//
+// This function returns the width of the printed character, like PutChar().
+func (cv Canvas) PutCell(x int, y int, c Cell) int {
+	n := cv.PutChar(x, y, c.Rune)
+	cv.PutAttr(x, y, c.Attr)
+
+	return n
+}
+
+// GetChars returns a snapshot of the characters of the canvas' current frame.
+// The characters are stored row by row, so the character at (x, y) has the
+// index y*GetWidth()+x. The values are the same as returned by GetChar(),
+// including MagicFullwidth for the right half of fullwidth characters.
+//
+// The returned slice is a copy and is not updated when the canvas changes.
+func (cv Canvas) GetChars() []rune {
+	defer runtime.KeepAlive(cv.h)
+
+	n := cv.GetWidth() * cv.GetHeight()
+	chars := make([]rune, n)
+
+	if n == 0 {
+		return chars
+	}
+
+	cChars := (*[maxCells]C.uint32_t)(unsafe.Pointer(C.caca_get_canvas_chars(cv.ptr())))[:n:n]
+	for i, ch := range cChars {
+		chars[i] = rune(ch)
+	}
+
+	return chars
+}
+
+// GetAttrs returns a snapshot of the attributes of the canvas' current frame.
+// The attributes are stored row by row, so the attribute at (x, y) has the
+// index y*GetWidth()+x. See GetAttr() for the meaning of the values.
+//
+// The returned slice is a copy and is not updated when the canvas changes.
+func (cv Canvas) GetAttrs() []Attr {
+	defer runtime.KeepAlive(cv.h)
+
+	n := cv.GetWidth() * cv.GetHeight()
+	attrs := make([]Attr, n)
+
+	if n == 0 {
+		return attrs
+	}
+
+	cAttrs := (*[maxCells]C.uint32_t)(unsafe.Pointer(C.caca_get_canvas_attrs(cv.ptr())))[:n:n]
+	for i, attr := range cAttrs {
+		attrs[i] = Attr(attr)
+	}
+
+	return attrs
+}
+
+// readCells copies the cells of the rectangle, which must lie inside the
+// canvas, from the canvas' current frame into chars and attrs. These hold
+// GetWidth()*GetHeight() cells, indexed like the slices returned by GetChars()
+// and GetAttrs(); cells outside the rectangle are left untouched.
+func (cv Canvas) readCells(r Rect, chars []rune, attrs []Attr) {
+	defer runtime.KeepAlive(cv.h)
+
+	if r.Empty() {
+		return
+	}
+
+	width := cv.GetWidth()
+	n := width * cv.GetHeight()
+	cChars := (*[maxCells]C.uint32_t)(unsafe.Pointer(C.caca_get_canvas_chars(cv.ptr())))[:n:n]
+	cAttrs := (*[maxCells]C.uint32_t)(unsafe.Pointer(C.caca_get_canvas_attrs(cv.ptr())))[:n:n]
+
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for i := y*width + r.X; i < y*width+r.X+r.Width; i++ {
+			chars[i], attrs[i] = rune(cChars[i]), Attr(cAttrs[i])
+		}
+	}
+}
+
+// SetCells writes a w×h rectangle of characters and attributes at the given
+// coordinates in a single call. chars and attrs are stored row by row, like
+// the slices returned by GetChars() and GetAttrs(), and must both hold w*h
+// elements. If attrs is nil, the characters are printed with the current
+// attribute, like PutChar() does.
+//
+// Cells outside the canvas boundaries are ignored. The rectangle, widened by
+// one column on each side for fullwidth characters cut at its edges, is added
+// to the dirty rectangle list as a whole.
+//
+// If the slice lengths do not match the rectangle size, ErrInvalidArgument is
+// returned.
+func (cv Canvas) SetCells(x int, y int, w int, h int, chars []rune, attrs []Attr) error {
+	defer runtime.KeepAlive(cv.h)
+
+	if w < 0 || h < 0 || len(chars) != w*h || (attrs != nil && len(attrs) != w*h) {
+		return &Error{Op: "Canvas.SetCells", Err: ErrInvalidArgument}
+	}
+
+	if w == 0 || h == 0 {
+		return nil
+	}
+
+	var cAttrs *C.uint32_t
+	if attrs != nil {
+		cAttrs = (*C.uint32_t)(unsafe.Pointer(&attrs[0]))
+	}
+
+	C.set_cells(cv.ptr(), C.int(x), C.int(y), C.int(w), C.int(h), (*C.uint32_t)(unsafe.Pointer(&chars[0])), cAttrs)
+
+	return nil
+}
@@ -0,0 +1,256 @@
+// Command cacaplay replays a recording of libcaca's "raw" display driver on
+// any display.
+//
+// Usage:
+//
+//     cacaplay [-speed factor] [-fps rate] [-driver name] [-loop] [file]
+//
+// The recording is read from the file, or from the standard input if no file
+// or "-" is given. Recordings made with caca.RawRecorder are played at their
+// original speed multiplied by -speed; plain "raw" streams carry no times and
+// are played at -fps frames per second. Frames are played as they arrive, so
+// the output of a running program can be piped in and watched live. Press q
+// or Escape to quit.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/czwinzscher/libcaca-go"
+)
+
+// pollInterval is the longest time spent waiting for a key press at once, in
+// microseconds.
+const pollInterval = 100000
+
+func main() {
+	speed := flag.Float64("speed", 1, "playback speed factor")
+	fps := flag.Float64("fps", 25, "frame rate for recordings without times")
+	driver := flag.String("driver", "", "display driver, autodetected if empty")
+	loop := flag.Bool("loop", false, "replay the recording until quit")
+	flag.Parse()
+
+	if *speed <= 0 || *fps <= 0 {
+		fmt.Fprintln(os.Stderr, "[ERR] -speed and -fps must be positive")
+		os.Exit(2)
+	}
+
+	if err := play(flag.Arg(0), *driver, *speed, *fps, *loop); err != nil {
+		fmt.Fprintln(os.Stderr, "[ERR] "+err.Error())
+		os.Exit(1)
+	}
+}
+
+// frameWait is the longest time spent handling events at once while waiting
+// for the next frame of a live recording.
+const frameWait = 10 * time.Millisecond
+
+// decoded is a frame read from the recording, or the error that ended it.
+type decoded struct {
+	frame caca.RawFrame
+	timed bool
+	err   error
+}
+
+// readFrames decodes the recording in the background and sends its frames as
+// they arrive, so that a recording still being written, such as a pipe from a
+// running program, is played live. The channel is closed at the end of the
+// recording; a read error is sent as the last value.
+func readFrames(r io.Reader) <-chan decoded {
+	frames := make(chan decoded, 1)
+
+	go func() {
+		defer close(frames)
+
+		dec := caca.NewRawDecoder(r)
+
+		for {
+			frame, err := dec.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			if err != nil {
+				frames <- decoded{err: errors.New("error while reading recording: " + err.Error())}
+
+				return
+			}
+
+			frames <- decoded{frame: frame, timed: dec.Timed()}
+		}
+	}()
+
+	return frames
+}
+
+// receive waits for the next frame of the recording while handling events.
+// ok is false at the end of the recording; quit reports whether the user
+// asked to quit in the meantime.
+func receive(dp caca.Display, frames <-chan decoded) (d decoded, ok bool, quit bool) {
+	for {
+		select {
+		case d, ok = <-frames:
+			return d, ok, false
+		default:
+		}
+
+		if waitUntil(dp, time.Now().Add(frameWait)) {
+			return decoded{}, false, true
+		}
+	}
+}
+
+func play(name string, driver string, speed float64, fps float64, loop bool) error {
+	var r io.Reader = os.Stdin
+
+	if name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		r = f
+	}
+
+	// Frames are imported into an offscreen canvas and blitted onto the
+	// display's canvas, which cannot be resized while attached.
+	frameCv, err := caca.CreateCanvas(0, 0)
+	if err != nil {
+		return errors.New("error while creating canvas: " + err.Error())
+	}
+
+	defer func() { _ = frameCv.Free() }()
+
+	var dp caca.Display
+
+	if driver == "" {
+		dp, err = caca.CreateDisplay(nil)
+	} else {
+		dp, err = caca.CreateDisplayWithDriver(nil, driver)
+	}
+
+	if err != nil {
+		return errors.New("error while creating display: " + err.Error())
+	}
+
+	defer dp.Free()
+
+	_ = dp.SetTitle("cacaplay")
+	cv := dp.GetCanvas()
+
+	show := func(frame caca.RawFrame) error {
+		if err := frame.Import(frameCv); err != nil {
+			return errors.New("error while importing frame: " + err.Error())
+		}
+
+		cv.Clear()
+
+		if err := cv.Blit(0, 0, frameCv, nil); err != nil {
+			return errors.New("error while drawing frame: " + err.Error())
+		}
+
+		dp.Refresh()
+
+		return nil
+	}
+
+	interval := time.Duration(float64(time.Second) / fps)
+	frames := readFrames(r)
+	start := time.Now()
+
+	// Frames are only kept once played if they are needed again for -loop.
+	var played []caca.RawFrame
+
+	n := 0
+
+	for ; ; n++ {
+		d, ok, quit := receive(dp, frames)
+		if quit {
+			return nil
+		}
+
+		if !ok {
+			break
+		}
+
+		if d.err != nil {
+			return d.err
+		}
+
+		if !d.timed {
+			d.frame.Time = time.Duration(n) * interval
+		}
+
+		// Frames of a live recording arriving late are shown at once.
+		if waitUntil(dp, start.Add(time.Duration(float64(d.frame.Time)/speed))) {
+			return nil
+		}
+
+		if err := show(d.frame); err != nil {
+			return err
+		}
+
+		if loop {
+			played = append(played, d.frame)
+		}
+	}
+
+	if n == 0 {
+		return errors.New("the recording holds no frames")
+	}
+
+	for loop && len(played) > 0 {
+		start := time.Now()
+
+		for _, frame := range played {
+			due := start.Add(time.Duration(float64(frame.Time) / speed))
+			if waitUntil(dp, due) {
+				return nil
+			}
+
+			if err := show(frame); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Keep the last frame on screen until the user quits.
+	waitUntil(dp, time.Time{})
+
+	return nil
+}
+
+// waitUntil handles events until the given time and reports whether the user
+// asked to quit. A zero time waits until the user quits.
+func waitUntil(dp caca.Display, due time.Time) bool {
+	for {
+		timeout := pollInterval
+
+		if !due.IsZero() {
+			left := time.Until(due)
+			if left <= 0 {
+				return false
+			}
+
+			if us := int(left / time.Microsecond); us < timeout {
+				timeout = us
+			}
+		}
+
+		ev, ok := dp.GetEvent(caca.EventKeyPress|caca.EventQuit, nil, timeout)
+		if !ok {
+			continue
+		}
+
+		if ev.Type == caca.EventQuit || ev.KeyCh == 'q' || ev.KeyCh == caca.KeyEscape {
+			return true
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/czwinzscher/libcaca-go"
 	"os"
+	"time"
 )
 
+// keyPressTimeout is how long the example waits for a key press.
+const keyPressTimeout = 30 * time.Second
+
 func showCanvas() (errs []error) {
 	canvasWidth := 50
 	canvasHeight := 10
@@ -40,10 +45,18 @@ func showCanvas() (errs []error) {
 	}
 
 	dp.Refresh()
-	dp.GetEvent(caca.EventKeyPress, nil, -1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyPressTimeout)
+	defer cancel()
+
+	_, err = dp.WaitEvent(ctx, caca.EventKeyPress)
 
 	dp.Free()
 
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return []error{errors.New("error while waiting for key press: " + err.Error())}
+	}
+
 	return errs
 }
 
@@ -0,0 +1,54 @@
+package caca
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// cAllocs counts the C memory blocks that the bindings allocated, or took
+// ownership of, and did not free yet. It lets the tests check that no binding
+// leaks C memory.
+var cAllocs int64
+
+// cString is C.CString() counted in cAllocs. The result must be released with
+// cFree().
+func cString(s string) *C.char {
+	atomic.AddInt64(&cAllocs, 1)
+
+	return C.CString(s)
+}
+
+// cBytes is C.CBytes() counted in cAllocs. The result must be released with
+// cFree().
+func cBytes(b []byte) unsafe.Pointer {
+	atomic.AddInt64(&cAllocs, 1)
+
+	return C.CBytes(b)
+}
+
+// cCalloc is C.calloc() counted in cAllocs. The result must be released with
+// cFree().
+func cCalloc(n C.size_t, size C.size_t) unsafe.Pointer {
+	atomic.AddInt64(&cAllocs, 1)
+
+	return C.calloc(n, size)
+}
+
+// cOwn counts a buffer allocated by libcaca, such as the result of
+// caca_export_canvas_to_memory(), in cAllocs. The buffer must be released
+// with cFree().
+func cOwn(p unsafe.Pointer) unsafe.Pointer {
+	atomic.AddInt64(&cAllocs, 1)
+
+	return p
+}
+
+// cFree releases memory obtained from cString(), cBytes(), cCalloc() or
+// cOwn().
+func cFree(p unsafe.Pointer) {
+	atomic.AddInt64(&cAllocs, -1)
+	C.free(p)
+}
@@ -2,6 +2,7 @@ package caca
 
 // #cgo LDFLAGS: -lcaca
 // #include <caca.h>
+// #include <stdlib.h>
 // #include <string.h>
 import "C"
 
@@ -110,6 +111,31 @@ func GetVersion() string {
 	return string(b)
 }
 
+// goStrings converts a NULL-terminated array of C strings, as returned by the
+// libcaca list functions, into a Go slice.
+func goStrings(list **C.char) []string {
+	var strs []string
+
+	if list == nil {
+		return strs
+	}
+
+	for p := list; *p != nil; p = (**C.char)(unsafe.Pointer(uintptr(unsafe.Pointer(p)) + unsafe.Sizeof(*p))) {
+		strs = append(strs, C.GoString(*p))
+	}
+
+	return strs
+}
+
+// bytesPtr returns a pointer to the first byte of b, or nil if b is empty.
+func bytesPtr(b []byte) unsafe.Pointer {
+	if len(b) == 0 {
+		return nil
+	}
+
+	return unsafe.Pointer(&b[0])
+}
+
 // Rand returns a random number between min and max.
 func Rand(min int, max int) int {
 	return int(C.caca_rand(C.int(min), C.int(max)))
@@ -186,6 +212,25 @@ func AttrToRGB12Bg(attr uint32) uint16 {
 	return uint16(C.caca_attr_to_rgb12_bg(C.uint32_t(attr)))
 }
 
+// AttrToARGB64 gets the 64-bit colour pair for a given attribute. The returned
+// array contains eight values, each holding one of the background alpha, red,
+// green and blue components and the foreground alpha, red, green and blue
+// components, in that order. Each component is a 4-bit value between 0 and 15.
+//
+// This function never fails. If the attribute value is outside the expected
+// 32-bit range, higher order bits are simply ignored.
+func AttrToARGB64(attr uint32) [8]uint8 {
+	var cArgb [8]C.uint8_t
+	C.caca_attr_to_argb64(C.uint32_t(attr), &cArgb[0])
+
+	var argb [8]uint8
+	for i, c := range cArgb {
+		argb[i] = uint8(c)
+	}
+
+	return argb
+}
+
 // UTF8ToUTF32 converts a UTF-8 character read from a string and returns its
 // value in the UTF-32 character set.
 //
@@ -195,7 +240,10 @@ func AttrToRGB12Bg(attr uint32) uint16 {
 // This function never fails, but its behaviour with illegal UTF-8 sequences is
 // undefined.
 func UTF8ToUTF32(s string) uint32 {
-	return uint32(C.caca_utf8_to_utf32(C.CString(s), nil))
+	cStr := cString(s)
+	defer cFree(unsafe.Pointer(cStr))
+
+	return uint32(C.caca_utf8_to_utf32(cStr, nil))
 }
 
 // UTF32ToUTF8 Convert a UTF-32 character read from a string and returns its value
@@ -5,9 +5,81 @@ package caca
 // #include <stdlib.h>
 import "C"
 
-// Display is a libcaca display context.
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// Display is a handle to a libcaca display context. Copies of a Display refer
+// to the same underlying display, so freeing any copy frees it for all of
+// them. Using a display after it was freed panics.
+//
+// Unlike canvases, displays are not freed by a finalizer: most drivers must
+// be shut down from the thread that uses them, which a finalizer cannot
+// guarantee. A display that is never freed stays open until the program
+// exits, so Free() must always be called.
 type Display struct {
-	Dp *C.struct_caca_display
+	h *displayHandle
+}
+
+type displayHandle struct {
+	dp *C.struct_caca_display
+	cv Canvas
+
+	// mu guards the synthetic events pushed with PushEvent() and the
+	// refresh hooks, which may be used from other goroutines.
+	mu      sync.Mutex
+	pending []EventData
+	hooks   []*refreshHook
+}
+
+// newDisplay wraps a C display pointer in a Display handle. If cv is nil, the
+// canvas was created by libcaca and is marked as owned by the display.
+func newDisplay(cPtr *C.struct_caca_display, cv *Canvas) Display {
+	h := &displayHandle{dp: cPtr}
+
+	if cv != nil {
+		h.cv = *cv
+	} else {
+		h.cv = newCanvas(C.caca_get_canvas(cPtr), false)
+	}
+
+	return Display{h: h}
+}
+
+// free destroys the display and invalidates the canvas if the display
+// created it.
+func (h *displayHandle) free() {
+	pumps.Lock()
+	dp := h.dp
+	h.dp = nil
+	delete(pumps.groups, h)
+	pumps.Unlock()
+
+	if dp == nil {
+		return
+	}
+
+	C.caca_free_display(dp)
+
+	if !h.cv.h.owned {
+		h.cv.h.cv = nil
+	}
+}
+
+// ptr returns the underlying C display and panics if the display was freed.
+func (d Display) ptr() *C.struct_caca_display {
+	d.mustLive()
+
+	return d.h.dp
+}
+
+// mustLive panics if the display was freed or never initialised.
+func (d Display) mustLive() {
+	if d.h == nil || d.h.dp == nil {
+		panic("caca: use of freed or uninitialised Display")
+	}
 }
 
 // CreateDisplay creates a graphical context using device-dependent features
@@ -23,21 +95,23 @@ type Display struct {
 //
 // If an error occurs the according errno is returned.
 func CreateDisplay(cv *Canvas) (Display, error) {
+	defer runtime.KeepAlive(cv)
+
 	var cPtr *C.struct_caca_display
 
 	var err error
 
 	if cv != nil {
-		cPtr, err = C.caca_create_display(cv.Cv)
+		cPtr, err = C.caca_create_display(cv.ptr())
 	} else {
 		cPtr, err = C.caca_create_display(nil)
 	}
 
 	if cPtr == nil {
-		return Display{}, err
+		return Display{}, wrapErr("CreateDisplay", err)
 	}
 
-	return Display{Dp: cPtr}, nil
+	return newDisplay(cPtr, cv), nil
 }
 
 // CreateDisplayWithDriver creates a graphical context using device-dependent
@@ -50,47 +124,70 @@ func CreateDisplay(cv *Canvas) (Display, error) {
 // Free() is called.
 //
 // If no driver name is provided, libcaca will try to autodetect the best
-// output driver it can.
+// output driver it can. The drivers available in the linked libcaca are
+// returned by Drivers().
 //
 // See also CreateDisplay().
 //
 // If an error occurs the according errno is returned.
 func CreateDisplayWithDriver(cv *Canvas, driver string) (Display, error) {
+	defer runtime.KeepAlive(cv)
+
+	cDriver := cString(driver)
+	defer cFree(unsafe.Pointer(cDriver))
+
 	var cPtr *C.struct_caca_display
 
 	var err error
 
 	if cv != nil {
-		cPtr, err = C.caca_create_display_with_driver(cv.Cv, C.CString(driver))
+		cPtr, err = C.caca_create_display_with_driver(cv.ptr(), cDriver)
 	} else {
-		cPtr, err = C.caca_create_display_with_driver(nil, C.CString(driver))
+		cPtr, err = C.caca_create_display_with_driver(nil, cDriver)
 	}
 
 	if cPtr == nil {
-		return Display{}, err
+		return Display{}, wrapErr("CreateDisplayWithDriver", err)
 	}
 
-	return Display{Dp: cPtr}, nil
+	return newDisplay(cPtr, cv), nil
 }
 
 // GetDriver returns the display's current output driver.
 func (d Display) GetDriver() string {
-	return C.GoString(C.caca_get_display_driver(d.Dp))
+	defer runtime.KeepAlive(d.h)
+
+	return C.GoString(C.caca_get_display_driver(d.ptr()))
 }
 
-// SetDriver dynamically changes the display's output driver.
+// SetDriver dynamically changes the display's output driver. The drivers
+// available in the linked libcaca are returned by Drivers().
 //
-// Returns 0 in case of success, -1 if an error occurred.
-func (d Display) SetDriver(driver string) int {
-	return int(C.caca_set_display_driver(d.Dp, C.CString(driver)))
+// If an error occurs the according errno is returned.
+func (d Display) SetDriver(driver string) error {
+	defer runtime.KeepAlive(d.h)
+
+	cDriver := cString(driver)
+	defer cFree(unsafe.Pointer(cDriver))
+
+	ret, err := C.caca_set_display_driver(d.ptr(), cDriver)
+
+	if int(ret) == -1 {
+		return wrapErr("Display.SetDriver", err)
+	}
+
+	return nil
 }
 
 // GetCanvas returns the canvas that was either attached or created by
 // CreateDisplay().
+//
+// A canvas created by the display is owned by it: calling Free() on it is
+// refused, and it becomes invalid once the display is freed.
 func (d Display) GetCanvas() Canvas {
-	cPtr := C.caca_get_canvas(d.Dp)
+	d.mustLive()
 
-	return Canvas{Cv: cPtr}
+	return d.h.cv
 }
 
 // Refresh flushes all graphical operations and prints them to the display
@@ -100,8 +197,14 @@ func (d Display) GetCanvas() Canvas {
 // value to achieve constant framerate: if two consecutive calls to Refresh()
 // are within a time range shorter than the value set with SetTime(), the
 // second call will be delayed before performing the screen refresh.
+//
+// Hooks added with AddRefreshHook() are called after the refresh.
 func (d Display) Refresh() {
-	C.caca_refresh_display(d.Dp)
+	defer runtime.KeepAlive(d.h)
+
+	C.caca_refresh_display(d.ptr())
+
+	d.runRefreshHooks()
 }
 
 // SetTime sets the refresh delay in microseconds. The refresh delay is used by
@@ -113,10 +216,12 @@ func (d Display) Refresh() {
 //
 // If an error occurs the according errno is returned.
 func (d Display) SetTime(usec int) error {
-	ret, err := C.caca_set_display_time(d.Dp, C.int(usec))
+	defer runtime.KeepAlive(d.h)
+
+	ret, err := C.caca_set_display_time(d.ptr(), C.int(usec))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Display.SetTime", err)
 	}
 
 	return nil
@@ -127,7 +232,9 @@ func (d Display) SetTime(usec int) error {
 // activated by calling SetTime(), the average rendering time will be close to
 // the requested delay even if the real rendering time was shorter.
 func (d Display) GetTime() int {
-	return int(C.caca_get_display_time(d.Dp))
+	defer runtime.KeepAlive(d.h)
+
+	return int(C.caca_get_display_time(d.ptr()))
 }
 
 // SetTitle tries to change libcaca's window title if it runs in a window.
@@ -135,13 +242,18 @@ func (d Display) GetTime() int {
 //
 // If an error occurs the according errno is returned.
 func (d Display) SetTitle(title string) error {
-	ret, err := C.caca_set_display_title(d.Dp, C.CString(title))
+	defer runtime.KeepAlive(d.h)
+
+	cTitle := cString(title)
+	defer cFree(unsafe.Pointer(cTitle))
+
+	ret, err := C.caca_set_display_title(d.ptr(), cTitle)
 
 	if int(ret) != -1 {
 		return nil
 	}
 
-	return err
+	return wrapErr("Display.SetTitle", err)
 }
 
 // SetMouse shows or hides the mouse pointer. This function works with the
@@ -149,10 +261,12 @@ func (d Display) SetTitle(title string) error {
 //
 // If an error occurs the according errno is returned.
 func (d Display) SetMouse(flag int) error {
-	ret, err := C.caca_set_mouse(d.Dp, C.int(flag))
+	defer runtime.KeepAlive(d.h)
+
+	ret, err := C.caca_set_mouse(d.ptr(), C.int(flag))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Display.SetMouse", err)
 	}
 
 	return nil
@@ -165,10 +279,12 @@ func (d Display) SetMouse(flag int) error {
 //
 // If an error occurs the according errno is returned.
 func (d Display) SetCursor(flag int) error {
-	ret, err := C.caca_set_cursor(d.Dp, C.int(flag))
+	defer runtime.KeepAlive(d.h)
+
+	ret, err := C.caca_set_cursor(d.ptr(), C.int(flag))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Display.SetCursor", err)
 	}
 
 	return nil
@@ -179,19 +295,47 @@ func (d Display) SetCursor(flag int) error {
 // discarded. If eventMask is zero, the function returns immediately.
 //
 // The timeout value tells how long this function needs to wait for an event.
-// A value of zero returns immediately and the function returns zero if no more
+// A value of zero returns immediately and the function returns false if no more
 // events are pending in the queue. A negative value causes the function to wait
 // indefinitely until a matching event is received.
 //
-// If not nil, ev will be filled with information about the event received. If
-// nil, the function will return but no information about the event will be
-// sent.
-func (d Display) GetEvent(eventMask int, ev *Event, timeout int) {
-	if ev == nil {
-		C.caca_get_event(d.Dp, C.int(eventMask), nil, C.int(timeout))
-	} else {
-		C.caca_get_event(d.Dp, C.int(eventMask), ev.Ev, C.int(timeout))
+// The received event is returned decoded as an EventData value together with
+// true. If no event was received before the timeout expired, an empty
+// EventData and false are returned.
+//
+// Events pushed with PushEvent() are returned first, before any event from
+// the driver.
+//
+// If ev was allocated with NewEvent(), it is additionally filled with the raw
+// event so that its getters can be used. If ev is nil or was not allocated,
+// a temporary event is used internally and freed before returning.
+func (d Display) GetEvent(eventMask int, ev *Event, timeout int) (EventData, bool) {
+	defer runtime.KeepAlive(d.h)
+
+	d.mustLive()
+
+	if data, ok := d.popEvent(eventMask); ok {
+		if ev != nil && ev.Ev != nil {
+			ev.fill(data)
+		}
+
+		return data, true
 	}
+
+	if ev == nil || ev.Ev == nil {
+		tmp := NewEvent()
+		defer tmp.Free()
+
+		ev = &tmp
+	}
+
+	ret := C.caca_get_event(d.ptr(), C.int(eventMask), ev.Ev, C.int(timeout))
+
+	if int(ret) == 0 {
+		return EventData{}, false
+	}
+
+	return ev.Data(), true
 }
 
 // GetMouseX returns the X coordinate of the mouse position last time it was
@@ -199,7 +343,9 @@ func (d Display) GetEvent(eventMask int, ev *Event, timeout int) {
 // being used, because mouse position is only detected when the mouse is
 // clicked. Other drivers such as X11 work well.
 func (d Display) GetMouseX() int {
-	return int(C.caca_get_mouse_x(d.Dp))
+	defer runtime.KeepAlive(d.h)
+
+	return int(C.caca_get_mouse_x(d.ptr()))
 }
 
 // GetMouseY returns the Y coordinate of the mouse position last time it was
@@ -207,7 +353,9 @@ func (d Display) GetMouseX() int {
 // being used, because mouse position is only detected when the mouse is
 // clicked. Other drivers such as X11 work well.
 func (d Display) GetMouseY() int {
-	return int(C.caca_get_mouse_y(d.Dp))
+	defer runtime.KeepAlive(d.h)
+
+	return int(C.caca_get_mouse_y(d.ptr()))
 }
 
 // Free detaches a graphical context from its caca backend and destroys it.
@@ -216,6 +364,23 @@ func (d Display) GetMouseY() int {
 //
 // If the caca canvas was automatically created by CreateDisplay(), it is
 // automatically destroyed and any handle to it becomes invalid.
+//
+// Event pumps started with Events() or WaitEvent() are stopped before the
+// display is destroyed. Calling Free() on an already freed display does
+// nothing.
 func (d Display) Free() {
-	C.caca_free_display(d.Dp)
+	if d.h == nil || d.h.dp == nil {
+		return
+	}
+
+	stopPumps(d.h)
+	d.h.free()
+}
+
+// Close frees the display like Free(). It makes Display satisfy io.Closer and
+// always returns nil.
+func (d Display) Close() error {
+	d.Free()
+
+	return nil
 }
@@ -6,12 +6,44 @@ package caca
 import "C"
 
 import (
+	"runtime"
 	"unsafe"
 )
 
-// Dither is a dither structure.
+// Dither is a handle to a dither structure. Copies of a Dither refer to the
+// same underlying dither, so freeing any copy frees it for all of them. Using a
+// dither after it was freed panics.
+//
+// A dither that is never freed is destroyed by a finalizer once it becomes
+// unreachable.
 type Dither struct {
-	Di *C.struct_caca_dither
+	h *ditherHandle
+}
+
+type ditherHandle struct {
+	di *C.struct_caca_dither
+
+	// The pixel layout and the settings used by the Go dithering engine, see
+	// SetBackend().
+	format  pixelFormat
+	backend DitherBackend
+	goAlgo  DitherAlgorithm
+	serpent bool
+	errGain float64
+}
+
+// ptr returns the underlying C dither and panics if the dither was freed.
+func (di Dither) ptr() *C.struct_caca_dither {
+	di.mustLive()
+
+	return di.h.di
+}
+
+// mustLive panics if the dither was freed or never initialised.
+func (di Dither) mustLive() {
+	if di.h == nil || di.h.di == nil {
+		panic("caca: use of freed or uninitialised Dither")
+	}
 }
 
 // CreateDither creates a dither structure from its coordinates
@@ -25,10 +57,22 @@ func CreateDither(bpp int, w int, h int, pitch int, rmask uint32, gmask uint32,
 	cPtr, err := C.caca_create_dither(C.int(bpp), C.int(w), C.int(h), C.int(pitch), C.uint32_t(rmask), C.uint32_t(gmask), C.uint32_t(bmask), C.uint32_t(amask))
 
 	if cPtr == nil {
-		return Dither{}, err
+		return Dither{}, wrapErr("CreateDither", err)
 	}
 
-	return Dither{Di: cPtr}, nil
+	handle := &ditherHandle{di: cPtr, errGain: 1}
+	handle.format = pixelFormat{
+		bpp: bpp, width: w, height: h, pitch: pitch,
+		masks: [4]uint32{rmask, gmask, bmask, amask},
+	}
+
+	runtime.SetFinalizer(handle, func(h *ditherHandle) {
+		if h.di != nil {
+			C.caca_free_dither(h.di)
+		}
+	})
+
+	return Dither{h: handle}, nil
 }
 
 // SetPalette sets the palette of an 8 bits per pixel bitmap. Values should be
@@ -36,10 +80,16 @@ func CreateDither(bpp int, w int, h int, pitch int, rmask uint32, gmask uint32,
 //
 // If an error occurs the according errno is returned.
 func (di Dither) SetPalette(red [256]uint32, green [256]uint32, blue [256]uint32, alpha [256]uint32) error {
-	ret, err := C.caca_set_dither_palette(di.Di, (*C.uint32_t)(&red[0]), (*C.uint32_t)(&green[0]), (*C.uint32_t)(&blue[0]), (*C.uint32_t)(&alpha[0]))
+	defer runtime.KeepAlive(di.h)
+
+	ret, err := C.caca_set_dither_palette(di.ptr(), (*C.uint32_t)(&red[0]), (*C.uint32_t)(&green[0]), (*C.uint32_t)(&blue[0]), (*C.uint32_t)(&alpha[0]))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Dither.SetPalette", err)
+	}
+
+	for i := range di.h.format.palette {
+		di.h.format.palette[i] = [4]uint32{red[i], green[i], blue[i], alpha[i]}
 	}
 
 	return nil
@@ -49,10 +99,12 @@ func (di Dither) SetPalette(red [256]uint32, green [256]uint32, blue [256]uint32
 //
 // If an error occurs the according errno is returned.
 func (di Dither) SetBrightness(brightness float64) error {
-	ret, err := C.caca_set_dither_brightness(di.Di, C.float(brightness))
+	defer runtime.KeepAlive(di.h)
+
+	ret, err := C.caca_set_dither_brightness(di.ptr(), C.float(brightness))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Dither.SetBrightness", err)
 	}
 
 	return nil
@@ -60,7 +112,9 @@ func (di Dither) SetBrightness(brightness float64) error {
 
 // GetBrightness returns the brightness of the dither.
 func (di Dither) GetBrightness() float64 {
-	return float64(C.caca_get_dither_brightness(di.Di))
+	defer runtime.KeepAlive(di.h)
+
+	return float64(C.caca_get_dither_brightness(di.ptr()))
 }
 
 // SetGamma sets the gamma of the dither object. A negative value causes colour
@@ -68,10 +122,12 @@ func (di Dither) GetBrightness() float64 {
 //
 // If an error occurs the according errno is returned.
 func (di Dither) SetGamma(gamma float64) error {
-	ret, err := C.caca_set_dither_gamma(di.Di, C.float(gamma))
+	defer runtime.KeepAlive(di.h)
+
+	ret, err := C.caca_set_dither_gamma(di.ptr(), C.float(gamma))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Dither.SetGamma", err)
 	}
 
 	return nil
@@ -79,17 +135,21 @@ func (di Dither) SetGamma(gamma float64) error {
 
 // GetGamma returns the gamma of the dither object.
 func (di Dither) GetGamma() float64 {
-	return float64(C.caca_get_dither_gamma(di.Di))
+	defer runtime.KeepAlive(di.h)
+
+	return float64(C.caca_get_dither_gamma(di.ptr()))
 }
 
 // SetContrast sets the contrast of the dither object.
 //
 // If an error occurs the according errno is returned.
 func (di Dither) SetContrast(gamma float64) error {
-	ret, err := C.caca_set_dither_contrast(di.Di, C.float(gamma))
+	defer runtime.KeepAlive(di.h)
+
+	ret, err := C.caca_set_dither_contrast(di.ptr(), C.float(gamma))
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Dither.SetContrast", err)
 	}
 
 	return nil
@@ -97,125 +157,247 @@ func (di Dither) SetContrast(gamma float64) error {
 
 // GetContrast returns the contrast of the dither object.
 func (di Dither) GetContrast() float64 {
-	return float64(C.caca_get_dither_contrast(di.Di))
+	defer runtime.KeepAlive(di.h)
+
+	return float64(C.caca_get_dither_contrast(di.ptr()))
 }
 
 // SetAntialias tells the renderer whether to antialias the dither.
 // Antialiasing smoothens the rendered image and avoids the commonly seen
 // staircase effect.
 //
-//     "none": no antialiasing.
-//     "prefilter" or "default": simple prefilter antialiasing. This is the
-//                               default value.
+//     AntialiasNone: no antialiasing.
+//     AntialiasPrefilter or "default": simple prefilter antialiasing. This is
+//                                      the default value.
+//
+// AntialiasList() returns the methods supported by the linked libcaca.
 //
 // If an error occurs the according errno is returned.
-func (di Dither) SetAntialias(str string) error {
-	ret, err := C.caca_set_dither_antialias(di.Di, C.CString(str))
+func (di Dither) SetAntialias(str DitherAntialias) error {
+	defer runtime.KeepAlive(di.h)
+
+	cStr := cString(string(str))
+	defer cFree(unsafe.Pointer(cStr))
+
+	ret, err := C.caca_set_dither_antialias(di.ptr(), cStr)
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Dither.SetAntialias", err)
 	}
 
 	return nil
 }
 
 // GetAntialias returns the antialiasing method of the dither object.
-func (di Dither) GetAntialias() string {
-	return C.GoString(C.caca_get_dither_antialias(di.Di))
+func (di Dither) GetAntialias() DitherAntialias {
+	defer runtime.KeepAlive(di.h)
+
+	return DitherAntialias(C.GoString(C.caca_get_dither_antialias(di.ptr())))
+}
+
+// AntialiasList returns the antialiasing methods supported by the linked
+// libcaca.
+func (di Dither) AntialiasList() []DitherChoice {
+	defer runtime.KeepAlive(di.h)
+
+	return ditherChoices(C.caca_get_dither_antialias_list(di.ptr()))
 }
 
 // SetColor tells the renderer which colours should be used to render the
-// bitmap. Valid values for str are:
-//
-//     "mono": use light gray on a black background.
-//     "gray": use white and two shades of gray on a black background.
-//     "8": use the 8 ANSI colours on a black background.
-//     "16": use the 16 ANSI colours on a black background.
-//     "fullgray": use black, white and two shades of gray for both the
-//                 characters and the background.
-//     "full8": use the 8 ANSI colours for both the characters and the
-//              background.
-//     "full16" or "default": use the 16 ANSI colours for both the characters
-//                            and the background. This is the default value.
+// bitmap. Valid values are:
+//
+//     ColorMono: use light gray on a black background.
+//     ColorGray: use white and two shades of gray on a black background.
+//     Color8: use the 8 ANSI colours on a black background.
+//     Color16: use the 16 ANSI colours on a black background.
+//     ColorFullGray: use black, white and two shades of gray for both the
+//                    characters and the background.
+//     ColorFull8: use the 8 ANSI colours for both the characters and the
+//                 background.
+//     ColorFull16 or "default": use the 16 ANSI colours for both the
+//                               characters and the background. This is the
+//                               default value.
+//
+// ColorList() returns the colour modes supported by the linked libcaca.
 //
 // If an error occurs the according errno is returned.
-func (di Dither) SetColor(str string) error {
-	ret, err := C.caca_set_dither_color(di.Di, C.CString(str))
+func (di Dither) SetColor(str DitherColor) error {
+	defer runtime.KeepAlive(di.h)
+
+	cStr := cString(string(str))
+	defer cFree(unsafe.Pointer(cStr))
+
+	ret, err := C.caca_set_dither_color(di.ptr(), cStr)
 
 	if ret == -1 {
-		return err
+		return wrapErr("Dither.SetColor", err)
 	}
 
 	return nil
 }
 
 // GetColor returns the current colour mode of the dither object.
-func (di Dither) GetColor() string {
-	return C.GoString(C.caca_get_dither_color(di.Di))
+func (di Dither) GetColor() DitherColor {
+	defer runtime.KeepAlive(di.h)
+
+	return DitherColor(C.GoString(C.caca_get_dither_color(di.ptr())))
+}
+
+// ColorList returns the colour modes supported by the linked libcaca.
+func (di Dither) ColorList() []DitherChoice {
+	defer runtime.KeepAlive(di.h)
+
+	return ditherChoices(C.caca_get_dither_color_list(di.ptr()))
 }
 
 // SetCharset tells the renderer which characters should be used to render the
-// dither. Valid values for str are:
+// dither. Valid values are:
+//
+//     CharsetASCII or "default": use only ASCII characters. This is the
+//                                default value.
+//     CharsetShades: use Unicode characters "U+2591 LIGHT SHADE",
+//                    "U+2592 MEDIUM SHADE" and "U+2593 DARK SHADE". These
+//                    characters are also present in the CP437 codepage
+//                    available on DOS and VGA.
+//     CharsetBlocks: use Unicode quarter-cell block combinations. These
+//                    characters are only found in the Unicode set.
 //
-//     "ascii" or "default": use only ASCII characters. This is the default
-//                           value.
-//     "shades": use Unicode characters "U+2591 LIGHT SHADE",
-//               "U+2592 MEDIUM SHADE" and "U+2593 DARK SHADE". These
-//               characters are also present in the CP437 codepage available
-//               on DOS and VGA.
-//     "blocks": use Unicode quarter-cell block combinations. These characters
-//               are only found in the Unicode set.
+// CharsetList() returns the character sets supported by the linked libcaca.
 //
 // If an error occurs the according errno is returned.
-func (di Dither) SetCharset(str string) error {
-	ret, err := C.caca_set_dither_charset(di.Di, C.CString(str))
+func (di Dither) SetCharset(str DitherCharset) error {
+	defer runtime.KeepAlive(di.h)
+
+	cStr := cString(string(str))
+	defer cFree(unsafe.Pointer(cStr))
+
+	ret, err := C.caca_set_dither_charset(di.ptr(), cStr)
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Dither.SetCharset", err)
 	}
 
 	return nil
 }
 
 // GetCharset returns the current character set of the dither object.
-func (di Dither) GetCharset() string {
-	return C.GoString(C.caca_get_dither_charset(di.Di))
+func (di Dither) GetCharset() DitherCharset {
+	defer runtime.KeepAlive(di.h)
+
+	return DitherCharset(C.GoString(C.caca_get_dither_charset(di.ptr())))
+}
+
+// CharsetList returns the character sets supported by the linked libcaca.
+func (di Dither) CharsetList() []DitherChoice {
+	defer runtime.KeepAlive(di.h)
+
+	return ditherChoices(C.caca_get_dither_charset_list(di.ptr()))
 }
 
 // SetAlgorithm tells the renderer which dithering algorithm should be used.
 // Dithering is necessary because the picture being rendered has usually far
-// more colours than the available palette. Valid values for str are:
+// more colours than the available palette. Valid values are:
+//
+//     DitherNone: no dithering is used, the nearest matching colour is used.
+//     DitherOrdered2: use a 2x2 Bayer matrix for dithering.
+//     DitherOrdered4: use a 4x4 Bayer matrix for dithering.
+//     DitherOrdered8: use a 8x8 Bayer matrix for dithering.
+//     DitherRandom: use random dithering.
+//     DitherFstein: use Floyd-Steinberg dithering. This is the default value.
+//
+// The algorithms DitherAtkinson, DitherJarvis, DitherStucki, DitherSierra,
+// DitherBurkes and DitherBlueNoise are only implemented by the Go dithering
+// engine. Selecting one of them makes Bitmap() use that engine, see
+// SetBackend().
 //
-//     "none": no dithering is used, the nearest matching colour is used.
-//     "ordered2": use a 2x2 Bayer matrix for dithering.
-//     "ordered4": use a 4x4 Bayer matrix for dithering.
-//     "ordered8": use a 8x8 Bayer matrix for dithering.
-//     "random": use random dithering.
-//     "fstein": use Floyd-Steinberg dithering. This is the default value.
+// AlgorithmList() returns the algorithms supported by the linked libcaca.
 //
 // If an error occurs the according errno is returned.
-func (di Dither) SetAlgorithm(str string) error {
-	ret, err := C.caca_set_dither_algorithm(di.Di, C.CString(str))
+func (di Dither) SetAlgorithm(str DitherAlgorithm) error {
+	defer runtime.KeepAlive(di.h)
+
+	if str.goOnly() {
+		di.mustLive()
+		di.h.goAlgo = str
+
+		return nil
+	}
+
+	cStr := cString(string(str))
+	defer cFree(unsafe.Pointer(cStr))
+
+	ret, err := C.caca_set_dither_algorithm(di.ptr(), cStr)
 
 	if int(ret) == -1 {
-		return err
+		return wrapErr("Dither.SetAlgorithm", err)
 	}
 
+	di.h.goAlgo = ""
+
 	return nil
 }
 
 // GetAlgorithm returns the current dithering algorithm of the dither object.
-func (di Dither) GetAlgorithm() string {
-	return C.GoString(C.caca_get_dither_algorithm(di.Di))
+func (di Dither) GetAlgorithm() DitherAlgorithm {
+	defer runtime.KeepAlive(di.h)
+
+	di.mustLive()
+
+	if di.h.goAlgo != "" {
+		return di.h.goAlgo
+	}
+
+	return DitherAlgorithm(C.GoString(C.caca_get_dither_algorithm(di.ptr())))
+}
+
+// AlgorithmList returns the dithering algorithms supported by the linked
+// libcaca.
+func (di Dither) AlgorithmList() []DitherChoice {
+	defer runtime.KeepAlive(di.h)
+
+	return ditherChoices(C.caca_get_dither_algorithm_list(di.ptr()))
 }
 
 // Bitmap dithers a bitmap at the given coordinates. The dither can be of any
 // size and will be stretched to the text area.
+//
+// The bitmap is rendered by libcaca, unless the Go backend was selected with
+// SetBackend() or the algorithm is only available in Go.
 func (di Dither) Bitmap(cv Canvas, x int, y int, w int, h int, pixels []byte) {
-	C.caca_dither_bitmap(cv.Cv, C.int(x), C.int(y), C.int(w), C.int(h), di.Di, unsafe.Pointer(&pixels[0]))
+	defer runtime.KeepAlive(di.h)
+	defer runtime.KeepAlive(cv.h)
+
+	di.mustLive()
+
+	if di.h.backend == DitherBackendGo || di.h.goAlgo != "" {
+		di.bitmapGo(cv, Rect{X: x, Y: y, Width: w, Height: h}, pixels)
+
+		return
+	}
+
+	if len(pixels) == 0 {
+		return
+	}
+
+	C.caca_dither_bitmap(cv.ptr(), C.int(x), C.int(y), C.int(w), C.int(h), di.ptr(), unsafe.Pointer(&pixels[0]))
 }
 
-// Free frees the memory allocated by CreateDither().
+// Free frees the memory allocated by CreateDither(). Calling Free() on an
+// already freed dither does nothing.
 func (di Dither) Free() {
-	C.caca_free_dither(di.Di)
+	if di.h == nil || di.h.di == nil {
+		return
+	}
+
+	C.caca_free_dither(di.h.di)
+	di.h.di = nil
+	runtime.SetFinalizer(di.h, nil)
+}
+
+// Close frees the dither like Free(). It makes Dither satisfy io.Closer and
+// always returns nil.
+func (di Dither) Close() error {
+	di.Free()
+
+	return nil
 }
@@ -0,0 +1,252 @@
+package caca
+
+// #cgo LDFLAGS: -lcaca
+// #include <caca.h>
+import "C"
+
+import "sync"
+
+// DitherColor is a colour mode accepted by Dither.SetColor().
+type DitherColor string
+
+// Colour modes supported by libcaca.
+const (
+	// ColorMono uses light gray on a black background.
+	ColorMono DitherColor = "mono"
+
+	// ColorGray uses white and two shades of gray on a black background.
+	ColorGray DitherColor = "gray"
+
+	// Color8 uses the 8 ANSI colours on a black background.
+	Color8 DitherColor = "8"
+
+	// Color16 uses the 16 ANSI colours on a black background.
+	Color16 DitherColor = "16"
+
+	// ColorFullGray uses black, white and two shades of gray for both the
+	// characters and the background.
+	ColorFullGray DitherColor = "fullgray"
+
+	// ColorFull8 uses the 8 ANSI colours for both the characters and the
+	// background.
+	ColorFull8 DitherColor = "full8"
+
+	// ColorFull16 uses the 16 ANSI colours for both the characters and the
+	// background. This is the default value.
+	ColorFull16 DitherColor = "full16"
+)
+
+// DitherCharset is a character set accepted by Dither.SetCharset().
+type DitherCharset string
+
+// Character sets supported by libcaca.
+const (
+	// CharsetASCII uses only ASCII characters. This is the default value.
+	CharsetASCII DitherCharset = "ascii"
+
+	// CharsetShades uses the Unicode light, medium and dark shade
+	// characters, which are also present in the CP437 codepage.
+	CharsetShades DitherCharset = "shades"
+
+	// CharsetBlocks uses Unicode quarter-cell block combinations.
+	CharsetBlocks DitherCharset = "blocks"
+)
+
+// DitherAlgorithm is a dithering algorithm accepted by Dither.SetAlgorithm().
+type DitherAlgorithm string
+
+// Dithering algorithms supported by libcaca.
+const (
+	// DitherNone uses the nearest matching colour without dithering.
+	DitherNone DitherAlgorithm = "none"
+
+	// DitherOrdered2 uses a 2x2 Bayer matrix.
+	DitherOrdered2 DitherAlgorithm = "ordered2"
+
+	// DitherOrdered4 uses a 4x4 Bayer matrix.
+	DitherOrdered4 DitherAlgorithm = "ordered4"
+
+	// DitherOrdered8 uses an 8x8 Bayer matrix.
+	DitherOrdered8 DitherAlgorithm = "ordered8"
+
+	// DitherRandom uses random dithering.
+	DitherRandom DitherAlgorithm = "random"
+
+	// DitherFstein uses Floyd-Steinberg dithering. This is the default
+	// value.
+	DitherFstein DitherAlgorithm = "fstein"
+)
+
+// Dithering algorithms only implemented by the Go dithering engine, see
+// Dither.SetBackend().
+const (
+	// DitherAtkinson uses Atkinson error diffusion, which only propagates
+	// three quarters of the error and keeps highlights and shadows crisp.
+	DitherAtkinson DitherAlgorithm = "atkinson"
+
+	// DitherJarvis uses Jarvis-Judice-Ninke error diffusion.
+	DitherJarvis DitherAlgorithm = "jarvis"
+
+	// DitherStucki uses Stucki error diffusion.
+	DitherStucki DitherAlgorithm = "stucki"
+
+	// DitherSierra uses three-row Sierra error diffusion.
+	DitherSierra DitherAlgorithm = "sierra"
+
+	// DitherBurkes uses Burkes error diffusion.
+	DitherBurkes DitherAlgorithm = "burkes"
+
+	// DitherBlueNoise uses a blue noise threshold matrix.
+	DitherBlueNoise DitherAlgorithm = "bluenoise"
+)
+
+// goOnly reports whether the algorithm is only implemented by the Go
+// dithering engine.
+func (a DitherAlgorithm) goOnly() bool {
+	switch a {
+	case DitherAtkinson, DitherJarvis, DitherStucki, DitherSierra, DitherBurkes, DitherBlueNoise:
+		return true
+	default:
+		return false
+	}
+}
+
+// DitherAntialias is an antialiasing method accepted by
+// Dither.SetAntialias().
+type DitherAntialias string
+
+// Antialiasing methods supported by libcaca.
+const (
+	// AntialiasNone disables antialiasing.
+	AntialiasNone DitherAntialias = "none"
+
+	// AntialiasPrefilter uses simple prefilter antialiasing. This is the
+	// default value.
+	AntialiasPrefilter DitherAntialias = "prefilter"
+)
+
+// DitherChoice is an entry of the lists returned by Dither.ColorList(),
+// Dither.CharsetList(), Dither.AlgorithmList() and Dither.AntialiasList().
+// Name can be converted to the matching type and passed to the setter.
+type DitherChoice struct {
+	Name        string
+	Description string
+}
+
+func ditherChoices(list **C.char) []DitherChoice {
+	strs := goStrings(list)
+	choices := make([]DitherChoice, 0, len(strs)/2)
+
+	for i := 0; i+1 < len(strs); i += 2 {
+		choices = append(choices, DitherChoice{Name: strs[i], Description: strs[i+1]})
+	}
+
+	return choices
+}
+
+// ditherLists caches the choices of the linked libcaca, which never change,
+// so that parsing a name does not query libcaca every time.
+var ditherLists struct {
+	once       sync.Once
+	colors     []DitherChoice
+	charsets   []DitherChoice
+	algorithms []DitherChoice
+	antialias  []DitherChoice
+}
+
+func loadDitherLists() {
+	ditherLists.once.Do(func() {
+		// libcaca only hands out the lists for a dither, although they do
+		// not depend on it.
+		di, err := CreateDither(32, 1, 1, 4, 0xff0000, 0xff00, 0xff, 0)
+		if err != nil {
+			return
+		}
+
+		defer di.Free()
+
+		ditherLists.colors = di.ColorList()
+		ditherLists.charsets = di.CharsetList()
+		ditherLists.algorithms = di.AlgorithmList()
+		ditherLists.antialias = di.AntialiasList()
+	})
+}
+
+func hasChoice(choices []DitherChoice, name string) bool {
+	for _, c := range choices {
+		if c.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseDitherColor checks that s names a colour mode of the linked libcaca,
+// as listed by Dither.ColorList(). "default" is accepted as ColorFull16.
+// Otherwise ErrInvalidArgument is returned.
+func ParseDitherColor(s string) (DitherColor, error) {
+	if s == "default" {
+		return ColorFull16, nil
+	}
+
+	loadDitherLists()
+
+	if !hasChoice(ditherLists.colors, s) {
+		return "", &Error{Op: "ParseDitherColor", Err: ErrInvalidArgument}
+	}
+
+	return DitherColor(s), nil
+}
+
+// ParseDitherCharset checks that s names a character set of the linked
+// libcaca, as listed by Dither.CharsetList(). "default" is accepted as
+// CharsetASCII. Otherwise ErrInvalidArgument is returned.
+func ParseDitherCharset(s string) (DitherCharset, error) {
+	if s == "default" {
+		return CharsetASCII, nil
+	}
+
+	loadDitherLists()
+
+	if !hasChoice(ditherLists.charsets, s) {
+		return "", &Error{Op: "ParseDitherCharset", Err: ErrInvalidArgument}
+	}
+
+	return DitherCharset(s), nil
+}
+
+// ParseDitherAlgorithm checks that s names a dithering algorithm of the linked
+// libcaca, as listed by Dither.AlgorithmList(), or one of the algorithms only
+// implemented by the Go dithering engine. "default" is accepted as
+// DitherFstein. Otherwise ErrInvalidArgument is returned.
+func ParseDitherAlgorithm(s string) (DitherAlgorithm, error) {
+	if s == "default" {
+		return DitherFstein, nil
+	}
+
+	loadDitherLists()
+
+	if a := DitherAlgorithm(s); !a.goOnly() && !hasChoice(ditherLists.algorithms, s) {
+		return "", &Error{Op: "ParseDitherAlgorithm", Err: ErrInvalidArgument}
+	}
+
+	return DitherAlgorithm(s), nil
+}
+
+// ParseDitherAntialias checks that s names an antialiasing method of the
+// linked libcaca, as listed by Dither.AntialiasList(). "default" is accepted
+// as AntialiasPrefilter. Otherwise ErrInvalidArgument is returned.
+func ParseDitherAntialias(s string) (DitherAntialias, error) {
+	if s == "default" {
+		return AntialiasPrefilter, nil
+	}
+
+	loadDitherLists()
+
+	if !hasChoice(ditherLists.antialias, s) {
+		return "", &Error{Op: "ParseDitherAntialias", Err: ErrInvalidArgument}
+	}
+
+	return DitherAntialias(s), nil
+}
@@ -0,0 +1,105 @@
+package caca
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDither(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) (string, error)
+		in    string
+		want  string
+		ok    bool
+	}{
+		{"color", parseColor, "mono", "mono", true},
+		{"color", parseColor, "fullgray", "fullgray", true},
+		{"color", parseColor, "default", "full16", true},
+		{"color", parseColor, "full32", "", false},
+		{"color", parseColor, "", "", false},
+		{"charset", parseCharset, "blocks", "blocks", true},
+		{"charset", parseCharset, "default", "ascii", true},
+		{"charset", parseCharset, "ASCII", "", false},
+		{"algorithm", parseAlgorithm, "ordered8", "ordered8", true},
+		{"algorithm", parseAlgorithm, "atkinson", "atkinson", true},
+		{"algorithm", parseAlgorithm, "bluenoise", "bluenoise", true},
+		{"algorithm", parseAlgorithm, "default", "fstein", true},
+		{"algorithm", parseAlgorithm, "ordered16", "", false},
+		{"antialias", parseAntialias, "none", "none", true},
+		{"antialias", parseAntialias, "default", "prefilter", true},
+		{"antialias", parseAntialias, "supersample", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.parse(tt.in)
+		if tt.ok && (err != nil || got != tt.want) {
+			t.Errorf("parse %s %q = %q, %v, want %q", tt.name, tt.in, got, err, tt.want)
+		}
+
+		if !tt.ok && !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("parse %s %q error %v, want %v", tt.name, tt.in, err, ErrInvalidArgument)
+		}
+	}
+}
+
+// TestParseDitherMatchesLists checks that every choice libcaca lists is
+// accepted.
+func TestParseDitherMatchesLists(t *testing.T) {
+	di, err := CreateDither(32, 1, 1, 4, 0xff0000, 0xff00, 0xff, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer di.Free()
+
+	lists := []struct {
+		name    string
+		parse   func(string) (string, error)
+		choices []DitherChoice
+	}{
+		{"color", parseColor, di.ColorList()},
+		{"charset", parseCharset, di.CharsetList()},
+		{"algorithm", parseAlgorithm, di.AlgorithmList()},
+		{"antialias", parseAntialias, di.AntialiasList()},
+	}
+
+	for _, l := range lists {
+		if len(l.choices) == 0 {
+			t.Errorf("%s list is empty", l.name)
+		}
+
+		for _, c := range l.choices {
+			if c.Name == "default" {
+				continue
+			}
+
+			if got, err := l.parse(c.Name); err != nil || got != c.Name {
+				t.Errorf("parse %s %q = %q, %v, want %q", l.name, c.Name, got, err, c.Name)
+			}
+		}
+	}
+}
+
+func parseColor(s string) (string, error) {
+	c, err := ParseDitherColor(s)
+
+	return string(c), err
+}
+
+func parseCharset(s string) (string, error) {
+	c, err := ParseDitherCharset(s)
+
+	return string(c), err
+}
+
+func parseAlgorithm(s string) (string, error) {
+	a, err := ParseDitherAlgorithm(s)
+
+	return string(a), err
+}
+
+func parseAntialias(s string) (string, error) {
+	a, err := ParseDitherAntialias(s)
+
+	return string(a), err
+}
@@ -0,0 +1,157 @@
+package caca
+
+// #cgo LDFLAGS: -lcaca
+// #include <caca.h>
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Driver is a display driver compiled into the linked libcaca.
+type Driver struct {
+	Name        string
+	Description string
+}
+
+// DriverCaps describes which optional display features a driver supports.
+type DriverCaps struct {
+	// Title is set if Display.SetTitle() changes the window or terminal
+	// title.
+	Title bool
+
+	// Mouse is set if the driver reports mouse events and Display.SetMouse()
+	// can show or hide the pointer.
+	Mouse bool
+
+	// Cursor is set if Display.SetCursor() can show or hide the cursor.
+	Cursor bool
+
+	// Resize is set if the driver sends EventResize events when the output
+	// device changes size.
+	Resize bool
+
+	// PixelSize is set if Display.GetWidth() and Display.GetHeight() report
+	// the real size of the output in pixels. Other drivers return an
+	// estimate based on the canvas size.
+	PixelSize bool
+}
+
+// driverCaps is a static table of the capabilities of the drivers shipped
+// with libcaca, written down from their respective driver sources. libcaca
+// has no API to query them, so the table is not checked against the linked
+// library: drivers it does not list report no capabilities, and it must be
+// updated by hand when a libcaca release changes what a driver supports.
+var driverCaps = map[string]DriverCaps{
+	"cocoa":   {Title: true, Mouse: true, Cursor: true, Resize: true, PixelSize: true},
+	"conio":   {Cursor: true},
+	"gl":      {Title: true, Mouse: true, Cursor: true, Resize: true, PixelSize: true},
+	"ncurses": {Title: true, Mouse: true, Cursor: true, Resize: true},
+	"null":    {},
+	"raw":     {},
+	"slang":   {Title: true, Mouse: true, Cursor: true, Resize: true},
+	"vga":     {Cursor: true},
+	"win32":   {Title: true, Mouse: true, Cursor: true, Resize: true},
+	"x11":     {Title: true, Mouse: true, Cursor: true, Resize: true, PixelSize: true},
+}
+
+// Drivers returns the display drivers compiled into the linked libcaca, in
+// the order libcaca reports them.
+func Drivers() []Driver {
+	list := goStrings(C.caca_get_display_driver_list())
+	drivers := make([]Driver, 0, len(list)/2)
+
+	for i := 0; i+1 < len(list); i += 2 {
+		drivers = append(drivers, Driver{Name: list[i], Description: list[i+1]})
+	}
+
+	return drivers
+}
+
+// HasDriver reports whether the driver with the given name is compiled into
+// the linked libcaca.
+func HasDriver(name string) bool {
+	for _, drv := range Drivers() {
+		if drv.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Caps returns the capabilities of the driver. Drivers unknown to this package
+// report no capabilities.
+func (drv Driver) Caps() DriverCaps {
+	return driverCaps[drv.Name]
+}
+
+// PickDriver returns the first driver of the preference list that is compiled
+// into the linked libcaca, together with a human-readable explanation of the
+// choice, such as `using "slang": "ncurses" is not available`.
+//
+// If none of the preferred drivers is available, ErrNoDevice is returned.
+func PickDriver(preferred ...string) (Driver, string, error) {
+	available := make(map[string]Driver)
+	for _, drv := range Drivers() {
+		available[drv.Name] = drv
+	}
+
+	var missing []string
+
+	for _, name := range preferred {
+		drv, ok := available[name]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%q", name))
+
+			continue
+		}
+
+		reason := fmt.Sprintf("using %q", name)
+		if len(missing) > 0 {
+			reason += ": " + strings.Join(missing, ", ") + notAvailable(len(missing))
+		}
+
+		return drv, reason, nil
+	}
+
+	reason := "no driver available"
+	if len(missing) > 0 {
+		reason += ": " + strings.Join(missing, ", ") + notAvailable(len(missing))
+	}
+
+	return Driver{}, reason, &Error{Op: "PickDriver", Err: ErrNoDevice}
+}
+
+func notAvailable(n int) string {
+	if n == 1 {
+		return " is not available"
+	}
+
+	return " are not available"
+}
+
+// Caps returns the capabilities of the display's current driver.
+func (d Display) Caps() DriverCaps {
+	return driverCaps[d.GetDriver()]
+}
+
+// GetWidth returns the width of the display's output in pixels. Drivers
+// without a notion of pixels, such as the terminal drivers, return an
+// estimate based on the canvas width. See DriverCaps.PixelSize.
+func (d Display) GetWidth() int {
+	defer runtime.KeepAlive(d.h)
+
+	return int(C.caca_get_display_width(d.ptr()))
+}
+
+// GetHeight returns the height of the display's output in pixels. Drivers
+// without a notion of pixels, such as the terminal drivers, return an
+// estimate based on the canvas height. See DriverCaps.PixelSize.
+func (d Display) GetHeight() int {
+	defer runtime.KeepAlive(d.h)
+
+	return int(C.caca_get_display_height(d.ptr()))
+}
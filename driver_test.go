@@ -0,0 +1,82 @@
+package caca
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPickDriver(t *testing.T) {
+	if !HasDriver("null") || !HasDriver("raw") {
+		t.Skip("null or raw driver not available")
+	}
+
+	tests := []struct {
+		preferred []string
+		want      string
+		reason    string
+	}{
+		{[]string{"null"}, "null", `using "null"`},
+		{[]string{"raw", "null"}, "raw", `using "raw"`},
+		{[]string{"null", "raw"}, "null", `using "null"`},
+		{[]string{"nosuch", "null", "raw"}, "null", `using "null": "nosuch" is not available`},
+		{[]string{"nosuch", "other", "raw", "null"}, "raw", `using "raw": "nosuch", "other" are not available`},
+		{[]string{"nosuch", "null", "other"}, "null", `using "null": "nosuch" is not available`},
+	}
+
+	for _, tt := range tests {
+		drv, reason, err := PickDriver(tt.preferred...)
+		if err != nil {
+			t.Errorf("PickDriver(%q): %v", tt.preferred, err)
+
+			continue
+		}
+
+		if drv.Name != tt.want {
+			t.Errorf("PickDriver(%q) picked %q, want %q", tt.preferred, drv.Name, tt.want)
+		}
+
+		if drv.Description == "" {
+			t.Errorf("PickDriver(%q) returned a driver without a description", tt.preferred)
+		}
+
+		if reason != tt.reason {
+			t.Errorf("PickDriver(%q) reason %q, want %q", tt.preferred, reason, tt.reason)
+		}
+	}
+}
+
+func TestPickDriverNoneAvailable(t *testing.T) {
+	tests := []struct {
+		preferred []string
+		reason    string
+	}{
+		{nil, "no driver available"},
+		{[]string{"nosuch"}, `no driver available: "nosuch" is not available`},
+		{[]string{"nosuch", "other"}, `no driver available: "nosuch", "other" are not available`},
+	}
+
+	for _, tt := range tests {
+		drv, reason, err := PickDriver(tt.preferred...)
+		if !errors.Is(err, ErrNoDevice) {
+			t.Errorf("PickDriver(%q) error %v, want %v", tt.preferred, err, ErrNoDevice)
+		}
+
+		if drv != (Driver{}) {
+			t.Errorf("PickDriver(%q) picked %+v, want no driver", tt.preferred, drv)
+		}
+
+		if reason != tt.reason {
+			t.Errorf("PickDriver(%q) reason %q, want %q", tt.preferred, reason, tt.reason)
+		}
+	}
+}
+
+func TestDriverCaps(t *testing.T) {
+	if caps := (Driver{Name: "nosuch"}).Caps(); caps != (DriverCaps{}) {
+		t.Errorf("unknown driver has capabilities %+v, want none", caps)
+	}
+
+	if caps := (Driver{Name: "x11"}).Caps(); !caps.Title || !caps.PixelSize {
+		t.Errorf("x11 capabilities %+v, want Title and PixelSize", caps)
+	}
+}
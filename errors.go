@@ -0,0 +1,109 @@
+package caca
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Sentinel errors returned by the bindings. They are always wrapped in an
+// *Error carrying the name of the failed operation, use errors.Is() to check
+// for them.
+var (
+	// ErrInvalidArgument is returned when libcaca rejects an argument (EINVAL).
+	ErrInvalidArgument = errors.New("invalid argument")
+
+	// ErrOutOfMemory is returned when libcaca could not allocate memory
+	// (ENOMEM).
+	ErrOutOfMemory = errors.New("out of memory")
+
+	// ErrDisplayAttached is returned when an operation is not allowed because
+	// a display is attached to the canvas (EBUSY).
+	ErrDisplayAttached = errors.New("canvas is attached to a display")
+
+	// ErrOutOfRange is returned when an index or coordinate is outside the
+	// accepted range (ERANGE).
+	ErrOutOfRange = errors.New("value out of range")
+
+	// ErrNotSupported is returned when the requested feature is not available
+	// in the linked libcaca or the current driver (ENOSYS).
+	ErrNotSupported = errors.New("operation not supported")
+
+	// ErrNoDevice is returned when no display driver could be initialised
+	// (ENODEV).
+	ErrNoDevice = errors.New("no display driver available")
+
+	// ErrFormatUnsupported is returned by the import and export functions when
+	// the requested format is unknown.
+	ErrFormatUnsupported = errors.New("unsupported format")
+
+	// ErrNotOwned is returned when freeing a canvas that is owned by a
+	// display.
+	ErrNotOwned = errors.New("canvas is owned by a display")
+
+	// ErrOperationFailed is returned when libcaca reports a failure without
+	// setting errno.
+	ErrOperationFailed = errors.New("operation failed")
+)
+
+// Error is the error type returned by all bindings. Err is one of the
+// sentinel errors above, or the raw errno if it has no sentinel equivalent.
+//
+// Besides the sentinel, errors.Is() also matches the original errno, so
+// checking for syscall.EINVAL keeps working.
+type Error struct {
+	Op    string
+	Err   error
+	Errno syscall.Errno
+}
+
+func (e *Error) Error() string {
+	return "caca: " + e.Op + ": " + e.Err.Error()
+}
+
+// Unwrap returns the sentinel error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the errno that caused the error.
+func (e *Error) Is(target error) bool {
+	errno, ok := target.(syscall.Errno)
+
+	return ok && e.Errno != 0 && errno == e.Errno
+}
+
+var errnoErrors = map[syscall.Errno]error{
+	syscall.EINVAL: ErrInvalidArgument,
+	syscall.ENOMEM: ErrOutOfMemory,
+	syscall.EBUSY:  ErrDisplayAttached,
+	syscall.ERANGE: ErrOutOfRange,
+	syscall.ENOSYS: ErrNotSupported,
+	syscall.ENODEV: ErrNoDevice,
+}
+
+// wrapErr converts the error returned by a cgo call into an *Error for the
+// given operation. err may be nil if libcaca did not set errno.
+func wrapErr(op string, err error) error {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) || errno == 0 {
+		return &Error{Op: op, Err: ErrOperationFailed}
+	}
+
+	if sentinel, ok := errnoErrors[errno]; ok {
+		return &Error{Op: op, Err: sentinel, Errno: errno}
+	}
+
+	return &Error{Op: op, Err: errno, Errno: errno}
+}
+
+// wrapFormatErr is like wrapErr, but reports EINVAL as ErrFormatUnsupported.
+// It is used by the import and export functions, where EINVAL means that the
+// requested format is unknown.
+func wrapFormatErr(op string, err error) error {
+	e := wrapErr(op, err).(*Error)
+	if e.Errno == syscall.EINVAL {
+		e.Err = ErrFormatUnsupported
+	}
+
+	return e
+}
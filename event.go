@@ -14,6 +14,68 @@ type Event struct {
 	Ev *C.struct_caca_event
 }
 
+// EventData is a decoded copy of a libcaca event. Unlike Event it does not
+// refer to any C memory and can be copied and stored freely.
+//
+// Only the fields that are meaningful for the event's type are set, see
+// Event.GetType() for which fields belong to which event types. All other
+// fields are left at their zero value.
+type EventData struct {
+	Type         int
+	KeyCh        int
+	KeyUTF32     rune
+	KeyUTF8      string
+	MouseButton  int
+	MouseX       int
+	MouseY       int
+	ResizeWidth  int
+	ResizeHeight int
+}
+
+// NewEvent allocates a new empty event structure that can be filled by
+// Display.GetEvent(). The memory is owned by the package and must be released
+// with Free() once the event is no longer needed.
+func NewEvent() Event {
+	cPtr := cCalloc(1, C.sizeof_struct_caca_event)
+
+	return Event{Ev: (*C.struct_caca_event)(cPtr)}
+}
+
+// Free frees the memory allocated by NewEvent(). Calling Free on an event that
+// was not allocated by NewEvent() does nothing.
+func (e *Event) Free() {
+	if e.Ev == nil {
+		return
+	}
+
+	cFree(unsafe.Pointer(e.Ev))
+	e.Ev = nil
+}
+
+// Data decodes the event into an EventData value. Only the accessors that are
+// valid for the event's type are called, so this function is safe to call on
+// any event filled by Display.GetEvent().
+func (e Event) Data() EventData {
+	data := EventData{Type: e.GetType()}
+
+	switch data.Type {
+	case EventKeyPress, EventKeyRelease:
+		data.KeyCh = e.GetKeyCh()
+		data.KeyUTF32 = rune(e.GetKeyUTF32())
+		data.KeyUTF8 = e.GetKeyUTF8()
+	case EventMousePress, EventMouseRelease:
+		data.MouseButton = e.GetMouseButton()
+	case EventMouseMotion:
+		data.MouseX = e.GetMouseButtonX()
+		data.MouseY = e.GetMouseButtonY()
+	case EventResize:
+		data.ResizeWidth = e.GetResizeWidth()
+		data.ResizeHeight = e.GetResizeHeight()
+	}
+
+	return data
+}
+
 // GetType returns the type of the event. This function may always be called on
 // an event after display.GetEvent() was called, and its return value indicates
 // which other functions may be called:
@@ -52,18 +114,19 @@ func (e Event) GetKeyUTF32() uint32 {
 }
 
 // GetKeyUTF8 returns the UTF-8 value for an event's key if it resolves to a
-// printable character. Up to 6 UTF-8 bytes and a null termination are returned.
+// printable character. Up to 6 UTF-8 bytes are returned, the null termination
+// is stripped.
 //
 // This function never fails, but must only be called with a valid event of type
 // CACA_EVENT_KEY_PRESS or CACA_EVENT_KEY_RELEASE, or the results will be
 // undefined. See GetType() for more information.
 func (e Event) GetKeyUTF8() string {
-	cBuf := [7]C.char{}
+	// caca_get_event_key_utf8() always copies the full 8-byte buffer of the
+	// event.
+	cBuf := [8]C.char{}
 	C.caca_get_event_key_utf8(e.Ev, &cBuf[0])
 
-	goBuf := (*[7]byte)(unsafe.Pointer(&cBuf[0]))[:7:7]
-
-	return string(goBuf)
+	return C.GoString(&cBuf[0])
 }
 
 // GetMouseButton returns the mouse button index for the event.
@@ -0,0 +1,169 @@
+package caca
+
+// #cgo LDFLAGS: -lcaca
+// #include <caca.h>
+import "C"
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// eventPollTimeout is the timeout in microseconds used for every single
+// caca_get_event() call made by Events() and WaitEvent(). It bounds how long
+// it takes for them to notice a cancelled context or a freed display.
+const eventPollTimeout = 10000
+
+// pumpGroup tracks the event pumps running on a single display so that Free()
+// can stop them before the display is destroyed.
+type pumpGroup struct {
+	stop    chan struct{}
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// pumps holds the pump groups of all displays. Its mutex also guards the
+// transition of a display handle to freed, see displayHandle.free(), so that
+// no pump can be registered on a display that is being freed.
+var pumps = struct {
+	sync.Mutex
+	groups map[*displayHandle]*pumpGroup
+}{groups: make(map[*displayHandle]*pumpGroup)}
+
+// closedStop is returned by startPump() for displays that are freed or being
+// freed.
+var closedStop = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+
+	return ch
+}()
+
+// startPump registers a new event pump for the display and returns the channel
+// that is closed when the display is freed. done must be called when the pump
+// exits. If the display is already freed or being freed, the returned channel
+// is closed.
+func startPump(h *displayHandle) (stop <-chan struct{}, done func()) {
+	pumps.Lock()
+	defer pumps.Unlock()
+
+	g, ok := pumps.groups[h]
+
+	if h.dp == nil || (ok && g.stopped) {
+		return closedStop, func() {}
+	}
+
+	if !ok {
+		g = &pumpGroup{stop: make(chan struct{})}
+		pumps.groups[h] = g
+	}
+
+	g.wg.Add(1)
+
+	return g.stop, g.wg.Done
+}
+
+// stopPumps signals all event pumps of the display to exit and waits until
+// they did. The display stays marked as stopping until displayHandle.free()
+// removes it, so pumps started in the meantime exit immediately.
+func stopPumps(h *displayHandle) {
+	pumps.Lock()
+
+	g, ok := pumps.groups[h]
+	if !ok {
+		g = &pumpGroup{stop: closedStop}
+		pumps.groups[h] = g
+	} else {
+		close(g.stop)
+	}
+
+	g.stopped = true
+	pumps.Unlock()
+
+	g.wg.Wait()
+}
+
+// Events starts an event pump on the display and returns a channel on which
+// all events matching eventMask are delivered. The pump runs in its own
+// goroutine locked to an OS thread.
+//
+// The channel is closed once ctx is cancelled or the display is freed with
+// Free(). Events that arrive while nobody is receiving from the channel are
+// held back until they can be delivered, so slow receivers do not lose
+// events.
+//
+// libcaca is not thread-safe: while the pump is running, no other function
+// reading events from the display (such as GetEvent() or WaitEvent()) should
+// be called.
+func (d Display) Events(ctx context.Context, eventMask int) <-chan EventData {
+	ch := make(chan EventData)
+	d.mustLive()
+	stop, done := startPump(d.h)
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		defer done()
+		defer close(ch)
+
+		ev := NewEvent()
+		defer ev.Free()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			default:
+			}
+
+			data, ok := d.GetEvent(eventMask, &ev, eventPollTimeout)
+			if !ok {
+				continue
+			}
+
+			select {
+			case ch <- data:
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// WaitEvent blocks until an event matching eventMask is received and returns
+// it. If ctx is cancelled or its deadline expires first, an empty EventData and
+// the context's error are returned. If the display is freed while waiting,
+// context.Canceled is returned.
+func (d Display) WaitEvent(ctx context.Context, eventMask int) (EventData, error) {
+	d.mustLive()
+	stop, done := startPump(d.h)
+	defer done()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	ev := NewEvent()
+	defer ev.Free()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return EventData{}, ctx.Err()
+		case <-stop:
+			return EventData{}, context.Canceled
+		default:
+		}
+
+		if data, ok := d.GetEvent(eventMask, &ev, eventPollTimeout); ok {
+			return data, nil
+		}
+	}
+}
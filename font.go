@@ -0,0 +1,225 @@
+package caca
+
+// #cgo LDFLAGS: -lcaca
+// #include <caca.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+	"image"
+	"image/png"
+	"io"
+	"runtime"
+	"unsafe"
+)
+
+// Font is a handle to a libcaca bitmap font, used to render canvases to
+// images. Copies of a Font refer to the same underlying font, so freeing any
+// copy frees it for all of them. Using a font after it was freed panics.
+//
+// A font that is never freed is destroyed by a finalizer once it becomes
+// unreachable.
+type Font struct {
+	h *fontHandle
+}
+
+type fontHandle struct {
+	f    *C.struct_caca_font
+	data unsafe.Pointer
+}
+
+// UnicodeBlock is a range of Unicode characters covered by a font. Start is
+// inclusive, End is exclusive.
+type UnicodeBlock struct {
+	Start, End rune
+}
+
+// free destroys the font and releases the font data if it was loaded from
+// memory.
+func (h *fontHandle) free() {
+	if h.f == nil {
+		return
+	}
+
+	C.caca_free_font(h.f)
+	h.f = nil
+
+	if h.data != nil {
+		cFree(h.data)
+		h.data = nil
+	}
+}
+
+func newFont(cPtr *C.struct_caca_font, data unsafe.Pointer) Font {
+	h := &fontHandle{f: cPtr, data: data}
+	runtime.SetFinalizer(h, (*fontHandle).free)
+
+	return Font{h: h}
+}
+
+// ptr returns the underlying C font and panics if the font was freed.
+func (f Font) ptr() *C.struct_caca_font {
+	if f.h == nil || f.h.f == nil {
+		panic("caca: use of freed or uninitialised Font")
+	}
+
+	return f.h.f
+}
+
+// Fonts returns the names of the fonts built into the linked libcaca, such as
+// "Monospace 9" and "Monospace Bold 12".
+func Fonts() []string {
+	return goStrings(C.caca_get_font_list())
+}
+
+// LoadFont loads one of the fonts built into libcaca by name. See Fonts() for
+// the available names.
+//
+// If an error occurs the according errno is returned.
+func LoadFont(name string) (Font, error) {
+	cName := cString(name)
+	defer cFree(unsafe.Pointer(cName))
+
+	cPtr, err := C.caca_load_font(unsafe.Pointer(cName), 0)
+
+	if cPtr == nil {
+		return Font{}, wrapErr("LoadFont", err)
+	}
+
+	return newFont(cPtr, nil), nil
+}
+
+// LoadFontData loads a font from its internal libcaca representation, as
+// produced by the caca-makefont tool. The data is copied, so the slice can be
+// reused once the function returns.
+//
+// If an error occurs the according errno is returned.
+func LoadFontData(data []byte) (Font, error) {
+	if len(data) == 0 {
+		return Font{}, &Error{Op: "LoadFontData", Err: ErrInvalidArgument}
+	}
+
+	cData := cBytes(data)
+
+	cPtr, err := C.caca_load_font(cData, C.size_t(len(data)))
+
+	if cPtr == nil {
+		cFree(cData)
+
+		return Font{}, wrapErr("LoadFontData", err)
+	}
+
+	return newFont(cPtr, cData), nil
+}
+
+// GetWidth returns the maximum glyph width of the font, in pixels.
+func (f Font) GetWidth() int {
+	defer runtime.KeepAlive(f.h)
+
+	return int(C.caca_get_font_width(f.ptr()))
+}
+
+// GetHeight returns the maximum glyph height of the font, in pixels.
+func (f Font) GetHeight() int {
+	defer runtime.KeepAlive(f.h)
+
+	return int(C.caca_get_font_height(f.ptr()))
+}
+
+// Blocks returns the Unicode blocks covered by the font.
+func (f Font) Blocks() []UnicodeBlock {
+	defer runtime.KeepAlive(f.h)
+
+	var blocks []UnicodeBlock
+
+	p := C.caca_get_font_blocks(f.ptr())
+	if p == nil {
+		return blocks
+	}
+
+	// The list holds start and end pairs and is terminated by a zero pair.
+	// The first block usually starts at zero, so only the end is checked.
+	list := (*[maxCells]C.uint32_t)(unsafe.Pointer(p))
+	for i := 0; list[i+1] != 0; i += 2 {
+		blocks = append(blocks, UnicodeBlock{Start: rune(list[i]), End: rune(list[i+1])})
+	}
+
+	return blocks
+}
+
+// Covers reports whether the font has a glyph for the character.
+func (f Font) Covers(ch rune) bool {
+	for _, b := range f.Blocks() {
+		if ch >= b.Start && ch < b.End {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Free frees the font. Calling Free() on an already freed font does nothing.
+func (f Font) Free() {
+	if f.h == nil || f.h.f == nil {
+		return
+	}
+
+	f.h.free()
+	runtime.SetFinalizer(f.h, nil)
+}
+
+// Close frees the font like Free(). It makes Font satisfy io.Closer and always
+// returns nil.
+func (f Font) Close() error {
+	f.Free()
+
+	return nil
+}
+
+// RenderCanvas renders the current frame of the canvas with the given font.
+// The image is GetWidth()*f.GetWidth() pixels wide and
+// GetHeight()*f.GetHeight() pixels high.
+//
+// If an error occurs the according errno is returned.
+func RenderCanvas(cv Canvas, f Font) (*image.RGBA, error) {
+	defer runtime.KeepAlive(cv.h)
+	defer runtime.KeepAlive(f.h)
+
+	w, h := cv.GetWidth()*f.GetWidth(), cv.GetHeight()*f.GetHeight()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	if w == 0 || h == 0 {
+		return img, nil
+	}
+
+	buf := make([]byte, 4*w*h)
+
+	ret, err := C.caca_render_canvas(cv.ptr(), f.ptr(), unsafe.Pointer(&buf[0]), C.int(w), C.int(h), C.int(4*w))
+
+	if int(ret) == -1 {
+		return nil, wrapErr("RenderCanvas", err)
+	}
+
+	// libcaca stores non-premultiplied pixels as A, R, G and B bytes,
+	// image.RGBA stores premultiplied R, G, B and A bytes.
+	for i := 0; i < len(buf); i += 4 {
+		a := uint32(buf[i])
+		img.Pix[i+0] = uint8(uint32(buf[i+1]) * a / 0xff)
+		img.Pix[i+1] = uint8(uint32(buf[i+2]) * a / 0xff)
+		img.Pix[i+2] = uint8(uint32(buf[i+3]) * a / 0xff)
+		img.Pix[i+3] = uint8(a)
+	}
+
+	return img, nil
+}
+
+// WritePNG renders the current frame of the canvas with the given font, like
+// RenderCanvas() does, and writes it to w as a PNG image.
+func WritePNG(w io.Writer, cv Canvas, f Font) error {
+	img, err := RenderCanvas(cv, f)
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(w, img)
+}
@@ -0,0 +1,73 @@
+package caca
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRenderCanvasPremultiplies(t *testing.T) {
+	fonts := Fonts()
+	if len(fonts) == 0 {
+		t.Skip("no built-in fonts")
+	}
+
+	f, err := LoadFont(fonts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Free()
+
+	cv, err := CreateCanvas(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	// A space only shows the background, a translucent orange.
+	cv.SetAttr(NewAttrARGB(0xffff, 0x8f80, 0))
+	cv.PutChar(0, 0, ' ')
+
+	argb := cv.GetAttr(0, 0).ARGB64()
+	if argb[0] == 0 || argb[0] == 0xf {
+		t.Fatalf("background alpha %#x is not translucent", argb[0])
+	}
+
+	// libcaca expands the 4-bit components of the background to 8 bits,
+	// which image.RGBA stores premultiplied by alpha.
+	a := uint32(argb[0]) * 0x11
+	want := color.RGBA{
+		R: uint8(uint32(argb[1]) * 0x11 * a / 0xff),
+		G: uint8(uint32(argb[2]) * 0x11 * a / 0xff),
+		B: uint8(uint32(argb[3]) * 0x11 * a / 0xff),
+		A: uint8(a),
+	}
+
+	img, err := RenderCanvas(cv, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != f.GetWidth() || h != f.GetHeight() {
+		t.Errorf("image is %dx%d, want %dx%d", w, h, f.GetWidth(), f.GetHeight())
+	}
+
+	if got := img.RGBAAt(0, 0); got != want {
+		t.Errorf("pixel (0, 0) = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePNG(&buf, cv, f); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := color.NRGBAModel.Convert(decoded.At(0, 0)), color.NRGBAModel.Convert(want); got != want {
+		t.Errorf("PNG pixel (0, 0) = %v, want %v", got, want)
+	}
+}
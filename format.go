@@ -0,0 +1,235 @@
+package caca
+
+// #cgo LDFLAGS: -lcaca
+// #include <caca.h>
+import "C"
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Format identifies a file format understood by the import and export
+// functions.
+type Format string
+
+// Formats known to libcaca. Not every format can be both imported and
+// exported, use ImportFormats() and ExportFormats() to find out what the
+// linked libcaca supports.
+const (
+	// FormatAuto lets the import functions autodetect the format. It
+	// cannot be used for exporting.
+	FormatAuto Format = ""
+
+	FormatCaca  Format = "caca"
+	FormatANSI  Format = "ansi"
+	FormatUTF8  Format = "utf8"
+	FormatHTML  Format = "html"
+	FormatHTML3 Format = "html3"
+	FormatIRC   Format = "irc"
+	FormatPS    Format = "ps"
+	FormatSVG   Format = "svg"
+	FormatTGA   Format = "tga"
+	FormatTroff Format = "troff"
+	FormatBin   Format = "bin"
+	FormatText  Format = "text"
+)
+
+// FormatInfo describes a format supported by the linked libcaca.
+type FormatInfo struct {
+	Format      Format
+	Description string
+}
+
+// extFormats maps file name extensions to formats that libcaca can export.
+var extFormats = map[string]Format{
+	".caca":  FormatCaca,
+	".cv":    FormatCaca,
+	".ans":   FormatANSI,
+	".ansi":  FormatANSI,
+	".utf8":  FormatUTF8,
+	".html":  FormatHTML,
+	".htm":   FormatHTML,
+	".irc":   FormatIRC,
+	".ps":    FormatPS,
+	".eps":   FormatPS,
+	".svg":   FormatSVG,
+	".tga":   FormatTGA,
+	".troff": FormatTroff,
+	".tr":    FormatTroff,
+}
+
+// importExtFormats maps file name extensions to the import-only formats.
+var importExtFormats = map[string]Format{
+	".bin": FormatBin,
+	".txt": FormatText,
+	".asc": FormatText,
+}
+
+// mimeFormats maps MIME types to formats that libcaca can export.
+var mimeFormats = map[string]Format{
+	"application/x-caca":     FormatCaca,
+	"text/x-ansi":            FormatANSI,
+	"text/html":              FormatHTML,
+	"application/postscript": FormatPS,
+	"image/svg+xml":          FormatSVG,
+	"image/x-tga":            FormatTGA,
+	"image/x-targa":          FormatTGA,
+	"text/troff":             FormatTroff,
+	"text/x-troff":           FormatTroff,
+}
+
+// importMIMEFormats maps MIME types to the import-only formats.
+var importMIMEFormats = map[string]Format{
+	"text/plain": FormatText,
+}
+
+// formatLists caches the import and export lists of the linked libcaca,
+// which never change, so that checking a format does not query libcaca on
+// every import and export.
+var formatLists struct {
+	once    sync.Once
+	imports []FormatInfo
+	exports []FormatInfo
+}
+
+func loadFormatLists() {
+	formatLists.once.Do(func() {
+		formatLists.imports = formatInfos(C.caca_get_import_list())
+		formatLists.exports = formatInfos(C.caca_get_export_list())
+	})
+}
+
+// ImportFormats returns the formats the linked libcaca can import.
+func ImportFormats() []FormatInfo {
+	loadFormatLists()
+
+	return append([]FormatInfo(nil), formatLists.imports...)
+}
+
+// ExportFormats returns the formats the linked libcaca can export.
+func ExportFormats() []FormatInfo {
+	loadFormatLists()
+
+	return append([]FormatInfo(nil), formatLists.exports...)
+}
+
+func formatInfos(list **C.char) []FormatInfo {
+	strs := goStrings(list)
+	infos := make([]FormatInfo, 0, len(strs)/2)
+
+	for i := 0; i+1 < len(strs); i += 2 {
+		infos = append(infos, FormatInfo{Format: Format(strs[i]), Description: strs[i+1]})
+	}
+
+	return infos
+}
+
+// CanImport reports whether the linked libcaca can import the format.
+// FormatAuto is always accepted.
+func (f Format) CanImport() bool {
+	loadFormatLists()
+
+	return f == FormatAuto || hasFormat(formatLists.imports, f)
+}
+
+// CanExport reports whether the linked libcaca can export the format.
+func (f Format) CanExport() bool {
+	loadFormatLists()
+
+	return hasFormat(formatLists.exports, f)
+}
+
+func hasFormat(infos []FormatInfo, f Format) bool {
+	for _, info := range infos {
+		if info.Format == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FormatFromFilename picks a format to export to from the extension of the
+// given file name. The comparison is case-insensitive. If the extension is
+// unknown, FormatAuto and false are returned.
+//
+// Extensions of import-only formats, such as ".txt" for FormatText, are
+// unknown to this function. Use ImportFormatFromFilename() to pick a format
+// to import from.
+func FormatFromFilename(name string) (Format, bool) {
+	f, ok := extFormats[strings.ToLower(filepath.Ext(name))]
+
+	return f, ok
+}
+
+// ImportFormatFromFilename is like FormatFromFilename() but also knows the
+// extensions of the import-only formats, FormatText and FormatBin.
+func ImportFormatFromFilename(name string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	if f, ok := extFormats[ext]; ok {
+		return f, true
+	}
+
+	f, ok := importExtFormats[ext]
+
+	return f, ok
+}
+
+// FormatFromMIME picks a format to export to from a MIME type such as
+// "text/html" or "text/html; charset=utf-8". If the type is unknown,
+// FormatAuto and false are returned.
+//
+// Types of import-only formats, such as "text/plain" for FormatText, are
+// unknown to this function. Use ImportFormatFromMIME() to pick a format to
+// import from.
+func FormatFromMIME(mimeType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return FormatAuto, false
+	}
+
+	f, ok := mimeFormats[mediaType]
+
+	return f, ok
+}
+
+// ImportFormatFromMIME is like FormatFromMIME() but also knows the types of
+// the import-only formats, such as "text/plain".
+func ImportFormatFromMIME(mimeType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return FormatAuto, false
+	}
+
+	if f, ok := mimeFormats[mediaType]; ok {
+		return f, true
+	}
+
+	f, ok := importMIMEFormats[mediaType]
+
+	return f, ok
+}
+
+// checkImportFormat returns ErrFormatUnsupported if the linked libcaca cannot
+// import the format.
+func checkImportFormat(op string, f Format) error {
+	if !f.CanImport() {
+		return &Error{Op: op, Err: ErrFormatUnsupported}
+	}
+
+	return nil
+}
+
+// checkExportFormat returns ErrFormatUnsupported if the linked libcaca cannot
+// export the format.
+func checkExportFormat(op string, f Format) error {
+	if !f.CanExport() {
+		return &Error{Op: op, Err: ErrFormatUnsupported}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package caca
+
+import "testing"
+
+func TestFormatFromFilename(t *testing.T) {
+	tests := []struct {
+		name       string
+		want       Format
+		ok         bool
+		wantImport Format
+		okImport   bool
+	}{
+		{"art.caca", FormatCaca, true, FormatCaca, true},
+		{"dir.d/ART.ANS", FormatANSI, true, FormatANSI, true},
+		{"page.htm", FormatHTML, true, FormatHTML, true},
+		{"figure.eps", FormatPS, true, FormatPS, true},
+		{"man.tr", FormatTroff, true, FormatTroff, true},
+		{"notes.txt", FormatAuto, false, FormatText, true},
+		{"NOTES.ASC", FormatAuto, false, FormatText, true},
+		{"art.bin", FormatAuto, false, FormatBin, true},
+		{"image.png", FormatAuto, false, FormatAuto, false},
+		{"caca", FormatAuto, false, FormatAuto, false},
+		{"", FormatAuto, false, FormatAuto, false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := FormatFromFilename(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("FormatFromFilename(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+
+		if got, ok := ImportFormatFromFilename(tt.name); got != tt.wantImport || ok != tt.okImport {
+			t.Errorf("ImportFormatFromFilename(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.wantImport, tt.okImport)
+		}
+	}
+}
+
+func TestFormatFromMIME(t *testing.T) {
+	tests := []struct {
+		mimeType   string
+		want       Format
+		ok         bool
+		wantImport Format
+		okImport   bool
+	}{
+		{"text/html", FormatHTML, true, FormatHTML, true},
+		{"text/html; charset=utf-8", FormatHTML, true, FormatHTML, true},
+		{"TEXT/HTML", FormatHTML, true, FormatHTML, true},
+		{"application/x-caca", FormatCaca, true, FormatCaca, true},
+		{"image/x-targa", FormatTGA, true, FormatTGA, true},
+		{"text/plain", FormatAuto, false, FormatText, true},
+		{"text/plain; charset=utf-8", FormatAuto, false, FormatText, true},
+		{"image/png", FormatAuto, false, FormatAuto, false},
+		{"text/html; charset", FormatAuto, false, FormatAuto, false},
+		{"", FormatAuto, false, FormatAuto, false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := FormatFromMIME(tt.mimeType); got != tt.want || ok != tt.ok {
+			t.Errorf("FormatFromMIME(%q) = %q, %v, want %q, %v", tt.mimeType, got, ok, tt.want, tt.ok)
+		}
+
+		if got, ok := ImportFormatFromMIME(tt.mimeType); got != tt.wantImport || ok != tt.okImport {
+			t.Errorf("ImportFormatFromMIME(%q) = %q, %v, want %q, %v", tt.mimeType, got, ok, tt.wantImport, tt.okImport)
+		}
+	}
+}
+
+func TestFormatTablesMatchDirection(t *testing.T) {
+	if !FormatText.CanImport() || FormatText.CanExport() {
+		t.Skip("linked libcaca does not treat the text format as import-only")
+	}
+
+	for ext, f := range extFormats {
+		if !f.CanExport() {
+			t.Errorf("extension %q maps to %q, which cannot be exported", ext, f)
+		}
+	}
+
+	for ext, f := range importExtFormats {
+		if !f.CanImport() {
+			t.Errorf("extension %q maps to %q, which cannot be imported", ext, f)
+		}
+	}
+
+	for typ, f := range mimeFormats {
+		if !f.CanExport() {
+			t.Errorf("MIME type %q maps to %q, which cannot be exported", typ, f)
+		}
+	}
+}
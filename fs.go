@@ -0,0 +1,87 @@
+package caca
+
+import (
+	"image"
+	"io/fs"
+	"os"
+)
+
+// ImportFromFS imports the named file of fsys into the canvas' current frame,
+// like ImportFromFile() does. The file is read through fsys, so it may come
+// from an embed.FS, a zip archive or any other file system.
+//
+// If an error occurs -1 and the according error is returned.
+func (cv Canvas) ImportFromFS(fsys fs.FS, name string, format Format) (int, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return -1, err
+	}
+
+	return cv.ImportFromMemory(data, format)
+}
+
+// ImportAreaFromFS imports the named file of fsys into the canvas' current
+// frame at the given coordinates, like ImportAreaFromFile() does.
+//
+// If an error occurs -1 and the according error is returned.
+func (cv Canvas) ImportAreaFromFS(fsys fs.FS, x int, y int, name string, format Format) (int, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return -1, err
+	}
+
+	return cv.ImportAreaFromMemory(x, y, data, format)
+}
+
+// SetFigfontFS loads the named figfont of fsys and attaches it to the canvas,
+// like SetFigfont() does.
+//
+// libcaca can only load figfonts from the file system, so the font is copied
+// to a temporary file that is removed again once the font was loaded.
+//
+// If an error occurs the according error is returned.
+func (cv Canvas) SetFigfontFS(fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "caca-figfont-*.flf")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return cv.SetFigfont(f.Name())
+}
+
+// LoadImageFS decodes the named image of fsys with image.Decode(), so it
+// supports the formats registered with the image package. The result can be
+// drawn onto a canvas with a Dither.
+//
+// The function registers no decoders itself: import the decoder packages
+// needed, such as image/jpeg, in the calling program. PNG and GIF are always
+// registered, because the animation exporters of this package import
+// image/png and image/gif.
+func LoadImageFS(fsys fs.FS, name string) (image.Image, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+
+	return img, err
+}
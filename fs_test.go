@@ -0,0 +1,164 @@
+package caca
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testFigfont returns a figfont one line high whose glyphs are the characters
+// themselves.
+func testFigfont() []byte {
+	var b strings.Builder
+
+	b.WriteString("flf2a$ 1 1 1 -1 0\n")
+
+	for ch := ' '; ch <= '~'; ch++ {
+		if ch == ' ' {
+			b.WriteString("$@@\n")
+		} else {
+			b.WriteString(string(ch) + "@@\n")
+		}
+	}
+
+	// The seven German characters every figfont must provide.
+	b.WriteString(strings.Repeat("?@@\n", 7))
+
+	return []byte(b.String())
+}
+
+func TestImportFromFS(t *testing.T) {
+	fsys := fstest.MapFS{"art/canvas.caca": {Data: rawTestFrame(t, 2, 2, 'a')}}
+
+	cv, err := CreateCanvas(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	n, err := cv.ImportFromFS(fsys, "art/canvas.caca", FormatCaca)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(fsys["art/canvas.caca"].Data) {
+		t.Errorf("ImportFromFS() consumed %d bytes, want %d", n, len(fsys["art/canvas.caca"].Data))
+	}
+
+	if w, h := cv.GetWidth(), cv.GetHeight(); w != 2 || h != 2 {
+		t.Errorf("canvas is %dx%d after ImportFromFS(), want 2x2", w, h)
+	}
+
+	if n, err := cv.ImportFromFS(fsys, "art/missing.caca", FormatCaca); n != -1 || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ImportFromFS() of a missing file = %d, %v, want -1, %v", n, err, fs.ErrNotExist)
+	}
+}
+
+func TestImportAreaFromFS(t *testing.T) {
+	fsys := fstest.MapFS{"canvas.caca": {Data: rawTestFrame(t, 2, 2, 'a')}}
+
+	cv, err := CreateCanvas(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	if _, err := cv.ImportAreaFromFS(fsys, 1, 1, "canvas.caca", FormatCaca); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := canvasRows(cv); strings.Join(got, "|") != "| aa| aa" {
+		t.Errorf("canvas %q after ImportAreaFromFS(), want [\"\" \" aa\" \" aa\"]", got)
+	}
+
+	if n, err := cv.ImportAreaFromFS(fsys, 0, 0, "missing.caca", FormatCaca); n != -1 || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ImportAreaFromFS() of a missing file = %d, %v, want -1, %v", n, err, fs.ErrNotExist)
+	}
+}
+
+func TestSetFigfontFS(t *testing.T) {
+	// Point the temporary directory used by SetFigfontFS() to an empty one,
+	// to check that the copy of the font is removed again.
+	tmp := t.TempDir()
+
+	oldTmp, hadTmp := os.LookupEnv("TMPDIR")
+	os.Setenv("TMPDIR", tmp)
+
+	defer func() {
+		if hadTmp {
+			os.Setenv("TMPDIR", oldTmp)
+		} else {
+			os.Unsetenv("TMPDIR")
+		}
+	}()
+
+	fsys := fstest.MapFS{
+		"fonts/test.flf": {Data: testFigfont()},
+	}
+
+	cv, err := CreateCanvas(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	if err := cv.SetFigfontFS(fsys, "fonts/test.flf"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("SetFigfontFS() left %d files in the temporary directory", len(entries))
+	}
+
+	if err := cv.SetFigfontFS(fsys, "fonts/missing.flf"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("SetFigfontFS() of a missing file error %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestLoadImageFS(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(2, 1, color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{
+		"image.png": {Data: buf.Bytes()},
+		"text.txt":  {Data: []byte("not an image")},
+	}
+
+	img, err := LoadImageFS(fsys, "image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds() != src.Bounds() {
+		t.Errorf("image bounds %v, want %v", img.Bounds(), src.Bounds())
+	}
+
+	if got, want := color.NRGBAModel.Convert(img.At(2, 1)), src.At(2, 1); got != want {
+		t.Errorf("pixel (2, 1) = %v, want %v", got, want)
+	}
+
+	if _, err := LoadImageFS(fsys, "text.txt"); !errors.Is(err, image.ErrFormat) {
+		t.Errorf("LoadImageFS() of a text file error %v, want %v", err, image.ErrFormat)
+	}
+
+	if _, err := LoadImageFS(fsys, "missing.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadImageFS() of a missing file error %v, want %v", err, fs.ErrNotExist)
+	}
+}
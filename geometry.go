@@ -0,0 +1,128 @@
+package caca
+
+// Point is a position on a canvas, in character cells.
+type Point struct {
+	X, Y int
+}
+
+// Pt is shorthand for Point{X: x, Y: y}.
+func Pt(x int, y int) Point {
+	return Point{X: x, Y: y}
+}
+
+// Add returns the point p+q.
+func (p Point) Add(q Point) Point {
+	return Point{X: p.X + q.X, Y: p.Y + q.Y}
+}
+
+// Sub returns the point p-q.
+func (p Point) Sub(q Point) Point {
+	return Point{X: p.X - q.X, Y: p.Y - q.Y}
+}
+
+// In reports whether p is inside r.
+func (p Point) In(r Rect) bool {
+	return r.Contains(p)
+}
+
+// Rect is a rectangle on a canvas, given by its top-left corner and its size
+// in character cells. A rectangle with a zero or negative width or height is
+// empty.
+type Rect struct {
+	X, Y          int
+	Width, Height int
+}
+
+// NewRect is shorthand for Rect{X: x, Y: y, Width: w, Height: h}.
+func NewRect(x int, y int, w int, h int) Rect {
+	return Rect{X: x, Y: y, Width: w, Height: h}
+}
+
+// Min returns the top-left corner of r.
+func (r Rect) Min() Point {
+	return Point{X: r.X, Y: r.Y}
+}
+
+// Max returns the point just outside the bottom-right corner of r.
+func (r Rect) Max() Point {
+	return Point{X: r.X + r.Width, Y: r.Y + r.Height}
+}
+
+// Empty reports whether r contains no cells.
+func (r Rect) Empty() bool {
+	return r.Width <= 0 || r.Height <= 0
+}
+
+// Contains reports whether p is inside r.
+func (r Rect) Contains(p Point) bool {
+	return p.X >= r.X && p.X < r.X+r.Width && p.Y >= r.Y && p.Y < r.Y+r.Height
+}
+
+// Intersect returns the largest rectangle contained by both r and s. If the
+// two rectangles do not overlap, the zero Rect is returned.
+func (r Rect) Intersect(s Rect) Rect {
+	x0, y0 := maxInt(r.X, s.X), maxInt(r.Y, s.Y)
+	x1, y1 := minInt(r.X+r.Width, s.X+s.Width), minInt(r.Y+r.Height, s.Y+s.Height)
+
+	if x0 >= x1 || y0 >= y1 {
+		return Rect{}
+	}
+
+	return Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+// Union returns the smallest rectangle that contains both r and s. Empty
+// rectangles are ignored.
+func (r Rect) Union(s Rect) Rect {
+	if r.Empty() {
+		return s
+	}
+
+	if s.Empty() {
+		return r
+	}
+
+	x0, y0 := minInt(r.X, s.X), minInt(r.Y, s.Y)
+	x1, y1 := maxInt(r.X+r.Width, s.X+s.Width), maxInt(r.Y+r.Height, s.Y+s.Height)
+
+	return Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+// Inset returns r shrunk by n cells on each side. A negative n grows the
+// rectangle. If r is too small to be shrunk by n, an empty rectangle centred
+// on r is returned.
+func (r Rect) Inset(n int) Rect {
+	if r.Width < 2*n {
+		r.X += r.Width / 2
+		r.Width = 0
+	} else {
+		r.X += n
+		r.Width -= 2 * n
+	}
+
+	if r.Height < 2*n {
+		r.Y += r.Height / 2
+		r.Height = 0
+	} else {
+		r.Y += n
+		r.Height -= 2 * n
+	}
+
+	return r
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
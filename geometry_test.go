@@ -0,0 +1,139 @@
+package caca
+
+import "testing"
+
+func TestRectEmpty(t *testing.T) {
+	tests := []struct {
+		r    Rect
+		want bool
+	}{
+		{Rect{}, true},
+		{NewRect(1, 2, 3, 4), false},
+		{NewRect(1, 2, 0, 4), true},
+		{NewRect(1, 2, 3, 0), true},
+		{NewRect(1, 2, -3, 4), true},
+		{NewRect(1, 2, 3, -4), true},
+		{NewRect(-5, -5, 1, 1), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Empty(); got != tt.want {
+			t.Errorf("%+v.Empty() = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestRectContains(t *testing.T) {
+	r := NewRect(2, 3, 4, 2)
+
+	tests := []struct {
+		r    Rect
+		p    Point
+		want bool
+	}{
+		{r, Pt(2, 3), true},
+		{r, Pt(5, 4), true},
+		{r, Pt(6, 4), false},
+		{r, Pt(5, 5), false},
+		{r, Pt(1, 3), false},
+		{r, Pt(2, 2), false},
+		{Rect{}, Pt(0, 0), false},
+		{NewRect(2, 3, -4, 2), Pt(1, 3), false},
+		{NewRect(2, 3, 4, -2), Pt(2, 2), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Contains(tt.p); got != tt.want {
+			t.Errorf("%+v.Contains(%+v) = %v, want %v", tt.r, tt.p, got, tt.want)
+		}
+
+		if got := tt.p.In(tt.r); got != tt.want {
+			t.Errorf("%+v.In(%+v) = %v, want %v", tt.p, tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestRectIntersect(t *testing.T) {
+	tests := []struct {
+		name string
+		r, s Rect
+		want Rect
+	}{
+		{"overlapping", NewRect(0, 0, 4, 4), NewRect(2, 1, 4, 4), NewRect(2, 1, 2, 3)},
+		{"contained", NewRect(0, 0, 10, 10), NewRect(2, 3, 4, 5), NewRect(2, 3, 4, 5)},
+		{"same", NewRect(1, 1, 3, 3), NewRect(1, 1, 3, 3), NewRect(1, 1, 3, 3)},
+		{"negative coordinates", NewRect(-4, -4, 6, 6), NewRect(-1, 0, 5, 5), NewRect(-1, 0, 3, 2)},
+		{"disjoint", NewRect(0, 0, 2, 2), NewRect(5, 5, 2, 2), Rect{}},
+		{"touching horizontally", NewRect(0, 0, 2, 2), NewRect(2, 0, 2, 2), Rect{}},
+		{"touching vertically", NewRect(0, 0, 2, 2), NewRect(0, 2, 2, 2), Rect{}},
+		{"empty", NewRect(0, 0, 4, 4), NewRect(1, 1, 0, 0), Rect{}},
+		{"negative width", NewRect(0, 0, 4, 4), NewRect(3, 0, -2, 4), Rect{}},
+		{"negative height", NewRect(0, 0, 4, 4), NewRect(0, 3, 4, -2), Rect{}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Intersect(tt.s); got != tt.want {
+			t.Errorf("%s: %+v.Intersect(%+v) = %+v, want %+v", tt.name, tt.r, tt.s, got, tt.want)
+		}
+
+		if got := tt.s.Intersect(tt.r); got != tt.want {
+			t.Errorf("%s: %+v.Intersect(%+v) = %+v, want %+v", tt.name, tt.s, tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestRectUnion(t *testing.T) {
+	tests := []struct {
+		name string
+		r, s Rect
+		want Rect
+	}{
+		{"overlapping", NewRect(0, 0, 4, 4), NewRect(2, 1, 4, 4), NewRect(0, 0, 6, 5)},
+		{"contained", NewRect(0, 0, 10, 10), NewRect(2, 3, 4, 5), NewRect(0, 0, 10, 10)},
+		{"disjoint", NewRect(0, 0, 2, 2), NewRect(5, 6, 2, 2), NewRect(0, 0, 7, 8)},
+		{"touching", NewRect(0, 0, 2, 2), NewRect(2, 0, 2, 2), NewRect(0, 0, 4, 2)},
+		{"negative coordinates", NewRect(-4, -4, 2, 2), NewRect(1, 1, 2, 2), NewRect(-4, -4, 7, 7)},
+		{"empty", NewRect(1, 1, 2, 2), NewRect(9, 9, 0, 0), NewRect(1, 1, 2, 2)},
+		{"negative width", NewRect(1, 1, 2, 2), NewRect(-9, -9, -3, 5), NewRect(1, 1, 2, 2)},
+		{"negative height", NewRect(1, 1, 2, 2), NewRect(-9, -9, 5, -3), NewRect(1, 1, 2, 2)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Union(tt.s); got != tt.want {
+			t.Errorf("%s: %+v.Union(%+v) = %+v, want %+v", tt.name, tt.r, tt.s, got, tt.want)
+		}
+
+		if got := tt.s.Union(tt.r); got != tt.want {
+			t.Errorf("%s: %+v.Union(%+v) = %+v, want %+v", tt.name, tt.s, tt.r, got, tt.want)
+		}
+	}
+
+	// The union of two empty rectangles is empty.
+	if got := NewRect(1, 1, 0, 3).Union(NewRect(5, 5, -1, -1)); !got.Empty() {
+		t.Errorf("union of two empty rectangles %+v is not empty", got)
+	}
+}
+
+func TestRectInset(t *testing.T) {
+	tests := []struct {
+		r    Rect
+		n    int
+		want Rect
+	}{
+		{NewRect(0, 0, 10, 6), 1, NewRect(1, 1, 8, 4)},
+		{NewRect(0, 0, 10, 6), 3, NewRect(3, 3, 4, 0)},
+		{NewRect(0, 0, 10, 6), 0, NewRect(0, 0, 10, 6)},
+		{NewRect(2, 2, 4, 4), -2, NewRect(0, 0, 8, 8)},
+		{NewRect(0, 0, 10, 6), 4, NewRect(4, 3, 2, 0)},
+		{NewRect(0, 0, 10, 6), 6, NewRect(5, 3, 0, 0)},
+		{NewRect(0, 0, 3, 3), 2, NewRect(1, 1, 0, 0)},
+		{Rect{}, 1, Rect{}},
+		{NewRect(4, 4, -4, 2), 1, NewRect(2, 5, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Inset(tt.n); got != tt.want {
+			t.Errorf("%+v.Inset(%d) = %+v, want %+v", tt.r, tt.n, got, tt.want)
+		}
+	}
+}
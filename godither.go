@@ -0,0 +1,565 @@
+package caca
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"math/rand"
+)
+
+// DitherBackend selects which implementation renders a Dither, see
+// Dither.SetBackend().
+type DitherBackend int
+
+const (
+	// DitherBackendC renders bitmaps with libcaca. This is the default.
+	DitherBackendC DitherBackend = iota
+
+	// DitherBackendGo renders bitmaps with the pure-Go dithering engine.
+	DitherBackendGo
+)
+
+// SetBackend selects the implementation used by Bitmap(). Both backends honour
+// the colour mode, character set, antialiasing, brightness, gamma and contrast
+// settings of the dither, so that their output can be compared side by side.
+//
+// The Go backend supports all algorithms of libcaca as well as the additional
+// error diffusion algorithms DitherAtkinson, DitherJarvis, DitherStucki,
+// DitherSierra and DitherBurkes, and DitherBlueNoise. It also supports
+// serpentine scanning and a tunable error strength, see SetSerpentine() and
+// SetErrorStrength().
+func (di Dither) SetBackend(b DitherBackend) {
+	di.mustLive()
+	di.h.backend = b
+}
+
+// GetBackend returns the implementation selected with SetBackend().
+func (di Dither) GetBackend() DitherBackend {
+	di.mustLive()
+
+	return di.h.backend
+}
+
+// SetSerpentine enables or disables serpentine scanning in the Go backend.
+// With serpentine scanning, every other row is processed from right to left,
+// which avoids the diagonal artefacts of error diffusion. It is disabled by
+// default and has no effect on libcaca's algorithms.
+func (di Dither) SetSerpentine(serpentine bool) {
+	di.mustLive()
+	di.h.serpent = serpentine
+}
+
+// GetSerpentine reports whether serpentine scanning is enabled.
+func (di Dither) GetSerpentine() bool {
+	di.mustLive()
+
+	return di.h.serpent
+}
+
+// SetErrorStrength sets the fraction of the quantisation error that is
+// diffused to neighbouring cells by the error diffusion algorithms of the Go
+// backend. 1 diffuses the whole error and is the default, 0 disables error
+// diffusion. It has no effect on libcaca's algorithms.
+//
+// A negative strength is rejected with ErrInvalidArgument.
+func (di Dither) SetErrorStrength(strength float64) error {
+	di.mustLive()
+
+	if strength < 0 || math.IsNaN(strength) {
+		return &Error{Op: "Dither.SetErrorStrength", Err: ErrInvalidArgument}
+	}
+
+	di.h.errGain = strength
+
+	return nil
+}
+
+// GetErrorStrength returns the error strength of the Go backend.
+func (di Dither) GetErrorStrength() float64 {
+	di.mustLive()
+
+	return di.h.errGain
+}
+
+// pixelFormat is the pixel layout given to CreateDither() and SetPalette().
+type pixelFormat struct {
+	bpp, width, height, pitch int
+	masks                     [4]uint32
+	palette                   [256][4]uint32
+}
+
+// pixel returns the red, green, blue and alpha components of the pixel at the
+// given coordinates, each between 0 and 1. false is returned if the pixel is
+// outside the buffer.
+func (f *pixelFormat) pixel(pixels []byte, x int, y int) ([4]float64, bool) {
+	var rgba [4]float64
+
+	size := (f.bpp + 7) / 8
+	off := y*f.pitch + x*size
+
+	if off < 0 || off+size > len(pixels) {
+		return rgba, false
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if littleEndian() {
+		order = binary.LittleEndian
+	}
+
+	var v uint32
+
+	switch f.bpp {
+	case 8:
+		p := f.palette[pixels[off]]
+		for i := range rgba {
+			rgba[i] = float64(p[i]) / 0xfff
+		}
+
+		return rgba, true
+	case 16:
+		v = uint32(order.Uint16(pixels[off:]))
+	case 24:
+		p := pixels[off : off+3]
+		if order == binary.LittleEndian {
+			v = uint32(p[2])<<16 | uint32(p[1])<<8 | uint32(p[0])
+		} else {
+			v = uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		}
+	case 32:
+		v = order.Uint32(pixels[off:])
+	default:
+		return rgba, false
+	}
+
+	for i, mask := range f.masks {
+		rgba[i] = maskValue(v, mask)
+	}
+
+	if f.masks[3] == 0 {
+		rgba[3] = 1
+	}
+
+	return rgba, true
+}
+
+// maskValue extracts the masked component of a pixel as a value between 0
+// and 1.
+func maskValue(v uint32, mask uint32) float64 {
+	if mask == 0 {
+		return 0
+	}
+
+	shift := bits.TrailingZeros32(mask)
+
+	return float64((v&mask)>>shift) / float64(mask>>shift)
+}
+
+// rgb12Palette holds the 16 ANSI colours as 12-bit RGB values, as used by
+// libcaca when dithering.
+var rgb12Palette = [16]uint16{
+	0x000, 0x008, 0x080, 0x088, 0x800, 0x808, 0x880, 0xaaa,
+	0x555, 0x55f, 0x5f5, 0x5ff, 0xf55, 0xf5f, 0xff5, 0xfff,
+}
+
+// ditherColors returns the foreground and background colours available in a
+// colour mode.
+func ditherColors(mode DitherColor) ([]uint8, []uint8) {
+	grays := []uint8{ColorBlack, ColorDarkgray, ColorLightgray, ColorWhite}
+	all := make([]uint8, 16)
+
+	for i := range all {
+		all[i] = uint8(i)
+	}
+
+	black := []uint8{ColorBlack}
+
+	switch mode {
+	case ColorMono:
+		return []uint8{ColorLightgray}, black
+	case ColorGray:
+		return grays[1:], black
+	case Color8:
+		return all[:8], black
+	case Color16:
+		return all, black
+	case ColorFullGray:
+		return grays, grays
+	case ColorFull8:
+		return all[:8], all[:8]
+	default:
+		return all, all
+	}
+}
+
+// ditherGlyphs returns the characters of a character set, from the emptiest
+// to the fullest.
+func ditherGlyphs(charset DitherCharset) []rune {
+	switch charset {
+	case CharsetShades:
+		return []rune{' ', '░', '▒', '▓', '█'}
+	case CharsetBlocks:
+		return []rune{' ', '▘', '▚', '▙', '█'}
+	default:
+		return []rune(" .:;t%SX@8")
+	}
+}
+
+// diffusionTap is a single entry of an error diffusion kernel.
+type diffusionTap struct {
+	dx, dy int
+	weight float64
+}
+
+// diffusionKernels holds the error diffusion kernels of the Go backend. The
+// weights of each kernel are normalised by the kernel's divisor.
+var diffusionKernels = map[DitherAlgorithm][]diffusionTap{
+	DitherFstein: normaliseKernel(16, []diffusionTap{
+		{1, 0, 7}, {-1, 1, 3}, {0, 1, 5}, {1, 1, 1},
+	}),
+	DitherAtkinson: normaliseKernel(8, []diffusionTap{
+		{1, 0, 1}, {2, 0, 1}, {-1, 1, 1}, {0, 1, 1}, {1, 1, 1}, {0, 2, 1},
+	}),
+	DitherJarvis: normaliseKernel(48, []diffusionTap{
+		{1, 0, 7}, {2, 0, 5},
+		{-2, 1, 3}, {-1, 1, 5}, {0, 1, 7}, {1, 1, 5}, {2, 1, 3},
+		{-2, 2, 1}, {-1, 2, 3}, {0, 2, 5}, {1, 2, 3}, {2, 2, 1},
+	}),
+	DitherStucki: normaliseKernel(42, []diffusionTap{
+		{1, 0, 8}, {2, 0, 4},
+		{-2, 1, 2}, {-1, 1, 4}, {0, 1, 8}, {1, 1, 4}, {2, 1, 2},
+		{-2, 2, 1}, {-1, 2, 2}, {0, 2, 4}, {1, 2, 2}, {2, 2, 1},
+	}),
+	DitherSierra: normaliseKernel(32, []diffusionTap{
+		{1, 0, 5}, {2, 0, 3},
+		{-2, 1, 2}, {-1, 1, 4}, {0, 1, 5}, {1, 1, 4}, {2, 1, 2},
+		{-1, 2, 2}, {0, 2, 3}, {1, 2, 2},
+	}),
+	DitherBurkes: normaliseKernel(32, []diffusionTap{
+		{1, 0, 8}, {2, 0, 4},
+		{-2, 1, 2}, {-1, 1, 4}, {0, 1, 8}, {1, 1, 4}, {2, 1, 2},
+	}),
+}
+
+func normaliseKernel(div float64, taps []diffusionTap) []diffusionTap {
+	for i := range taps {
+		taps[i].weight /= div
+	}
+
+	return taps
+}
+
+// goDither holds the settings of a single Bitmap() call of the Go backend.
+type goDither struct {
+	format    *pixelFormat
+	fgs, bgs  []uint8
+	glyphs    []rune
+	antialias bool
+	gamma     float64
+	contrast  float64
+	bright    float64
+
+	kernel    []diffusionTap
+	threshold func(x int, y int) float64
+	serpent   bool
+	errGain   float64
+}
+
+func newGoDither(di Dither) *goDither {
+	g := &goDither{
+		format:    &di.h.format,
+		glyphs:    ditherGlyphs(di.GetCharset()),
+		antialias: di.GetAntialias() != AntialiasNone,
+		gamma:     di.GetGamma(),
+		contrast:  di.GetContrast(),
+		bright:    di.GetBrightness(),
+		serpent:   di.h.serpent,
+		errGain:   di.h.errGain,
+	}
+
+	if c, err := ParseDitherColor(string(di.GetColor())); err == nil {
+		g.fgs, g.bgs = ditherColors(c)
+	} else {
+		g.fgs, g.bgs = ditherColors(ColorFull16)
+	}
+
+	algo := di.GetAlgorithm()
+	g.kernel = diffusionKernels[algo]
+
+	switch algo {
+	case DitherOrdered2:
+		g.threshold = bayerThreshold(2)
+	case DitherOrdered4:
+		g.threshold = bayerThreshold(4)
+	case DitherOrdered8:
+		g.threshold = bayerThreshold(8)
+	case DitherRandom:
+		rnd := rand.New(rand.NewSource(1)) //nolint:gosec // reproducible noise, not security sensitive
+		g.threshold = func(int, int) float64 { return rnd.Float64() }
+	case DitherBlueNoise:
+		g.threshold = blueNoiseThreshold
+	default:
+		g.threshold = func(int, int) float64 { return 0.5 }
+	}
+
+	return g
+}
+
+// bitmapGo renders the bitmap onto the area r of the canvas with the Go
+// backend.
+func (di Dither) bitmapGo(cv Canvas, r Rect, pixels []byte) {
+	g := newGoDither(di)
+	clip := r.Intersect(cv.Bounds())
+
+	if clip.Empty() || g.format.width <= 0 || g.format.height <= 0 || len(pixels) == 0 {
+		return
+	}
+
+	chars, attrs := canvasArea(cv, clip)
+	errs := make([][3]float64, clip.Width*clip.Height)
+
+	for j := 0; j < clip.Height; j++ {
+		reverse := g.serpent && j%2 == 1
+
+		for k := 0; k < clip.Width; k++ {
+			i := k
+			if reverse {
+				i = clip.Width - 1 - k
+			}
+
+			rgba := g.sample(pixels, r, clip.X-r.X+i, clip.Y-r.Y+j)
+			if rgba[3] < 0.5 {
+				continue
+			}
+
+			idx := j*clip.Width + i
+
+			var c [3]float64
+			for n := range c {
+				c[n] = rgba[n] + errs[idx][n]
+			}
+
+			fg, bg, level, out := g.quantize(c, g.threshold(clip.X+i, clip.Y+j))
+			chars[idx] = g.glyphs[level]
+			attrs[idx] = NewAttrAnsi(fg, bg, 0)
+
+			g.diffuse(errs, clip, i, j, reverse, c, out)
+		}
+	}
+
+	_ = cv.SetCells(clip.X, clip.Y, clip.Width, clip.Height, chars, attrs)
+}
+
+// canvasArea returns the current characters and attributes of an area of the
+// canvas, so that transparent cells can be left unchanged.
+func canvasArea(cv Canvas, r Rect) ([]rune, []Attr) {
+	width := cv.GetWidth()
+	allChars, allAttrs := cv.GetChars(), cv.GetAttrs()
+	chars := make([]rune, 0, r.Width*r.Height)
+	attrs := make([]Attr, 0, r.Width*r.Height)
+
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		chars = append(chars, allChars[y*width+r.X:y*width+r.X+r.Width]...)
+		attrs = append(attrs, allAttrs[y*width+r.X:y*width+r.X+r.Width]...)
+	}
+
+	return chars, attrs
+}
+
+// sample returns the colour of the part of the bitmap covered by the cell
+// (cx, cy) of the area r, with gamma, contrast and brightness applied. With
+// antialiasing the covered pixels are averaged, otherwise the centre pixel is
+// used.
+func (g *goDither) sample(pixels []byte, r Rect, cx int, cy int) [4]float64 {
+	f := g.format
+	x0, x1 := cx*f.width/r.Width, (cx+1)*f.width/r.Width
+	y0, y1 := cy*f.height/r.Height, (cy+1)*f.height/r.Height
+
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+
+	if !g.antialias {
+		x0, y0 = (x0+x1)/2, (y0+y1)/2
+		x1, y1 = x0+1, y0+1
+	}
+
+	var sum [4]float64
+
+	n := 0
+
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			p, ok := f.pixel(pixels, x, y)
+			if !ok {
+				continue
+			}
+
+			for i := range sum {
+				sum[i] += p[i]
+			}
+
+			n++
+		}
+	}
+
+	if n == 0 {
+		return sum
+	}
+
+	for i := range sum {
+		sum[i] /= float64(n)
+	}
+
+	for i := 0; i < 3; i++ {
+		sum[i] = g.adjust(sum[i])
+	}
+
+	return sum
+}
+
+// adjust applies gamma, contrast and brightness to a colour component. A
+// negative gamma inverts the component, like libcaca does.
+func (g *goDither) adjust(v float64) float64 {
+	gamma := g.gamma
+	if gamma < 0 {
+		v, gamma = 1-v, -gamma
+	}
+
+	if gamma != 0 && gamma != 1 {
+		v = math.Pow(v, 1/gamma)
+	}
+
+	v = ((v-0.5)*g.contrast + 0.5) * g.bright
+
+	return math.Max(0, math.Min(1, v))
+}
+
+// quantize picks the foreground and background colours and the glyph level
+// that best represent the colour c. Error diffusion algorithms pick the
+// closest level, the others compare the exact mix with the threshold. The
+// colour actually rendered is returned as well.
+func (g *goDither) quantize(c [3]float64, threshold float64) (uint8, uint8, int, [3]float64) {
+	levels := float64(len(g.glyphs) - 1)
+	best := math.Inf(1)
+
+	var (
+		bestFg, bestBg uint8
+		bestT          float64
+	)
+
+	for _, fg := range g.fgs {
+		fgc := rgb12Float(rgb12Palette[fg])
+
+		for _, bg := range g.bgs {
+			bgc := rgb12Float(rgb12Palette[bg])
+			t := mixRatio(c, fgc, bgc)
+
+			if g.kernel != nil {
+				t = math.Round(t*levels) / levels
+			}
+
+			if d := colorDist(c, mix(fgc, bgc, t)); d < best {
+				best, bestFg, bestBg, bestT = d, fg, bg, t
+			}
+		}
+	}
+
+	level := int(math.Round(bestT * levels))
+	if g.kernel == nil {
+		level = int(math.Min(levels, math.Floor(bestT*levels+threshold)))
+	}
+
+	out := mix(rgb12Float(rgb12Palette[bestFg]), rgb12Float(rgb12Palette[bestBg]), float64(level)/levels)
+
+	return bestFg, bestBg, level, out
+}
+
+// diffuse spreads the difference between the wanted colour c and the rendered
+// colour out over the neighbouring cells that have not been processed yet.
+func (g *goDither) diffuse(errs [][3]float64, clip Rect, i int, j int, reverse bool, c [3]float64, out [3]float64) {
+	for _, tap := range g.kernel {
+		dx := tap.dx
+		if reverse {
+			dx = -dx
+		}
+
+		x, y := i+dx, j+tap.dy
+		if x < 0 || x >= clip.Width || y >= clip.Height {
+			continue
+		}
+
+		for n := range c {
+			errs[y*clip.Width+x][n] += (c[n] - out[n]) * tap.weight * g.errGain
+		}
+	}
+}
+
+func rgb12Float(c uint16) [3]float64 {
+	return [3]float64{float64(c>>8&0xf) / 0xf, float64(c>>4&0xf) / 0xf, float64(c&0xf) / 0xf}
+}
+
+// mixRatio returns the proportion of fg in the mix of fg and bg closest to c.
+func mixRatio(c [3]float64, fg [3]float64, bg [3]float64) float64 {
+	var num, den float64
+
+	for i := range c {
+		d := fg[i] - bg[i]
+		num += (c[i] - bg[i]) * d
+		den += d * d
+	}
+
+	if den == 0 {
+		return 0
+	}
+
+	return math.Max(0, math.Min(1, num/den))
+}
+
+func mix(fg [3]float64, bg [3]float64, t float64) [3]float64 {
+	var m [3]float64
+	for i := range m {
+		m[i] = bg[i] + t*(fg[i]-bg[i])
+	}
+
+	return m
+}
+
+func colorDist(a [3]float64, b [3]float64) float64 {
+	var d float64
+
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+
+	return d
+}
+
+// bayerThreshold returns the threshold function of an n×n Bayer matrix, where
+// n is a power of two.
+func bayerThreshold(n int) func(x int, y int) float64 {
+	m := []int{0}
+
+	for size := 1; size < n; size *= 2 {
+		next := make([]int, 4*size*size)
+
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				v := 4 * m[y*size+x]
+				next[y*2*size+x] = v
+				next[y*2*size+x+size] = v + 2
+				next[(y+size)*2*size+x] = v + 3
+				next[(y+size)*2*size+x+size] = v + 1
+			}
+		}
+
+		m = next
+	}
+
+	return func(x int, y int) float64 {
+		return (float64(m[(y%n)*n+x%n]) + 0.5) / float64(n*n)
+	}
+}
@@ -0,0 +1,320 @@
+package caca
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const (
+	ditherTestWidth  = 64
+	ditherTestHeight = 32
+	ditherCanvasW    = 40
+	ditherCanvasH    = 12
+)
+
+// gradientPixels returns a 32-bit RGB bitmap whose grey level increases from
+// left to right and whose red level increases from top to bottom.
+func gradientPixels() []byte {
+	return makePixels(func(x int, y int) (uint8, uint8, uint8) {
+		g := uint8(x * 255 / (ditherTestWidth - 1))
+		r := uint8(y * 255 / (ditherTestHeight - 1))
+
+		return r, g, g
+	})
+}
+
+// noisePixels returns a 32-bit RGB bitmap of reproducible random colours.
+func noisePixels() []byte {
+	rnd := rand.New(rand.NewSource(42))
+
+	return makePixels(func(int, int) (uint8, uint8, uint8) {
+		return uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256))
+	})
+}
+
+func makePixels(color func(x int, y int) (uint8, uint8, uint8)) []byte {
+	var order binary.ByteOrder = binary.BigEndian
+	if littleEndian() {
+		order = binary.LittleEndian
+	}
+
+	pixels := make([]byte, ditherTestWidth*ditherTestHeight*4)
+
+	for y := 0; y < ditherTestHeight; y++ {
+		for x := 0; x < ditherTestWidth; x++ {
+			r, g, b := color(x, y)
+			order.PutUint32(pixels[(y*ditherTestWidth+x)*4:], uint32(r)<<16|uint32(g)<<8|uint32(b))
+		}
+	}
+
+	return pixels
+}
+
+// ditherWith renders the pixels with the given backend, algorithm and
+// settings and returns the resulting cells.
+func ditherWith(t *testing.T, backend DitherBackend, algo DitherAlgorithm, pixels []byte, setup func(Dither)) ([]rune, []Attr) {
+	t.Helper()
+
+	di, err := CreateDither(32, ditherTestWidth, ditherTestHeight, ditherTestWidth*4, 0xff0000, 0xff00, 0xff, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer di.Free()
+
+	if err := di.SetCharset(CharsetShades); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := di.SetAlgorithm(algo); err != nil {
+		t.Fatal(err)
+	}
+
+	di.SetBackend(backend)
+
+	if setup != nil {
+		setup(di)
+	}
+
+	cv, err := CreateCanvas(ditherCanvasW, ditherCanvasH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	di.Bitmap(cv, 0, 0, ditherCanvasW, ditherCanvasH, pixels)
+
+	return cv.GetChars(), cv.GetAttrs()
+}
+
+// cellColor returns the colour a shades cell appears to have: the mix of its
+// foreground and background colours weighted by the glyph's coverage.
+func cellColor(ch rune, a Attr) [3]float64 {
+	glyphs := ditherGlyphs(CharsetShades)
+	level := -1
+
+	for i, g := range glyphs {
+		if g == ch {
+			level = i
+		}
+	}
+
+	fg, bg := a.FgAnsi(), a.BgAnsi()
+	if level < 0 || fg >= 16 || bg >= 16 {
+		return [3]float64{}
+	}
+
+	return mix(rgb12Float(rgb12Palette[fg]), rgb12Float(rgb12Palette[bg]), float64(level)/float64(len(glyphs)-1))
+}
+
+// regionLuma returns the mean luminance of the cells of each w×h region of
+// the canvas.
+func regionLuma(chars []rune, attrs []Attr, w int, h int) []float64 {
+	var lumas []float64
+
+	for ry := 0; ry+h <= ditherCanvasH; ry += h {
+		for rx := 0; rx+w <= ditherCanvasW; rx += w {
+			var sum float64
+
+			for y := ry; y < ry+h; y++ {
+				for x := rx; x < rx+w; x++ {
+					c := cellColor(chars[y*ditherCanvasW+x], attrs[y*ditherCanvasW+x])
+					sum += 0.299*c[0] + 0.587*c[1] + 0.114*c[2]
+				}
+			}
+
+			lumas = append(lumas, sum/float64(w*h))
+		}
+	}
+
+	return lumas
+}
+
+func TestGoDitherMatchesLibcaca(t *testing.T) {
+	// libcaca picks its colours differently, so the cells do not match one
+	// by one, but both engines must reproduce the same tones once averaged
+	// over small regions.
+	const tolerance = 0.12
+
+	for _, algo := range []DitherAlgorithm{DitherFstein, DitherOrdered2, DitherOrdered4, DitherOrdered8} {
+		pixels := gradientPixels()
+		cChars, cAttrs := ditherWith(t, DitherBackendC, algo, pixels, nil)
+		goChars, goAttrs := ditherWith(t, DitherBackendGo, algo, pixels, nil)
+
+		want := regionLuma(cChars, cAttrs, 8, 4)
+		got := regionLuma(goChars, goAttrs, 8, 4)
+
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > tolerance {
+				t.Errorf("%s: region %d has luminance %.3f, libcaca renders %.3f", algo, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestGoDitherDeterministic(t *testing.T) {
+	algos := []DitherAlgorithm{
+		DitherFstein, DitherAtkinson, DitherJarvis, DitherStucki, DitherSierra, DitherBurkes,
+		DitherOrdered4, DitherRandom, DitherBlueNoise,
+	}
+
+	pixels := noisePixels()
+
+	for _, algo := range algos {
+		chars1, attrs1 := ditherWith(t, DitherBackendGo, algo, pixels, nil)
+		chars2, attrs2 := ditherWith(t, DitherBackendGo, algo, pixels, nil)
+
+		for i := range chars1 {
+			if chars1[i] != chars2[i] || attrs1[i] != attrs2[i] {
+				t.Errorf("%s: cell %d differs between two identical renders", algo, i)
+
+				break
+			}
+		}
+	}
+}
+
+func TestGoDitherKernelsDiffer(t *testing.T) {
+	pixels := gradientPixels()
+	none, _ := ditherWith(t, DitherBackendGo, DitherNone, pixels, nil)
+
+	for _, algo := range []DitherAlgorithm{DitherAtkinson, DitherJarvis, DitherStucki, DitherSierra, DitherBurkes} {
+		chars, _ := ditherWith(t, DitherBackendGo, algo, pixels, nil)
+
+		if equalRunes(chars, none) {
+			t.Errorf("%s renders the same cells as DitherNone", algo)
+		}
+	}
+}
+
+func TestDiffusionKernelWeights(t *testing.T) {
+	want := map[DitherAlgorithm]float64{
+		DitherFstein:   1,
+		DitherAtkinson: 0.75, // Atkinson deliberately diffuses only 6/8 of the error.
+		DitherJarvis:   1,
+		DitherStucki:   1,
+		DitherSierra:   1,
+		DitherBurkes:   1,
+	}
+
+	for algo, kernel := range diffusionKernels {
+		var sum float64
+
+		for _, tap := range kernel {
+			if tap.dy < 0 || (tap.dy == 0 && tap.dx <= 0) {
+				t.Errorf("%s: tap (%d, %d) points at an already processed cell", algo, tap.dx, tap.dy)
+			}
+
+			sum += tap.weight
+		}
+
+		if math.Abs(sum-want[algo]) > 1e-9 {
+			t.Errorf("%s: weights sum to %v, want %v", algo, sum, want[algo])
+		}
+	}
+}
+
+func TestGoDitherSerpentine(t *testing.T) {
+	pixels := gradientPixels()
+	plain, _ := ditherWith(t, DitherBackendGo, DitherFstein, pixels, nil)
+	serpent, _ := ditherWith(t, DitherBackendGo, DitherFstein, pixels, func(di Dither) {
+		di.SetSerpentine(true)
+	})
+
+	// The first row is scanned from left to right either way.
+	if !equalRunes(plain[:ditherCanvasW], serpent[:ditherCanvasW]) {
+		t.Error("serpentine scanning changed the first row")
+	}
+
+	if equalRunes(plain, serpent) {
+		t.Error("serpentine scanning did not change the output")
+	}
+}
+
+func TestGoDitherErrorStrength(t *testing.T) {
+	pixels := gradientPixels()
+
+	// The same gradient with its left half blacked out. The cells of the
+	// right half sample the same pixels in both images.
+	halved := append([]byte(nil), pixels...)
+	for y := 0; y < ditherTestHeight; y++ {
+		for x := 0; x < ditherTestWidth/2; x++ {
+			copy(halved[(y*ditherTestWidth+x)*4:], []byte{0, 0, 0, 0})
+		}
+	}
+
+	noDiffusion := func(di Dither) {
+		if err := di.SetErrorStrength(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	full, _ := ditherWith(t, DitherBackendGo, DitherFstein, pixels, nil)
+	zero, zeroAttrs := ditherWith(t, DitherBackendGo, DitherFstein, pixels, noDiffusion)
+	zeroHalved, zeroHalvedAttrs := ditherWith(t, DitherBackendGo, DitherFstein, halved, noDiffusion)
+
+	// Without diffusion every cell only depends on its own pixels.
+	for y := 0; y < ditherCanvasH; y++ {
+		for x := ditherCanvasW / 2; x < ditherCanvasW; x++ {
+			i := y*ditherCanvasW + x
+			if zero[i] != zeroHalved[i] || zeroAttrs[i] != zeroHalvedAttrs[i] {
+				t.Fatalf("cell (%d, %d) changed with strength 0 although its pixels did not", x, y)
+			}
+		}
+	}
+
+	if equalRunes(full, zero) {
+		t.Error("error strength 0 renders the same cells as strength 1")
+	}
+
+	di, err := CreateDither(32, 1, 1, 4, 0xff0000, 0xff00, 0xff, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer di.Free()
+
+	if err := di.SetErrorStrength(-1); err == nil {
+		t.Error("negative error strength accepted")
+	}
+
+	if got := di.GetErrorStrength(); got != 1 {
+		t.Errorf("GetErrorStrength() = %v after a rejected value, want 1", got)
+	}
+}
+
+func TestBayerThreshold(t *testing.T) {
+	for _, n := range []int{2, 4, 8} {
+		threshold := bayerThreshold(n)
+		seen := make(map[float64]bool)
+
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				v := threshold(x, y)
+				if v <= 0 || v >= 1 || seen[v] {
+					t.Errorf("%dx%d: threshold(%d, %d) = %v is out of range or repeated", n, n, x, y, v)
+				}
+
+				seen[v] = true
+
+				if threshold(x+n, y+n) != v {
+					t.Errorf("%dx%d: matrix does not tile at (%d, %d)", n, n, x, y)
+				}
+			}
+		}
+	}
+}
+
+func equalRunes(a []rune, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
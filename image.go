@@ -0,0 +1,204 @@
+package caca
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"unsafe"
+)
+
+// DitherOptions configures how DrawImage() renders an image. Zero values
+// keep libcaca's defaults.
+type DitherOptions struct {
+	// Brightness, Gamma and Contrast are passed to the corresponding
+	// Dither setters. Zero keeps the default of 1.
+	Brightness float64
+	Gamma      float64
+	Contrast   float64
+
+	Antialias DitherAntialias
+	Color     DitherColor
+	Charset   DitherCharset
+	Algorithm DitherAlgorithm
+}
+
+// Apply sets all non-zero options on the dither.
+//
+// If an error occurs the according errno is returned.
+func (o *DitherOptions) Apply(di Dither) error {
+	if o == nil {
+		return nil
+	}
+
+	if o.Brightness != 0 {
+		if err := di.SetBrightness(o.Brightness); err != nil {
+			return err
+		}
+	}
+
+	if o.Gamma != 0 {
+		if err := di.SetGamma(o.Gamma); err != nil {
+			return err
+		}
+	}
+
+	if o.Contrast != 0 {
+		if err := di.SetContrast(o.Contrast); err != nil {
+			return err
+		}
+	}
+
+	if o.Antialias != "" {
+		if err := di.SetAntialias(o.Antialias); err != nil {
+			return err
+		}
+	}
+
+	if o.Color != "" {
+		if err := di.SetColor(o.Color); err != nil {
+			return err
+		}
+	}
+
+	if o.Charset != "" {
+		if err := di.SetCharset(o.Charset); err != nil {
+			return err
+		}
+	}
+
+	if o.Algorithm != "" {
+		if err := di.SetAlgorithm(o.Algorithm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DrawImage dithers img onto the area r of the canvas. The image is stretched
+// to the area. opts may be nil to use libcaca's defaults.
+//
+// *image.NRGBA, *image.Gray and *image.Paletted images and opaque *image.RGBA
+// images are handed to libcaca as they are, with the matching pixel masks or
+// palette. libcaca expects colours that are not premultiplied by alpha, so
+// *image.RGBA images with transparent pixels are converted to *image.NRGBA
+// first, as are all other images. libcaca has no YCbCr pixel format, so this
+// includes *image.YCbCr, the result of decoding a JPEG.
+//
+// If an error occurs the according errno is returned.
+func DrawImage(cv Canvas, r Rect, img image.Image, opts *DitherOptions) error {
+	b := img.Bounds()
+	if b.Empty() || r.Empty() {
+		return nil
+	}
+
+	di, pixels, err := newImageDither(img)
+	if err != nil {
+		return err
+	}
+
+	defer di.Free()
+
+	if err := opts.Apply(di); err != nil {
+		return err
+	}
+
+	di.Bitmap(cv, r.X, r.Y, r.Width, r.Height, pixels)
+
+	return nil
+}
+
+// newImageDither creates a dither matching the pixel layout of img and returns
+// it together with the pixel buffer to pass to Dither.Bitmap().
+func newImageDither(img image.Image) (Dither, []byte, error) {
+	b := img.Bounds()
+
+	switch m := img.(type) {
+	case *image.RGBA:
+		// Premultiplied and straight colours only agree on opaque pixels.
+		if !m.Opaque() {
+			return newNRGBADither(img)
+		}
+
+		return newRGBADither(b, m.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):])
+	case *image.NRGBA:
+		return newRGBADither(b, m.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):])
+	case *image.Gray:
+		var red, green, blue, alpha [256]uint32
+		for i := range red {
+			v := uint32(i) * 0xfff / 0xff
+			red[i], green[i], blue[i], alpha[i] = v, v, v, 0xfff
+		}
+
+		return newPalettedDither(b, m.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], red, green, blue, alpha)
+	case *image.Paletted:
+		var red, green, blue, alpha [256]uint32
+		for i, c := range m.Palette {
+			if i >= len(red) {
+				break
+			}
+
+			nc, _ := color.NRGBAModel.Convert(c).(color.NRGBA)
+			red[i] = uint32(nc.R) * 0xfff / 0xff
+			green[i] = uint32(nc.G) * 0xfff / 0xff
+			blue[i] = uint32(nc.B) * 0xfff / 0xff
+			alpha[i] = uint32(nc.A) * 0xfff / 0xff
+		}
+
+		return newPalettedDither(b, m.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], red, green, blue, alpha)
+	default:
+		return newNRGBADither(img)
+	}
+}
+
+// newNRGBADither converts img to an *image.NRGBA, which un-premultiplies its
+// colours, and creates a dither for the result.
+func newNRGBADither(img image.Image) (Dither, []byte, error) {
+	b := img.Bounds()
+	m := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(m, m.Bounds(), img, b.Min, draw.Src)
+
+	return newRGBADither(m.Bounds(), m.Stride, m.Pix)
+}
+
+// newRGBADither creates a 32 bits per pixel dither for pixels stored as R, G,
+// B and A bytes, as used by image.RGBA and image.NRGBA.
+func newRGBADither(b image.Rectangle, stride int, pix []byte) (Dither, []byte, error) {
+	rmask, gmask, bmask, amask := uint32(0xff000000), uint32(0x00ff0000), uint32(0x0000ff00), uint32(0x000000ff)
+	if littleEndian() {
+		rmask, gmask, bmask, amask = 0x000000ff, 0x0000ff00, 0x00ff0000, 0xff000000
+	}
+
+	di, err := CreateDither(32, b.Dx(), b.Dy(), stride, rmask, gmask, bmask, amask)
+	if err != nil {
+		return Dither{}, nil, err
+	}
+
+	return di, pix, nil
+}
+
+// newPalettedDither creates an 8 bits per pixel dither with the given 12-bit
+// palette.
+func newPalettedDither(b image.Rectangle, stride int, pix []byte, red [256]uint32, green [256]uint32, blue [256]uint32, alpha [256]uint32) (Dither, []byte, error) {
+	di, err := CreateDither(8, b.Dx(), b.Dy(), stride, 0, 0, 0, 0)
+	if err != nil {
+		return Dither{}, nil, err
+	}
+
+	if err := di.SetPalette(red, green, blue, alpha); err != nil {
+		di.Free()
+
+		return Dither{}, nil, err
+	}
+
+	return di, pix, nil
+}
+
+// littleEndian reports whether the native byte order is little endian.
+// libcaca reads 32-bit pixels as native integers, so the pixel masks depend on
+// it.
+func littleEndian() bool {
+	x := uint16(1)
+
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
@@ -0,0 +1,68 @@
+package caca
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNewImageDitherPixels(t *testing.T) {
+	translucent := color.RGBA{R: 0x40, G: 0x20, B: 0x00, A: 0x80}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	rgba.SetRGBA(0, 0, translucent)
+	rgba.SetRGBA(1, 0, color.RGBA{R: 0xff, A: 0xff})
+
+	di, pixels, err := newImageDither(rgba)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di.Free()
+
+	// The translucent image is un-premultiplied.
+	want := color.NRGBAModel.Convert(translucent).(color.NRGBA)
+	if got := (color.NRGBA{R: pixels[0], G: pixels[1], B: pixels[2], A: pixels[3]}); got != want {
+		t.Errorf("translucent RGBA pixel handed to libcaca as %v, want %v", got, want)
+	}
+
+	// An opaque image is handed over as it is.
+	opaque := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	opaque.SetRGBA(0, 0, color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff})
+
+	di, pixels, err = newImageDither(opaque)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di.Free()
+
+	if &pixels[0] != &opaque.Pix[0] {
+		t.Error("opaque RGBA image was copied")
+	}
+
+	// A YCbCr image is converted.
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = 0xff
+	}
+
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = 0x80, 0x80
+	}
+
+	di, pixels, err = newImageDither(ycbcr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	di.Free()
+
+	if len(pixels) != 4*2*4 {
+		t.Fatalf("YCbCr image handed to libcaca as %d bytes, want %d", len(pixels), 4*2*4)
+	}
+
+	if got := (color.NRGBA{R: pixels[0], G: pixels[1], B: pixels[2], A: pixels[3]}); got != (color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Errorf("white YCbCr pixel handed to libcaca as %v", got)
+	}
+}
@@ -0,0 +1,153 @@
+package caca
+
+// #cgo LDFLAGS: -lcaca
+// #include <caca.h>
+// #include <stdlib.h>
+// #include <string.h>
+//
+// static void fill_event(caca_event_t *ev, int type, int a, int b, int c,
+//                        uint32_t utf32, char const *utf8)
+// {
+//     memset(ev, 0, sizeof(*ev));
+//     ev->type = type;
+//
+//     switch (type) {
+//     case CACA_EVENT_KEY_PRESS:
+//     case CACA_EVENT_KEY_RELEASE:
+//         ev->data.key.ch = a;
+//         ev->data.key.utf32 = utf32;
+//         strncpy(ev->data.key.utf8, utf8, sizeof(ev->data.key.utf8) - 1);
+//         break;
+//     case CACA_EVENT_MOUSE_PRESS:
+//     case CACA_EVENT_MOUSE_RELEASE:
+//     case CACA_EVENT_MOUSE_MOTION:
+//         ev->data.mouse.x = a;
+//         ev->data.mouse.y = b;
+//         ev->data.mouse.button = c;
+//         break;
+//     case CACA_EVENT_RESIZE:
+//         ev->data.resize.w = a;
+//         ev->data.resize.h = b;
+//         break;
+//     }
+// }
+import "C"
+
+import (
+	"unsafe"
+)
+
+// refreshHook wraps a hook function so that it can be identified for removal.
+type refreshHook struct {
+	fn func(cv Canvas)
+}
+
+// fill stores the event data in the raw event so that its getters return it.
+func (e *Event) fill(data EventData) {
+	a, b, c := 0, 0, 0
+
+	switch data.Type {
+	case EventKeyPress, EventKeyRelease:
+		a = data.KeyCh
+	case EventMousePress, EventMouseRelease:
+		c = data.MouseButton
+	case EventMouseMotion:
+		a, b = data.MouseX, data.MouseY
+	case EventResize:
+		a, b = data.ResizeWidth, data.ResizeHeight
+	}
+
+	cUTF8 := cString(data.KeyUTF8)
+	defer cFree(unsafe.Pointer(cUTF8))
+
+	C.fill_event(e.Ev, C.int(data.Type), C.int(a), C.int(b), C.int(c), C.uint32_t(data.KeyUTF32), cUTF8)
+}
+
+// PushEvent appends a synthetic event to the display's event queue. Pushed
+// events are returned by GetEvent(), Events() and WaitEvent() in the order
+// they were pushed and before any event from the driver. This is mostly
+// useful for tests, see the catest package.
+//
+// Pushing an event does not change the display: a resize event does not
+// resize the canvas and a mouse motion event does not change GetMouseX() and
+// GetMouseY().
+//
+// The type of the event must be exactly one of the event types, otherwise
+// ErrInvalidArgument is returned. PushEvent may be called from any goroutine.
+func (d Display) PushEvent(data EventData) error {
+	d.mustLive()
+
+	switch data.Type {
+	case EventKeyPress, EventKeyRelease, EventMousePress, EventMouseRelease,
+		EventMouseMotion, EventResize, EventQuit:
+	default:
+		return &Error{Op: "Display.PushEvent", Err: ErrInvalidArgument}
+	}
+
+	if data.KeyUTF8 == "" && data.KeyUTF32 != 0 {
+		data.KeyUTF8 = string(data.KeyUTF32)
+	}
+
+	d.h.mu.Lock()
+	d.h.pending = append(d.h.pending, data)
+	d.h.mu.Unlock()
+
+	return nil
+}
+
+// popEvent removes and returns the first pushed event matching eventMask.
+func (d Display) popEvent(eventMask int) (EventData, bool) {
+	d.h.mu.Lock()
+	defer d.h.mu.Unlock()
+
+	for i, data := range d.h.pending {
+		if data.Type&eventMask == 0 {
+			continue
+		}
+
+		d.h.pending = append(d.h.pending[:i], d.h.pending[i+1:]...)
+
+		return data, true
+	}
+
+	return EventData{}, false
+}
+
+// AddRefreshHook registers fn to be called with the display's canvas after
+// every Refresh(). Hooks run in the order they were added, on the goroutine
+// calling Refresh(). The returned function removes the hook again.
+func (d Display) AddRefreshHook(fn func(cv Canvas)) (remove func()) {
+	d.mustLive()
+
+	hook := &refreshHook{fn: fn}
+
+	d.h.mu.Lock()
+	d.h.hooks = append(d.h.hooks, hook)
+	d.h.mu.Unlock()
+
+	h := d.h
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		for i, other := range h.hooks {
+			if other == hook {
+				h.hooks = append(h.hooks[:i:i], h.hooks[i+1:]...)
+
+				return
+			}
+		}
+	}
+}
+
+// runRefreshHooks calls the registered refresh hooks.
+func (d Display) runRefreshHooks() {
+	d.h.mu.Lock()
+	hooks := d.h.hooks
+	d.h.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook.fn(d.h.cv)
+	}
+}
@@ -0,0 +1,259 @@
+package caca
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+// leakIterations is how often every binding is called by the leak tests.
+const leakIterations = 100
+
+// checkNoLeak calls fn leakIterations times and fails if C memory allocated
+// by the bindings is still held afterwards.
+func checkNoLeak(t *testing.T, name string, fn func() error) {
+	t.Helper()
+
+	before := atomic.LoadInt64(&cAllocs)
+
+	for i := 0; i < leakIterations; i++ {
+		if err := fn(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	if after := atomic.LoadInt64(&cAllocs); after != before {
+		t.Errorf("%s: %d C allocations leaked after %d calls", name, after-before, leakIterations)
+	}
+}
+
+func TestCAllocCounting(t *testing.T) {
+	before := atomic.LoadInt64(&cAllocs)
+
+	ptrs := []unsafe.Pointer{
+		unsafe.Pointer(cString("hello")),
+		cBytes([]byte{1, 2, 3}),
+		cCalloc(4, 8),
+	}
+
+	for i, p := range ptrs {
+		if p == nil {
+			t.Fatalf("allocation %d returned nil", i)
+		}
+	}
+
+	if got := atomic.LoadInt64(&cAllocs) - before; got != int64(len(ptrs)) {
+		t.Errorf("%d allocations counted, want %d", got, len(ptrs))
+	}
+
+	for _, p := range ptrs {
+		cFree(p)
+	}
+
+	if after := atomic.LoadInt64(&cAllocs); after != before {
+		t.Errorf("%d allocations left after freeing them all", after-before)
+	}
+}
+
+func TestCanvasBindingsDoNotLeak(t *testing.T) {
+	cv, err := CreateCanvas(20, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	file := filepath.Join(t.TempDir(), "canvas.caca")
+
+	checkNoLeak(t, "PutStr", func() error {
+		cv.PutStr(0, 0, "hello world")
+
+		return nil
+	})
+
+	checkNoLeak(t, "SetFrameName", func() error {
+		return cv.SetFrameName("frame")
+	})
+
+	checkNoLeak(t, "ExportToMemory", func() error {
+		_, err := cv.ExportToMemory(FormatCaca)
+
+		return err
+	})
+
+	checkNoLeak(t, "ExportAreaToMemory", func() error {
+		_, err := cv.ExportAreaToMemory(0, 0, 10, 2, FormatUTF8)
+
+		return err
+	})
+
+	data, err := cv.ExportToMemory(FormatCaca)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkNoLeak(t, "ImportFromMemory", func() error {
+		_, err := cv.ImportFromMemory(data, FormatCaca)
+
+		return err
+	})
+
+	checkNoLeak(t, "ImportAreaFromMemory", func() error {
+		_, err := cv.ImportAreaFromMemory(1, 1, data, FormatCaca)
+
+		return err
+	})
+
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	checkNoLeak(t, "ImportFromFile", func() error {
+		_, err := cv.ImportFromFile(file, FormatCaca)
+
+		return err
+	})
+
+	checkNoLeak(t, "ImportAreaFromFile", func() error {
+		_, err := cv.ImportAreaFromFile(1, 1, file, FormatCaca)
+
+		return err
+	})
+
+	checkNoLeak(t, "SetFigfont", func() error {
+		_ = cv.SetFigfont(filepath.Join(t.TempDir(), "missing.flf"))
+
+		return nil
+	})
+
+	checkNoLeak(t, "UTF8ToUTF32", func() error {
+		UTF8ToUTF32("é")
+
+		return nil
+	})
+}
+
+func TestFailingBindingsDoNotLeak(t *testing.T) {
+	cv, err := CreateCanvas(20, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	checkNoLeak(t, "ImportFromMemory", func() error {
+		_, _ = cv.ImportFromMemory([]byte("\xca\xca\x00"), FormatCaca)
+
+		return nil
+	})
+
+	checkNoLeak(t, "ImportFromFile", func() error {
+		_, _ = cv.ImportFromFile(filepath.Join(t.TempDir(), "missing"), FormatCaca)
+
+		return nil
+	})
+
+	checkNoLeak(t, "LoadFont", func() error {
+		_, _ = LoadFont("no such font")
+
+		return nil
+	})
+
+	checkNoLeak(t, "LoadFontData", func() error {
+		_, _ = LoadFontData([]byte("not a font"))
+
+		return nil
+	})
+}
+
+func TestDitherBindingsDoNotLeak(t *testing.T) {
+	di, err := CreateDither(32, 4, 4, 16, 0xff0000, 0xff00, 0xff, 0xff000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer di.Free()
+
+	checkNoLeak(t, "SetAntialias", func() error {
+		return di.SetAntialias(AntialiasPrefilter)
+	})
+
+	checkNoLeak(t, "SetColor", func() error {
+		return di.SetColor(ColorFull16)
+	})
+
+	checkNoLeak(t, "SetCharset", func() error {
+		return di.SetCharset(CharsetASCII)
+	})
+
+	checkNoLeak(t, "SetAlgorithm", func() error {
+		return di.SetAlgorithm(DitherFstein)
+	})
+}
+
+func TestFontBindingsDoNotLeak(t *testing.T) {
+	fonts := Fonts()
+	if len(fonts) == 0 {
+		t.Skip("no built-in fonts")
+	}
+
+	checkNoLeak(t, "LoadFont", func() error {
+		f, err := LoadFont(fonts[0])
+		if err != nil {
+			return err
+		}
+
+		f.Free()
+
+		return nil
+	})
+}
+
+func TestDisplayBindingsDoNotLeak(t *testing.T) {
+	if !HasDriver("null") {
+		t.Skip("null driver not available")
+	}
+
+	checkNoLeak(t, "CreateDisplayWithDriver", func() error {
+		dp, err := CreateDisplayWithDriver(nil, "null")
+		if err != nil {
+			return err
+		}
+
+		dp.Free()
+
+		return nil
+	})
+
+	dp, err := CreateDisplayWithDriver(nil, "null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dp.Free()
+
+	checkNoLeak(t, "SetTitle", func() error {
+		return dp.SetTitle("title")
+	})
+
+	checkNoLeak(t, "SetDriver", func() error {
+		return dp.SetDriver("null")
+	})
+
+	checkNoLeak(t, "PushEvent+GetEvent", func() error {
+		if err := dp.PushEvent(EventData{Type: EventKeyPress, KeyCh: 'a', KeyUTF32: 'a'}); err != nil {
+			return err
+		}
+
+		ev := NewEvent()
+		defer ev.Free()
+
+		dp.GetEvent(EventAny, &ev, 0)
+
+		return nil
+	})
+
+	checkNoLeak(t, "GetEvent", func() error {
+		dp.GetEvent(EventAny, nil, 0)
+
+		return nil
+	})
+}
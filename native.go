@@ -0,0 +1,120 @@
+package caca
+
+import (
+	"github.com/czwinzscher/libcaca-go/cacafmt"
+)
+
+// ToNative copies all frames of the canvas into the pure-Go representation of
+// the native "caca" format, including the frame names and durations, and the
+// cursor and handle positions and current attribute that every frame keeps
+// for itself. The canvas' current frame is restored afterwards.
+func (cv Canvas) ToNative() *cacafmt.Canvas {
+	current := currentFrame(cv)
+	defer func() { _ = cv.SetFrame(current) }()
+
+	nc := &cacafmt.Canvas{Width: cv.GetWidth(), Height: cv.GetHeight()}
+
+	for i := 0; i < cv.GetFrameCount(); i++ {
+		_ = cv.SetFrame(i)
+
+		nc.Frames = append(nc.Frames, cacafmt.Frame{
+			Name:     cv.GetFrameName(),
+			Duration: cv.GetFrameDuration(),
+			Attr:     uint32(cv.GetAttr(-1, -1)),
+			CursorX:  cv.WhereX(),
+			CursorY:  cv.WhereY(),
+			HandleX:  cv.GetHandleX(),
+			HandleY:  cv.GetHandleY(),
+			Chars:    cv.GetChars(),
+			Attrs:    nativeAttrs(cv.GetAttrs()),
+		})
+	}
+
+	return nc
+}
+
+// CanvasFromNative creates a canvas holding all frames of the pure-Go "caca"
+// representation. Frame durations are stored with SetFrameDuration(), the
+// cursor and handle positions and the current attribute are restored for every
+// frame. The first frame is active on return.
+//
+// If the representation is invalid, ErrInvalidArgument is returned.
+//
+// If an error occurs the according errno is returned.
+func CanvasFromNative(nc *cacafmt.Canvas) (Canvas, error) {
+	if err := nc.Validate(); err != nil {
+		return Canvas{}, &Error{Op: "CanvasFromNative", Err: ErrInvalidArgument}
+	}
+
+	cv, err := CreateCanvas(nc.Width, nc.Height)
+	if err != nil {
+		return Canvas{}, err
+	}
+
+	if err := cv.setNative(nc); err != nil {
+		_ = cv.Free()
+
+		return Canvas{}, err
+	}
+
+	return cv, nil
+}
+
+// setNative fills the freshly created canvas with the frames of nc.
+func (cv Canvas) setNative(nc *cacafmt.Canvas) error {
+	for i := 1; i < len(nc.Frames); i++ {
+		if err := cv.CreateFrame(i); err != nil {
+			return err
+		}
+	}
+
+	for i, f := range nc.Frames {
+		if err := cv.SetFrame(i); err != nil {
+			return err
+		}
+
+		if err := cv.SetCells(0, 0, nc.Width, nc.Height, f.Chars, goAttrs(f.Attrs)); err != nil {
+			return err
+		}
+
+		if f.Name != "" {
+			if err := cv.SetFrameName(f.Name); err != nil {
+				return err
+			}
+		}
+
+		if err := cv.SetFrameDuration(f.Duration); err != nil {
+			return err
+		}
+
+		cv.GoToXY(f.CursorX, f.CursorY)
+		cv.SetHandle(f.HandleX, f.HandleY)
+		cv.SetAttr(Attr(f.Attr))
+	}
+
+	return cv.SetFrame(0)
+}
+
+// nativeAttrs converts attributes to the plain values stored by cacafmt.
+func nativeAttrs(attrs []Attr) []uint32 {
+	out := make([]uint32, len(attrs))
+	for i, a := range attrs {
+		out[i] = uint32(a)
+	}
+
+	return out
+}
+
+// goAttrs converts attributes stored by cacafmt to Attr values.
+func goAttrs(attrs []uint32) []Attr {
+	if attrs == nil {
+		return nil
+	}
+
+	out := make([]Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = Attr(a)
+	}
+
+	return out
+}
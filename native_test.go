@@ -0,0 +1,110 @@
+package caca
+
+import (
+	"testing"
+
+	"github.com/czwinzscher/libcaca-go/cacafmt"
+)
+
+func TestNativeRoundTripKeepsFrameState(t *testing.T) {
+	type frameState struct {
+		name             string
+		ch               rune
+		attr             Attr
+		cursorX, cursorY int
+		handleX, handleY int
+	}
+
+	want := []frameState{
+		{"first", 'a', NewAttrAnsi(ColorRed, ColorBlue, StyleBold), 1, 0, 0, 1},
+		{"second", 'b', NewAttrARGB(0xff80, 0xf124, 0), 2, 1, 2, 0},
+		{"third", 'c', NewAttrAnsi(ColorDefault, ColorTransparent, StyleItalics), 0, 1, 1, 1},
+	}
+
+	cv, err := CreateCanvas(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	for i, f := range want {
+		if i > 0 {
+			if err := cv.CreateFrame(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := cv.SetFrame(i); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cv.SetFrameName(f.name); err != nil {
+			t.Fatal(err)
+		}
+
+		cv.SetAttr(f.attr)
+		cv.PutChar(0, 0, f.ch)
+		cv.GoToXY(f.cursorX, f.cursorY)
+		cv.SetHandle(f.handleX, f.handleY)
+	}
+
+	if err := cv.SetFrame(1); err != nil {
+		t.Fatal(err)
+	}
+
+	nc := cv.ToNative()
+
+	if got := currentFrame(cv); got != 1 {
+		t.Errorf("current frame %d after ToNative(), want 1", got)
+	}
+
+	if len(nc.Frames) != len(want) {
+		t.Fatalf("ToNative() has %d frames, want %d", len(nc.Frames), len(want))
+	}
+
+	for i, f := range want {
+		got := nc.Frames[i]
+		if got.Name != f.name || got.Attr != uint32(f.attr) || got.CursorX != f.cursorX || got.CursorY != f.cursorY || got.HandleX != f.handleX || got.HandleY != f.handleY {
+			t.Errorf("native frame %d = %q attr %#x cursor (%d, %d) handle (%d, %d), want %q attr %#x cursor (%d, %d) handle (%d, %d)",
+				i, got.Name, got.Attr, got.CursorX, got.CursorY, got.HandleX, got.HandleY,
+				f.name, uint32(f.attr), f.cursorX, f.cursorY, f.handleX, f.handleY)
+		}
+	}
+
+	// Go through the encoded format as well.
+	data, err := nc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, _, err := cacafmt.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cv2, err := CanvasFromNative(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv2.Free()
+
+	if got := currentFrame(cv2); got != 0 {
+		t.Errorf("current frame %d after CanvasFromNative(), want 0", got)
+	}
+
+	for i, f := range want {
+		if err := cv2.SetFrame(i); err != nil {
+			t.Fatal(err)
+		}
+
+		got := frameState{
+			name: cv2.GetFrameName(), ch: cv2.GetChar(0, 0), attr: cv2.GetAttr(-1, -1),
+			cursorX: cv2.WhereX(), cursorY: cv2.WhereY(),
+			handleX: cv2.GetHandleX(), handleY: cv2.GetHandleY(),
+		}
+
+		if got != f {
+			t.Errorf("frame %d after the round trip = %+v, want %+v", i, got, f)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package caca
+
+import (
+	"image/color"
+	"math"
+	"sort"
+)
+
+// PixelMode selects how many pixels a PixelCanvas packs into a single
+// character cell, and which Unicode characters it uses to draw them.
+type PixelMode int
+
+const (
+	// PixelHalfBlocks uses 1x2 pixels per cell, drawn with the upper and
+	// lower half block characters.
+	PixelHalfBlocks PixelMode = iota
+
+	// PixelQuadrants uses 2x2 pixels per cell, drawn with the quadrant block
+	// characters.
+	PixelQuadrants
+
+	// PixelSextants uses 2x3 pixels per cell, drawn with the sextant
+	// characters of the Symbols for Legacy Computing block. Not every font
+	// provides them.
+	PixelSextants
+
+	// PixelBraille uses 2x4 pixels per cell, drawn with the Braille
+	// patterns.
+	PixelBraille
+)
+
+// cellSize returns the number of pixels per cell in each direction.
+func (m PixelMode) cellSize() (int, int) {
+	switch m {
+	case PixelQuadrants:
+		return 2, 2
+	case PixelSextants:
+		return 2, 3
+	case PixelBraille:
+		return 2, 4
+	default:
+		return 1, 2
+	}
+}
+
+// brailleDots maps the pixels of a cell, row by row, to the dot bits of the
+// Braille patterns.
+var brailleDots = [8]rune{0x01, 0x08, 0x02, 0x10, 0x04, 0x20, 0x40, 0x80}
+
+var quadrantGlyphs = [16]rune{
+	' ', '▘', '▝', '▀', '▖', '▌', '▞', '▛', '▗', '▚', '▐', '▜', '▄', '▙', '▟', '█',
+}
+
+// glyph returns the character showing the given pixels of a cell. Bit n of
+// bits is set if the n-th pixel of the cell, counted row by row, is lit.
+func (m PixelMode) glyph(bits uint) rune {
+	switch m {
+	case PixelQuadrants:
+		return quadrantGlyphs[bits]
+	case PixelSextants:
+		switch bits {
+		case 0:
+			return ' '
+		case 21:
+			return '▌'
+		case 42:
+			return '▐'
+		case 63:
+			return '█'
+		}
+
+		ch := rune(0x1fb00 + bits - 1)
+		if bits > 21 {
+			ch--
+		}
+
+		if bits > 42 {
+			ch--
+		}
+
+		return ch
+	case PixelBraille:
+		ch := rune(0x2800)
+
+		for i, dot := range brailleDots {
+			if bits&(1<<uint(i)) != 0 {
+				ch |= dot
+			}
+		}
+
+		return ch
+	default:
+		return [4]rune{' ', '▀', '▄', '█'}[bits]
+	}
+}
+
+// PixelCanvas is a pixel grid drawn onto an area of a Canvas with sub-cell
+// resolution. Each pixel is either unset or has a colour. Drawing happens in
+// memory, Flush() composes the glyphs and colours into the canvas.
+//
+// A cell can only show two colours: the colour of its lit pixels as the
+// foreground, and either the canvas' default background or, if all pixels of
+// the cell are set, the colour of the darker pixels as the background.
+type PixelCanvas struct {
+	cv     Canvas
+	area   Rect
+	mode   PixelMode
+	cw, ch int
+	width  int
+	height int
+	pix    []color.NRGBA
+	pen    color.NRGBA
+}
+
+// NewPixelCanvas creates a pixel canvas covering the area r of cv. Its size
+// in pixels is the size of r multiplied by the cell size of the mode. The
+// pen colour is initially opaque white.
+func NewPixelCanvas(cv Canvas, r Rect, mode PixelMode) *PixelCanvas {
+	cw, ch := mode.cellSize()
+
+	if r.Empty() {
+		r.Width, r.Height = 0, 0
+	}
+
+	pc := &PixelCanvas{
+		cv: cv, area: r, mode: mode, cw: cw, ch: ch,
+		width: r.Width * cw, height: r.Height * ch,
+		pen: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+	pc.pix = make([]color.NRGBA, pc.width*pc.height)
+
+	return pc
+}
+
+// Width returns the width of the pixel canvas in pixels.
+func (pc *PixelCanvas) Width() int {
+	return pc.width
+}
+
+// Height returns the height of the pixel canvas in pixels.
+func (pc *PixelCanvas) Height() int {
+	return pc.height
+}
+
+// SetColor sets the pen colour used by the drawing functions. A fully
+// transparent colour such as color.Transparent erases pixels instead.
+func (pc *PixelCanvas) SetColor(c color.Color) {
+	pc.pen, _ = color.NRGBAModel.Convert(c).(color.NRGBA)
+}
+
+// Clear unsets all pixels.
+func (pc *PixelCanvas) Clear() {
+	for i := range pc.pix {
+		pc.pix[i] = color.NRGBA{}
+	}
+}
+
+// Set sets the pixel at the given coordinates to the pen colour. Pixels
+// outside the canvas are ignored.
+func (pc *PixelCanvas) Set(x int, y int) {
+	pc.SetRGBA(x, y, pc.pen)
+}
+
+// SetRGBA sets the pixel at the given coordinates to c. Pixels outside the
+// canvas are ignored.
+func (pc *PixelCanvas) SetRGBA(x int, y int, c color.Color) {
+	if x < 0 || y < 0 || x >= pc.width || y >= pc.height {
+		return
+	}
+
+	pc.pix[y*pc.width+x], _ = color.NRGBAModel.Convert(c).(color.NRGBA)
+}
+
+// Unset clears the pixel at the given coordinates.
+func (pc *PixelCanvas) Unset(x int, y int) {
+	pc.SetRGBA(x, y, color.NRGBA{})
+}
+
+// IsSet reports whether the pixel at the given coordinates is set.
+func (pc *PixelCanvas) IsSet(x int, y int) bool {
+	return pc.At(x, y).A != 0
+}
+
+// At returns the colour of the pixel at the given coordinates. Unset pixels
+// and pixels outside the canvas are fully transparent.
+func (pc *PixelCanvas) At(x int, y int) color.NRGBA {
+	if x < 0 || y < 0 || x >= pc.width || y >= pc.height {
+		return color.NRGBA{}
+	}
+
+	return pc.pix[y*pc.width+x]
+}
+
+// Line draws a line from (x0, y0) to (x1, y1) with the pen colour.
+func (pc *PixelCanvas) Line(x0 int, y0 int, x1 int, y1 int) {
+	dx, dy := absInt(x1-x0), -absInt(y1-y0)
+	sx, sy := 1, 1
+
+	if x0 > x1 {
+		sx = -1
+	}
+
+	if y0 > y1 {
+		sy = -1
+	}
+
+	e := dx + dy
+
+	for {
+		pc.Set(x0, y0)
+
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * e
+
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// Rect draws the outline of r with the pen colour.
+func (pc *PixelCanvas) Rect(r Rect) {
+	if r.Empty() {
+		return
+	}
+
+	x1, y1 := r.X+r.Width-1, r.Y+r.Height-1
+	pc.Line(r.X, r.Y, x1, r.Y)
+	pc.Line(r.X, y1, x1, y1)
+	pc.Line(r.X, r.Y, r.X, y1)
+	pc.Line(x1, r.Y, x1, y1)
+}
+
+// FillRect fills r with the pen colour.
+func (pc *PixelCanvas) FillRect(r Rect) {
+	r = r.Intersect(Rect{Width: pc.width, Height: pc.height})
+
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for x := r.X; x < r.X+r.Width; x++ {
+			pc.Set(x, y)
+		}
+	}
+}
+
+// Circle draws a circle around (cx, cy) with the pen colour.
+func (pc *PixelCanvas) Circle(cx int, cy int, r int) {
+	x, y, e := r, 0, 1-r
+
+	for x >= y {
+		for _, p := range [8][2]int{{x, y}, {y, x}, {-y, x}, {-x, y}, {-x, -y}, {-y, -x}, {y, -x}, {x, -y}} {
+			pc.Set(cx+p[0], cy+p[1])
+		}
+
+		y++
+
+		if e < 0 {
+			e += 2*y + 1
+		} else {
+			x--
+			e += 2*(y-x) + 1
+		}
+	}
+}
+
+// FillCircle fills a circle around (cx, cy) with the pen colour.
+func (pc *PixelCanvas) FillCircle(cx int, cy int, r int) {
+	for dy := -r; dy <= r; dy++ {
+		dx := int(math.Sqrt(float64(r*r - dy*dy)))
+		for x := cx - dx; x <= cx+dx; x++ {
+			pc.Set(x, cy+dy)
+		}
+	}
+}
+
+// Polygon draws the outline of the polygon through the given points with the
+// pen colour. The last point is connected to the first one.
+func (pc *PixelCanvas) Polygon(pts []Point) {
+	if len(pts) == 0 {
+		return
+	}
+
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		pc.Line(p.X, p.Y, q.X, q.Y)
+	}
+}
+
+// FillPolygon fills the polygon through the given points with the pen colour,
+// using the even-odd rule. A pixel is filled if its centre is inside the
+// polygon.
+func (pc *PixelCanvas) FillPolygon(pts []Point) {
+	if len(pts) < 3 {
+		pc.Polygon(pts)
+
+		return
+	}
+
+	for y := 0; y < pc.height; y++ {
+		fy := float64(y) + 0.5
+
+		var xs []float64
+
+		for i, p := range pts {
+			q := pts[(i+1)%len(pts)]
+			if (float64(p.Y) <= fy) == (float64(q.Y) <= fy) {
+				continue
+			}
+
+			t := (fy - float64(p.Y)) / float64(q.Y-p.Y)
+			xs = append(xs, float64(p.X)+t*float64(q.X-p.X))
+		}
+
+		sort.Float64s(xs)
+
+		for i := 0; i+1 < len(xs); i += 2 {
+			for x := int(math.Ceil(xs[i] - 0.5)); float64(x)+0.5 <= xs[i+1]; x++ {
+				pc.Set(x, y)
+			}
+		}
+	}
+}
+
+// Flush composes the pixels into glyphs and attributes and writes them into
+// the area of the underlying canvas. Cells without any set pixel are cleared
+// to a space with the default colours.
+func (pc *PixelCanvas) Flush() {
+	clip := pc.area.Intersect(pc.cv.Bounds())
+	if clip.Empty() {
+		return
+	}
+
+	chars := make([]rune, 0, clip.Width*clip.Height)
+	attrs := make([]Attr, 0, clip.Width*clip.Height)
+	cell := make([]color.NRGBA, pc.cw*pc.ch)
+
+	for cy := clip.Y - pc.area.Y; cy < clip.Y-pc.area.Y+clip.Height; cy++ {
+		for cx := clip.X - pc.area.X; cx < clip.X-pc.area.X+clip.Width; cx++ {
+			for j := 0; j < pc.ch; j++ {
+				for i := 0; i < pc.cw; i++ {
+					cell[j*pc.cw+i] = pc.At(cx*pc.cw+i, cy*pc.ch+j)
+				}
+			}
+
+			ch, attr := pc.composeCell(cell)
+			chars = append(chars, ch)
+			attrs = append(attrs, attr)
+		}
+	}
+
+	_ = pc.cv.SetCells(clip.X, clip.Y, clip.Width, clip.Height, chars, attrs)
+}
+
+// composeCell returns the glyph and attribute showing the pixels of a cell.
+func (pc *PixelCanvas) composeCell(cell []color.NRGBA) (rune, Attr) {
+	var bits uint
+
+	full := true
+
+	for i, c := range cell {
+		if c.A != 0 {
+			bits |= 1 << uint(i)
+		} else {
+			full = false
+		}
+	}
+
+	if bits == 0 {
+		return ' ', NewAttrAnsi(ColorDefault, ColorDefault, 0)
+	}
+
+	if !full {
+		return pc.mode.glyph(bits), NewAttrARGB(averageARGB(cell, bits), 0, 0).WithBgAnsi(ColorDefault)
+	}
+
+	// All pixels are set: light the brighter half and use the darker half
+	// as the background.
+	var mean float64
+	for _, c := range cell {
+		mean += luminance(c)
+	}
+
+	mean /= float64(len(cell))
+	bits = 0
+
+	for i, c := range cell {
+		if luminance(c) >= mean {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	all := uint(1)<<uint(len(cell)) - 1
+	if bits == all {
+		return pc.mode.glyph(all), NewAttrARGB(averageARGB(cell, all), averageARGB(cell, all), 0)
+	}
+
+	return pc.mode.glyph(bits), NewAttrARGB(averageARGB(cell, bits), averageARGB(cell, all&^bits), 0)
+}
+
+// averageARGB returns the average colour of the selected pixels as a 16-bit
+// ARGB value.
+func averageARGB(cell []color.NRGBA, bits uint) uint16 {
+	var r, g, b, n int
+
+	for i, c := range cell {
+		if bits&(1<<uint(i)) == 0 {
+			continue
+		}
+
+		r, g, b, n = r+int(c.R), g+int(c.G), b+int(c.B), n+1
+	}
+
+	if n == 0 {
+		return 0xf000
+	}
+
+	return 0xf000 | uint16(r/n>>4)<<8 | uint16(g/n>>4)<<4 | uint16(b/n>>4)
+}
+
+func luminance(c color.NRGBA) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+
+	return a
+}
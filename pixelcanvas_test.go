@@ -0,0 +1,137 @@
+package caca
+
+import "testing"
+
+// pixelBits returns the cell mask with the given pixels lit.
+func pixelBits(mode PixelMode, pts ...Point) uint {
+	cw, _ := mode.cellSize()
+
+	var bits uint
+	for _, p := range pts {
+		bits |= 1 << uint(p.Y*cw+p.X)
+	}
+
+	return bits
+}
+
+func TestPixelGlyphs(t *testing.T) {
+	tests := []struct {
+		name string
+		mode PixelMode
+		pts  []Point
+		want rune
+	}{
+		{"half blocks empty", PixelHalfBlocks, nil, ' '},
+		{"half blocks upper", PixelHalfBlocks, []Point{{0, 0}}, '▀'},
+		{"half blocks lower", PixelHalfBlocks, []Point{{0, 1}}, '▄'},
+		{"half blocks full", PixelHalfBlocks, []Point{{0, 0}, {0, 1}}, '█'},
+
+		{"quadrants empty", PixelQuadrants, nil, ' '},
+		{"quadrants upper left", PixelQuadrants, []Point{{0, 0}}, '▘'},
+		{"quadrants upper right", PixelQuadrants, []Point{{1, 0}}, '▝'},
+		{"quadrants upper half", PixelQuadrants, []Point{{0, 0}, {1, 0}}, '▀'},
+		{"quadrants lower left", PixelQuadrants, []Point{{0, 1}}, '▖'},
+		{"quadrants left half", PixelQuadrants, []Point{{0, 0}, {0, 1}}, '▌'},
+		{"quadrants upper right and lower left", PixelQuadrants, []Point{{1, 0}, {0, 1}}, '▞'},
+		{"quadrants all but lower right", PixelQuadrants, []Point{{0, 0}, {1, 0}, {0, 1}}, '▛'},
+		{"quadrants lower right", PixelQuadrants, []Point{{1, 1}}, '▗'},
+		{"quadrants upper left and lower right", PixelQuadrants, []Point{{0, 0}, {1, 1}}, '▚'},
+		{"quadrants right half", PixelQuadrants, []Point{{1, 0}, {1, 1}}, '▐'},
+		{"quadrants all but lower left", PixelQuadrants, []Point{{0, 0}, {1, 0}, {1, 1}}, '▜'},
+		{"quadrants lower half", PixelQuadrants, []Point{{0, 1}, {1, 1}}, '▄'},
+		{"quadrants all but upper right", PixelQuadrants, []Point{{0, 0}, {0, 1}, {1, 1}}, '▙'},
+		{"quadrants all but upper left", PixelQuadrants, []Point{{1, 0}, {0, 1}, {1, 1}}, '▟'},
+		{"quadrants full", PixelQuadrants, []Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}}, '█'},
+
+		{"sextants all off", PixelSextants, nil, ' '},
+		{"sextants all on", PixelSextants, []Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}, {1, 2}}, '█'},
+		{"sextants left half", PixelSextants, []Point{{0, 0}, {0, 1}, {0, 2}}, '▌'},
+		{"sextants right half", PixelSextants, []Point{{1, 0}, {1, 1}, {1, 2}}, '▐'},
+		{"sextant 1", PixelSextants, []Point{{0, 0}}, '\U0001fb00'},
+		{"sextant 35", PixelSextants, []Point{{0, 1}, {0, 2}}, '\U0001fb13'},
+		{"sextant 235", PixelSextants, []Point{{1, 0}, {0, 1}, {0, 2}}, '\U0001fb14'},
+		{"sextant 12345", PixelSextants, []Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}}, '\U0001fb1d'},
+		{"sextant 23456", PixelSextants, []Point{{1, 0}, {0, 1}, {1, 1}, {0, 2}, {1, 2}}, '\U0001fb3b'},
+
+		{"braille empty", PixelBraille, nil, '⠀'},
+		{"braille dot 1", PixelBraille, []Point{{0, 0}}, '⠁'},
+		{"braille dot 4", PixelBraille, []Point{{1, 0}}, '⠈'},
+		{"braille dot 7", PixelBraille, []Point{{0, 3}}, '⡀'},
+		{"braille dot 8", PixelBraille, []Point{{1, 3}}, '⢀'},
+		{"braille left column", PixelBraille, []Point{{0, 0}, {0, 1}, {0, 2}, {0, 3}}, '⡇'},
+		{"braille full", PixelBraille, []Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}, {1, 2}, {0, 3}, {1, 3}}, '⣿'},
+	}
+
+	for _, tt := range tests {
+		if got := tt.mode.glyph(pixelBits(tt.mode, tt.pts...)); got != tt.want {
+			t.Errorf("%s: glyph %U, want %U", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSextantGlyphsAllMasks(t *testing.T) {
+	// The sextant characters are ordered by the mask of their cells, where
+	// cell n of the Unicode names is bit n-1 of the mask. The masks of the
+	// empty cell, the two half blocks and the full block have no character
+	// of their own.
+	next := rune(0x1fb00)
+
+	for bits := uint(0); bits < 64; bits++ {
+		var want rune
+
+		switch bits {
+		case 0:
+			want = ' '
+		case 21:
+			want = '▌'
+		case 42:
+			want = '▐'
+		case 63:
+			want = '█'
+		default:
+			want = next
+			next++
+		}
+
+		if got := PixelSextants.glyph(bits); got != want {
+			t.Errorf("sextant mask %06b: glyph %U, want %U", bits, got, want)
+		}
+	}
+
+	if last := next - 1; last != 0x1fb3b {
+		t.Errorf("last sextant character %U, want U+1FB3B", last)
+	}
+}
+
+func TestBrailleGlyphsAllMasks(t *testing.T) {
+	// dots holds the Braille dot number of each pixel, row by row.
+	dots := [8]uint{1, 4, 2, 5, 3, 6, 7, 8}
+
+	for bits := uint(0); bits < 256; bits++ {
+		want := rune(0x2800)
+
+		for i, dot := range dots {
+			if bits&(1<<uint(i)) != 0 {
+				want += 1 << (dot - 1)
+			}
+		}
+
+		if got := PixelBraille.glyph(bits); got != want {
+			t.Errorf("braille mask %08b: glyph %U, want %U", bits, got, want)
+		}
+	}
+}
+
+func TestQuadrantGlyphsAllMasks(t *testing.T) {
+	seen := make(map[rune]uint)
+
+	for bits := uint(0); bits < 16; bits++ {
+		ch := PixelQuadrants.glyph(bits)
+
+		if other, ok := seen[ch]; ok {
+			t.Errorf("quadrant masks %04b and %04b share the glyph %q", other, bits, ch)
+		}
+
+		seen[ch] = bits
+	}
+}
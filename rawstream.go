@@ -0,0 +1,282 @@
+package caca
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/czwinzscher/libcaca-go/cacafmt"
+)
+
+// cacaHeaderSize is the size of the fixed part of a "caca" file: the magic,
+// the canvas signature and the sizes of the control and data sections.
+const cacaHeaderSize = 12
+
+// cacaCanvasMagic is the signature of a canvas in the "caca" format, as
+// written by the "raw" driver for every refresh.
+var cacaCanvasMagic = []byte{0xca, 0xca, 'C', 'V'}
+
+// rawTimedMagic starts a stream written by RawRecorder, in which every frame
+// is preceded by its timestamp.
+var rawTimedMagic = []byte{0xca, 0xca, 'T', 'S'}
+
+// RawFrame is a single frame of a "raw" driver stream.
+type RawFrame struct {
+	// Time is the time since the start of the recording at which the frame
+	// was displayed. It is only known for streams recorded with
+	// RawRecorder, otherwise it is zero.
+	Time time.Duration
+	// Data holds the frame in the native "caca" format.
+	Data []byte
+}
+
+// Import imports the frame into the canvas' current frame, like
+// ImportFromMemory() does with FormatCaca.
+//
+// If an error occurs the according errno is returned.
+func (f RawFrame) Import(cv Canvas) error {
+	n, err := cv.ImportFromMemory(f.Data, FormatCaca)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return &Error{Op: "RawFrame.Import", Err: ErrFormatUnsupported}
+	}
+
+	return nil
+}
+
+// RawDecoder splits the output of libcaca's "raw" display driver into frames.
+// The driver writes the canvas in the native "caca" format on every refresh;
+// streams recorded with RawRecorder additionally carry a timestamp for every
+// frame. Both kinds of streams are detected automatically.
+//
+// Bytes before the first frame, such as messages printed by the program
+// before the display was created, are skipped.
+type RawDecoder struct {
+	r     *bufio.Reader
+	timed bool
+	began bool
+}
+
+// NewRawDecoder returns a decoder reading a "raw" driver stream from r.
+func NewRawDecoder(r io.Reader) *RawDecoder {
+	return &RawDecoder{r: bufio.NewReader(r)}
+}
+
+// Timed reports whether the stream carries timestamps. It is only meaningful
+// once Next() returned the first frame.
+func (d *RawDecoder) Timed() bool {
+	return d.timed
+}
+
+// Next returns the next frame of the stream. Once the stream has been fully
+// consumed, io.EOF is returned. If the stream ends in the middle of a frame,
+// io.ErrUnexpectedEOF is returned.
+func (d *RawDecoder) Next() (RawFrame, error) {
+	if !d.began {
+		if err := d.start(); err != nil {
+			return RawFrame{}, err
+		}
+	}
+
+	var frame RawFrame
+
+	if d.timed {
+		var ts [8]byte
+
+		if _, err := io.ReadFull(d.r, ts[:]); err != nil {
+			return RawFrame{}, err
+		}
+
+		frame.Time = time.Duration(binary.BigEndian.Uint64(ts[:])) * time.Microsecond
+	}
+
+	hdr, err := d.r.Peek(cacaHeaderSize)
+	if err != nil {
+		if len(hdr) == 0 && errors.Is(err, io.EOF) && !d.timed {
+			return RawFrame{}, io.EOF
+		}
+
+		return RawFrame{}, unexpectedEOF(err)
+	}
+
+	if !bytes.HasPrefix(hdr, cacaCanvasMagic) {
+		return RawFrame{}, &Error{Op: "RawDecoder.Next", Err: ErrFormatUnsupported}
+	}
+
+	n, err := cacafmt.Len(hdr)
+	if err != nil {
+		return RawFrame{}, &Error{Op: "RawDecoder.Next", Err: ErrFormatUnsupported}
+	}
+
+	frame.Data = make([]byte, n)
+	if _, err := io.ReadFull(d.r, frame.Data); err != nil {
+		return RawFrame{}, unexpectedEOF(err)
+	}
+
+	return frame, nil
+}
+
+// start skips to the first frame and detects whether the stream is timed.
+func (d *RawDecoder) start() error {
+	for {
+		b, err := d.r.Peek(len(cacaCanvasMagic))
+
+		if bytes.Equal(b, rawTimedMagic) {
+			_, _ = d.r.Discard(len(rawTimedMagic))
+			d.timed = true
+			d.began = true
+
+			return nil
+		}
+
+		if bytes.Equal(b, cacaCanvasMagic) {
+			d.began = true
+
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		_, _ = d.r.Discard(1)
+	}
+}
+
+// unexpectedEOF turns io.EOF in the middle of a frame into
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// RawRecorder records the output of libcaca's "raw" display driver together
+// with the time at which each frame arrived, so that RawDecoder can replay
+// the recording at its original speed. It is meant to be used as the
+// standard output of a program using the "raw" driver:
+//
+//     rec := caca.NewRawRecorder(file)
+//     cmd := exec.Command("app")
+//     cmd.Env = append(os.Environ(), "CACA_DRIVER=raw")
+//     cmd.Stdout = rec
+//
+// Times are measured from the first call to Write(). RawRecorder is safe for
+// concurrent use.
+type RawRecorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	buf   []byte
+	start time.Time
+	began bool
+	err   error
+}
+
+// NewRawRecorder returns a recorder writing a timed stream to w.
+func NewRawRecorder(w io.Writer) *RawRecorder {
+	return &RawRecorder{w: w}
+}
+
+// Write records the driver output in p. Frames may be split over several
+// calls; a frame is written once it is complete, stamped with the time at
+// which its last byte arrived.
+func (rec *RawRecorder) Write(p []byte) (int, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.err != nil {
+		return 0, rec.err
+	}
+
+	now := time.Now()
+
+	if !rec.began {
+		rec.start = now
+		rec.began = true
+
+		if _, rec.err = rec.w.Write(rawTimedMagic); rec.err != nil {
+			return 0, rec.err
+		}
+	}
+
+	rec.buf = append(rec.buf, p...)
+	base := rec.buf
+
+	for {
+		// Skip anything that is not a frame, such as debug output.
+		i := bytes.Index(rec.buf, cacaCanvasMagic)
+		if i == -1 {
+			// Keep a tail that may be the beginning of the next magic.
+			rec.buf = rec.buf[len(rec.buf)-minInt(len(rec.buf), len(cacaCanvasMagic)-1):]
+
+			break
+		}
+
+		rec.buf = rec.buf[i:]
+
+		if len(rec.buf) < cacaHeaderSize {
+			break
+		}
+
+		n, err := cacafmt.Len(rec.buf)
+		if err != nil {
+			// Not a real frame header, resynchronise on the next one.
+			rec.buf = rec.buf[1:]
+
+			continue
+		}
+
+		if len(rec.buf) < n {
+			break
+		}
+
+		var ts [8]byte
+
+		binary.BigEndian.PutUint64(ts[:], uint64(now.Sub(rec.start)/time.Microsecond))
+
+		if _, rec.err = rec.w.Write(ts[:]); rec.err != nil {
+			return 0, rec.err
+		}
+
+		if _, rec.err = rec.w.Write(rec.buf[:n]); rec.err != nil {
+			return 0, rec.err
+		}
+
+		rec.buf = rec.buf[n:]
+	}
+
+	// Move the unconsumed rest, which the loop only ever slices off the
+	// front of base, back to the start of the buffer so that its capacity is
+	// reused instead of growing without bounds.
+	rec.buf = base[:copy(base, rec.buf)]
+
+	return len(p), nil
+}
+
+// Close reports io.ErrUnexpectedEOF if the recording ends in the middle of a
+// frame. It does not close the underlying writer.
+func (rec *RawRecorder) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.err != nil {
+		return rec.err
+	}
+
+	// After Write() the buffer either holds the start of an incomplete frame
+	// or a few bytes too short to be one.
+	if len(rec.buf) >= len(cacaCanvasMagic) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
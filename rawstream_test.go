@@ -0,0 +1,320 @@
+package caca
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/czwinzscher/libcaca-go/cacafmt"
+)
+
+// rawTestFrame encodes a w×h canvas filled with ch in the "caca" format.
+func rawTestFrame(t *testing.T, w int, h int, ch rune) []byte {
+	t.Helper()
+
+	f := cacafmt.Frame{Chars: make([]rune, w*h), Attrs: make([]uint32, w*h)}
+	for i := range f.Chars {
+		f.Chars[i] = ch
+	}
+
+	data, err := (&cacafmt.Canvas{Width: w, Height: h, Frames: []cacafmt.Frame{f}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// decodeRaw decodes every frame of a raw stream and returns the error that
+// ended it, nil for io.EOF.
+func decodeRaw(stream []byte) ([]RawFrame, bool, error) {
+	dec := NewRawDecoder(bytes.NewReader(stream))
+
+	var frames []RawFrame
+
+	for {
+		frame, err := dec.Next()
+		if err == io.EOF {
+			return frames, dec.Timed(), nil
+		}
+
+		if err != nil {
+			return frames, dec.Timed(), err
+		}
+
+		frames = append(frames, frame)
+	}
+}
+
+func checkRawFrames(t *testing.T, got []RawFrame, want [][]byte) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if !bytes.Equal(got[i].Data, want[i]) {
+			t.Errorf("frame %d = % x, want % x", i, got[i].Data, want[i])
+		}
+
+		if i > 0 && got[i].Time < got[i-1].Time {
+			t.Errorf("frame %d at %v is before frame %d at %v", i, got[i].Time, i-1, got[i-1].Time)
+		}
+	}
+}
+
+func TestRawDecoderUntimed(t *testing.T) {
+	a, b := rawTestFrame(t, 2, 1, 'a'), rawTestFrame(t, 3, 2, 'b')
+	stream := append([]byte("starting up\n\xca\xca"), a...)
+	stream = append(stream, b...)
+
+	frames, timed, err := decodeRaw(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if timed {
+		t.Error("Timed() = true for a plain stream")
+	}
+
+	checkRawFrames(t, frames, [][]byte{a, b})
+
+	for i, f := range frames {
+		if f.Time != 0 {
+			t.Errorf("frame %d has time %v", i, f.Time)
+		}
+	}
+}
+
+func TestRawDecoderEmpty(t *testing.T) {
+	for _, stream := range []string{"", "no frames here"} {
+		if _, err := NewRawDecoder(bytes.NewReader([]byte(stream))).Next(); err != io.EOF {
+			t.Errorf("Next() on %q = %v, want io.EOF", stream, err)
+		}
+	}
+}
+
+func TestRawRecorderSplitWrites(t *testing.T) {
+	want := [][]byte{rawTestFrame(t, 2, 2, 'x'), rawTestFrame(t, 1, 1, 'y'), rawTestFrame(t, 4, 1, 'z')}
+
+	input := []byte("debug output\n")
+	for _, f := range want {
+		input = append(input, f...)
+	}
+
+	for _, chunk := range []int{1, 3, len(cacaCanvasMagic), cacaHeaderSize + 1, len(input)} {
+		var out bytes.Buffer
+
+		rec := NewRawRecorder(&out)
+
+		for p := input; len(p) > 0; {
+			n := minInt(chunk, len(p))
+
+			if m, err := rec.Write(p[:n]); m != n || err != nil {
+				t.Fatalf("chunk %d: Write() = %d, %v", chunk, m, err)
+			}
+
+			p = p[n:]
+		}
+
+		if err := rec.Close(); err != nil {
+			t.Fatalf("chunk %d: Close() = %v", chunk, err)
+		}
+
+		frames, timed, err := decodeRaw(out.Bytes())
+		if err != nil {
+			t.Fatalf("chunk %d: %v", chunk, err)
+		}
+
+		if !timed {
+			t.Errorf("chunk %d: Timed() = false for a recording", chunk)
+		}
+
+		checkRawFrames(t, frames, want)
+	}
+}
+
+func TestRawRecorderResync(t *testing.T) {
+	a, b := rawTestFrame(t, 2, 1, 'a'), rawTestFrame(t, 1, 2, 'b')
+
+	// A magic followed by impossible section sizes is not a frame.
+	bogus := append(append([]byte{}, cacaCanvasMagic...), 0, 0, 0, 1, 0, 0, 0, 0)
+
+	input := append([]byte("noise"), a...)
+	input = append(input, bogus...)
+	input = append(input, "\xca\xcamore noise"...)
+	input = append(input, b...)
+
+	var out bytes.Buffer
+
+	rec := NewRawRecorder(&out)
+
+	if _, err := rec.Write(input); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	frames, _, err := decodeRaw(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRawFrames(t, frames, [][]byte{a, b})
+}
+
+func TestRawRecorderCloseTruncated(t *testing.T) {
+	a := rawTestFrame(t, 2, 2, 'a')
+
+	var out bytes.Buffer
+
+	rec := NewRawRecorder(&out)
+
+	if _, err := rec.Write(append(append([]byte{}, a...), a[:len(a)-1]...)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rec.Close(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Close() = %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	// The complete frame was recorded nonetheless.
+	frames, _, err := decodeRaw(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRawFrames(t, frames, [][]byte{a})
+}
+
+func TestRawDecoderTruncated(t *testing.T) {
+	a := rawTestFrame(t, 3, 1, 'a')
+
+	var out bytes.Buffer
+
+	rec := NewRawRecorder(&out)
+
+	if _, err := rec.Write(a); err != nil {
+		t.Fatal(err)
+	}
+
+	timed := out.Bytes()
+
+	tests := []struct {
+		name   string
+		stream []byte
+		frames int
+	}{
+		{"untimed in header", a[:cacaHeaderSize-2], 0},
+		{"untimed in cells", append(append([]byte{}, a...), a[:len(a)-4]...), 1},
+		{"timed in timestamp", timed[:len(rawTimedMagic)+3], 0},
+		{"timed after timestamp", timed[:len(rawTimedMagic)+8], 0},
+		{"timed in cells", timed[:len(timed)-1], 0},
+	}
+
+	for _, tt := range tests {
+		frames, _, err := decodeRaw(tt.stream)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: error = %v, want io.ErrUnexpectedEOF", tt.name, err)
+		}
+
+		if len(frames) != tt.frames {
+			t.Errorf("%s: got %d frames, want %d", tt.name, len(frames), tt.frames)
+		}
+	}
+}
+
+func TestRawDecoderGarbageBetweenFrames(t *testing.T) {
+	a := rawTestFrame(t, 1, 1, 'a')
+	stream := append(append(append([]byte{}, a...), "garbage!"...), a...)
+
+	frames, _, err := decodeRaw(stream)
+	if !errors.Is(err, ErrFormatUnsupported) {
+		t.Errorf("error = %v, want ErrFormatUnsupported", err)
+	}
+
+	checkRawFrames(t, frames, [][]byte{a})
+}
+
+// wrappedEOFReader reads from r and wraps the io.EOF at its end.
+type wrappedEOFReader struct {
+	r io.Reader
+}
+
+func (w wrappedEOFReader) Read(p []byte) (int, error) {
+	n, err := w.r.Read(p)
+	if err == io.EOF {
+		err = fmt.Errorf("end of recording: %w", err)
+	}
+
+	return n, err
+}
+
+func TestRawDecoderWrappedEOF(t *testing.T) {
+	a := rawTestFrame(t, 2, 1, 'a')
+
+	dec := NewRawDecoder(wrappedEOFReader{bytes.NewReader(a)})
+
+	if _, err := dec.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next() at the end = %v, want io.EOF", err)
+	}
+
+	// A wrapped io.EOF in the middle of a frame is still unexpected.
+	dec = NewRawDecoder(wrappedEOFReader{bytes.NewReader(a[:len(a)-1])})
+
+	if _, err := dec.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Next() on a truncated frame = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestRawRecorderReusesBuffer(t *testing.T) {
+	a := rawTestFrame(t, 4, 2, 'a')
+
+	rec := NewRawRecorder(io.Discard)
+
+	// Write many frames in pieces that straddle the frame boundaries, so
+	// that a partial frame is always left in the buffer.
+	stream := bytes.Repeat(a, 200)
+	for len(stream) > 0 {
+		n := minInt(len(stream), len(a)/3+1)
+
+		if _, err := rec.Write(stream[:n]); err != nil {
+			t.Fatal(err)
+		}
+
+		stream = stream[n:]
+	}
+
+	if c := cap(rec.buf); c > 4*len(a) {
+		t.Errorf("buffer capacity %d after 200 frames of %d bytes", c, len(a))
+	}
+}
+
+func TestRawRecorderWriteDoesNotAllocate(t *testing.T) {
+	rec := NewRawRecorder(io.Discard)
+
+	// Output that is not a frame is only buffered, which must not allocate
+	// once the buffer has grown.
+	noise := []byte("no frame here")
+
+	if _, err := rec.Write(noise); err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = rec.Write(noise)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Write() allocates %v times per call", allocs)
+	}
+}
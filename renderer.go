@@ -0,0 +1,117 @@
+package caca
+
+import (
+	"io"
+)
+
+// ANSIRenderer draws a canvas on a terminal through an io.Writer, such as an
+// SSH session or a pipe, without a libcaca display driver. After the first
+// full redraw, Flush() only updates the cells inside the canvas' dirty
+// rectangles that changed since the previous flush, using as few cursor
+// moves and SGR sequences as it can.
+//
+// The renderer draws the canvas' current frame at the top-left corner of the
+// terminal and assumes nothing else writes to the terminal in the meantime.
+// Call Redraw() after the terminal was cleared or resized.
+type ANSIRenderer struct {
+	cv     Canvas
+	w      io.Writer
+	term   *termWriter
+	width  int
+	height int
+	// chars and attrs hold the cells shown on the terminal; cvChars and
+	// cvAttrs mirror the canvas and are only updated inside the dirty
+	// rectangles, so that a flush does not copy the whole canvas.
+	chars   []rune
+	attrs   []Attr
+	cvChars []rune
+	cvAttrs []Attr
+}
+
+// NewANSIRenderer returns a renderer drawing the canvas to w with the given
+// colour escape sequences.
+func NewANSIRenderer(cv Canvas, w io.Writer, colors TermColors) *ANSIRenderer {
+	cv.mustLive()
+
+	return &ANSIRenderer{cv: cv, w: w, term: newTermWriter(colors)}
+}
+
+// Flush writes the changes of the canvas since the previous flush and clears
+// the canvas' dirty rectangle list. The first flush, and every flush after
+// the canvas was resized, redraws the whole screen; other flushes only read
+// the cells inside the dirty rectangles from the canvas.
+func (r *ANSIRenderer) Flush() error {
+	width, height := r.cv.GetWidth(), r.cv.GetHeight()
+
+	if r.chars == nil || width != r.width || height != r.height {
+		return r.Redraw()
+	}
+
+	bounds := NewRect(0, 0, width, height)
+	rects := r.cv.DirtyRects()
+
+	// Fetch all rectangles before drawing any, as drawing a fullwidth
+	// character cut by a rectangle looks at the cells next to it. The
+	// rectangles are widened by a cell on each side for the same reason.
+	for i, rect := range rects {
+		rects[i] = NewRect(rect.X-1, rect.Y, rect.Width+2, rect.Height).Intersect(bounds)
+		r.cv.readCells(rects[i], r.cvChars, r.cvAttrs)
+	}
+
+	for _, rect := range rects {
+		if rect.Empty() {
+			continue
+		}
+
+		for y := rect.Y; y < rect.Y+rect.Height; y++ {
+			row := y * width
+			r.term.putRow(y, rect.X, rect.X+rect.Width, r.cvChars[row:row+width], r.cvAttrs[row:row+width],
+				r.chars[row:row+width], r.attrs[row:row+width])
+
+			// Overlapping rectangles compare with what was just drawn.
+			copy(r.chars[row+rect.X:row+rect.X+rect.Width], r.cvChars[row+rect.X:])
+			copy(r.attrs[row+rect.X:row+rect.X+rect.Width], r.cvAttrs[row+rect.X:])
+		}
+	}
+
+	return r.finish()
+}
+
+// Redraw clears the terminal and draws the whole canvas, then clears the
+// canvas' dirty rectangle list.
+func (r *ANSIRenderer) Redraw() error {
+	r.width, r.height = r.cv.GetWidth(), r.cv.GetHeight()
+	r.chars, r.attrs = r.cv.GetChars(), r.cv.GetAttrs()
+	r.cvChars = append(r.cvChars[:0], r.chars...)
+	r.cvAttrs = append(r.cvAttrs[:0], r.attrs...)
+
+	r.term.clear()
+
+	for y := 0; y < r.height; y++ {
+		row := y * r.width
+		r.term.putRow(y, 0, r.width, r.chars[row:row+r.width], r.attrs[row:row+r.width], nil, nil)
+	}
+
+	return r.finish()
+}
+
+// finish writes the pending output and clears the dirty rectangle list.
+func (r *ANSIRenderer) finish() error {
+	r.cv.ClearDirtyRectList()
+
+	if r.term.buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := r.w.Write(r.term.buf.Bytes())
+	r.term.buf.Reset()
+
+	if err != nil {
+		// The terminal state is unknown after a failed write, so the next
+		// flush starts over.
+		r.chars = nil
+		r.term.reset()
+	}
+
+	return err
+}
@@ -0,0 +1,190 @@
+package caca
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSGRSequence(t *testing.T) {
+	red := NewAttrAnsi(ColorRed, ColorBlue, StyleBold)
+	bright := NewAttrAnsi(ColorLightred, ColorYellow, StyleItalics|StyleUnderline|StyleBlink)
+	def := NewAttrAnsi(ColorDefault, ColorTransparent, 0)
+	argb := NewAttrARGB(0xff80, 0xf124, 0)
+	bw := NewAttrARGB(0xf000, 0xffff, 0)
+
+	tests := []struct {
+		attr   Attr
+		colors TermColors
+		want   string
+	}{
+		{red, TermColors16, "\x1b[0;1;31;44m"},
+		{bright, TermColors16, "\x1b[0;3;4;5;91;103m"},
+		{def, TermColors16, "\x1b[0;39;49m"},
+		{bw, TermColors16, "\x1b[0;30;107m"},
+
+		// ANSI colours are written the same way in every mode.
+		{red, TermColors256, "\x1b[0;1;31;44m"},
+		{def, TermColors256, "\x1b[0;39;49m"},
+		{argb, TermColors256, "\x1b[0;38;5;214;48;5;23m"},
+		{bw, TermColors256, "\x1b[0;38;5;16;48;5;231m"},
+
+		{red, TermColorsTrue, "\x1b[0;1;31;44m"},
+		{def, TermColorsTrue, "\x1b[0;39;49m"},
+		{argb, TermColorsTrue, "\x1b[0;38;2;255;136;0;48;2;17;34;68m"},
+		{bw, TermColorsTrue, "\x1b[0;38;2;0;0;0;48;2;255;255;255m"},
+	}
+
+	for _, tt := range tests {
+		if got := sgrSequence(tt.attr, tt.colors); got != tt.want {
+			t.Errorf("sgrSequence(%#x, %d) = %q, want %q", tt.attr, tt.colors, got, tt.want)
+		}
+	}
+}
+
+func TestANSIRendererColors(t *testing.T) {
+	cv, err := CreateCanvas(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	cv.SetAttr(NewAttrARGB(0xff80, 0xf124, StyleBold))
+	cv.PutChar(0, 0, 'x')
+
+	for colors, want := range map[TermColors]string{
+		TermColors16:   "\x1b[0m\x1b[H\x1b[2J\x1b[1;1H" + sgrSequence(cv.GetAttr(0, 0), TermColors16) + "x",
+		TermColors256:  "\x1b[0m\x1b[H\x1b[2J\x1b[1;1H\x1b[0;1;38;5;214;48;5;23mx",
+		TermColorsTrue: "\x1b[0m\x1b[H\x1b[2J\x1b[1;1H\x1b[0;1;38;2;255;136;0;48;2;17;34;68mx",
+	} {
+		var buf bytes.Buffer
+
+		if err := NewANSIRenderer(cv, &buf, colors).Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != want {
+			t.Errorf("colors %d: output %q, want %q", colors, buf.String(), want)
+		}
+	}
+}
+
+// failWriter fails every write.
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestANSIRendererMinimalDiff(t *testing.T) {
+	cv, err := CreateCanvas(8, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	red := NewAttrAnsi(ColorRed, ColorBlue, 0)
+
+	cv.SetAttr(NewAttrAnsi(ColorDefault, ColorDefault, 0))
+	cv.Clear()
+
+	var buf bytes.Buffer
+
+	r := NewANSIRenderer(cv, &buf, TermColors16)
+
+	// flush checks the output of a flush against the expected outputs, one of
+	// which must match.
+	flush := func(name string, want ...string) {
+		t.Helper()
+
+		buf.Reset()
+
+		if err := r.Flush(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		for _, w := range want {
+			if buf.String() == w {
+				return
+			}
+		}
+
+		t.Errorf("%s: output %q, want %q", name, buf.String(), want[0])
+	}
+
+	// The first flush redraws everything.
+	buf.Reset()
+
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "\x1b[0m\x1b[H\x1b[2J\x1b[1;1H\x1b[0;39;49m"; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("first flush starts with %q, want %q", buf.String(), want)
+	}
+
+	flush("nothing changed", "")
+
+	cv.PutStr(2, 1, "hi")
+	flush("characters", "\x1b[2;3Hhi")
+
+	// A dirty cell whose content did not change is not written.
+	cv.PutChar(2, 1, 'h')
+	flush("same character", "")
+
+	cv.SetAttr(red)
+	cv.PutChar(5, 1, 'x')
+	flush("attribute", "\x1b[1C\x1b[0;31;44mx")
+
+	cv.PutChar(0, 1, 'a')
+	flush("start of line", "\ra")
+
+	cv.PutStr(3, 0, "日")
+	flush("fullwidth", "\x1b[1;4H日")
+
+	// Two changes in one flush, drawn in the order of the dirty rectangles,
+	// which libcaca may also merge into one.
+	cv.PutChar(7, 2, 'z')
+	cv.PutChar(1, 0, 'w')
+	flush("two changes", "\x1b[3;8Hz\x1b[1;2Hw", "\x1b[1;2Hw\x1b[3;8Hz")
+
+	// The renderer starts over after a failed write.
+	r.w = failWriter{}
+	cv.PutChar(0, 0, 'f')
+
+	if err := r.Flush(); err == nil {
+		t.Error("Flush() to a failing writer succeeded")
+	}
+
+	r.w = &buf
+	buf.Reset()
+
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "\x1b[0m\x1b[H\x1b[2J") {
+		t.Errorf("flush after a failed write %q is not a redraw", buf.String())
+	}
+
+	// So it does after a resize.
+	cv.SetAttr(NewAttrAnsi(ColorDefault, ColorDefault, 0))
+
+	if err := cv.SetSize(4, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "\x1b[0m\x1b[H\x1b[2J") {
+		t.Errorf("flush after a resize %q is not a redraw", buf.String())
+	}
+
+	cv.PutChar(3, 1, 'q')
+	flush("after resize", "\x1b[2;4Hq")
+}
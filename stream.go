@@ -0,0 +1,157 @@
+package caca
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// importChunkSize is the number of bytes read from the underlying reader at a
+// time while waiting for a complete frame.
+const importChunkSize = 32 * 1024
+
+// cacaMagic is the signature at the start of every file in the native "caca"
+// format.
+var cacaMagic = []byte{0xca, 0xca}
+
+// Importer imports successive canvases from a stream, such as a pipe or a
+// network connection. Data is read from the underlying reader only as far as
+// needed, bytes belonging to the next canvas are kept for the next call to
+// Import().
+//
+// Only the native FormatCaca files carry their own length, so only they can
+// be split into several canvases. All other formats, including text and ANSI
+// files, extend to the end of the stream and are imported in one go once the
+// reader reports io.EOF.
+type Importer struct {
+	r      io.Reader
+	format Format
+	buf    []byte
+	chunk  []byte
+	err    error
+}
+
+// NewImporter returns an Importer that reads canvases of the given format
+// from r. FormatAuto detects the format of every canvas separately.
+func NewImporter(r io.Reader, format Format) *Importer {
+	return &Importer{r: r, format: format}
+}
+
+// Import reads the next canvas from the stream and imports it into cv's
+// current frame, like ImportFromMemory() does. The number of bytes consumed is
+// returned.
+//
+// Once the stream has been fully consumed, io.EOF is returned. If the stream
+// ends in the middle of a canvas, io.ErrUnexpectedEOF is returned. Errors
+// returned by the underlying reader are passed on unchanged.
+func (im *Importer) Import(cv Canvas) (int, error) {
+	if err := checkImportFormat("Importer.Import", im.format); err != nil {
+		return 0, err
+	}
+
+	for {
+		if im.err == nil && !im.framed() {
+			im.fill()
+
+			continue
+		}
+
+		if im.err != nil && !errors.Is(im.err, io.EOF) {
+			return 0, im.err
+		}
+
+		if len(im.buf) == 0 && im.err != nil {
+			return 0, io.EOF
+		}
+
+		// libcaca only autodetects canvases of at least 4 bytes, a shorter
+		// start of a framed canvas would be imported as text.
+		format := im.format
+		if format == FormatAuto && im.framed() {
+			format = FormatCaca
+		}
+
+		n, err := cv.ImportFromMemory(im.buf, format)
+		if err != nil {
+			return 0, err
+		}
+
+		if n > 0 {
+			im.buf = im.buf[n:]
+
+			return n, nil
+		}
+
+		if im.err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		im.fill()
+	}
+}
+
+// framed reports whether the buffered data is known to be in a format that
+// carries its own length, so that it can be imported before the end of the
+// stream is reached.
+func (im *Importer) framed() bool {
+	switch im.format {
+	case FormatCaca:
+		return true
+	case FormatAuto:
+		return bytes.HasPrefix(im.buf, cacaMagic)
+	default:
+		return false
+	}
+}
+
+// fill reads the next chunk from the underlying reader into the buffer. The
+// chunk is read into a scratch slice that is reused by every call.
+func (im *Importer) fill() {
+	if im.chunk == nil {
+		im.chunk = make([]byte, importChunkSize)
+	}
+
+	n, err := im.r.Read(im.chunk)
+	im.buf = append(im.buf, im.chunk[:n]...)
+
+	if err != nil {
+		im.err = err
+	}
+}
+
+// ImportFrom reads a single canvas from r and imports it into the canvas'
+// current frame, like ImportFromMemory() does. The number of bytes consumed is
+// returned.
+//
+// Data that was read from r but belongs to a following canvas is discarded,
+// use an Importer to import successive canvases from the same stream.
+func (cv Canvas) ImportFrom(r io.Reader, format Format) (int, error) {
+	return NewImporter(r, format).Import(cv)
+}
+
+// ExportTo exports the canvas in the given format, like ExportToMemory() does,
+// and writes the result to w. The number of bytes written is returned.
+func (cv Canvas) ExportTo(w io.Writer, format Format) (int64, error) {
+	data, err := cv.ExportToMemory(format)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+
+	return int64(n), err
+}
+
+// ExportAreaTo exports the area r of the canvas in the given format, like
+// ExportAreaToMemory() does, and writes the result to w. The number of bytes
+// written is returned.
+func (cv Canvas) ExportAreaTo(w io.Writer, r Rect, format Format) (int64, error) {
+	data, err := cv.ExportRect(r, format)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+
+	return int64(n), err
+}
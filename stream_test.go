@@ -0,0 +1,143 @@
+package caca
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestImporterConsecutiveCanvases(t *testing.T) {
+	frames := [][]byte{
+		rawTestFrame(t, 3, 2, 'a'),
+		rawTestFrame(t, 5, 1, 'b'),
+		rawTestFrame(t, 1, 4, 'c'),
+	}
+	sizes := []Rect{NewRect(0, 0, 3, 2), NewRect(0, 0, 5, 1), NewRect(0, 0, 1, 4)}
+	stream := bytes.Join(frames, nil)
+
+	for _, format := range []Format{FormatCaca, FormatAuto} {
+		cv, err := CreateCanvas(0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Read one byte at a time, so that every canvas needs many reads.
+		im := NewImporter(iotest.OneByteReader(bytes.NewReader(stream)), format)
+
+		for i, frame := range frames {
+			n, err := im.Import(cv)
+			if err != nil {
+				t.Fatalf("format %q, canvas %d: %v", format, i, err)
+			}
+
+			if n != len(frame) {
+				t.Errorf("format %q, canvas %d: consumed %d bytes, want %d", format, i, n, len(frame))
+			}
+
+			if w, h := cv.GetWidth(), cv.GetHeight(); w != sizes[i].Width || h != sizes[i].Height {
+				t.Errorf("format %q, canvas %d: size %dx%d, want %dx%d", format, i, w, h, sizes[i].Width, sizes[i].Height)
+			}
+
+			if ch, want := cv.GetChar(0, 0), rune('a'+i); ch != want {
+				t.Errorf("format %q, canvas %d: character %q, want %q", format, i, ch, want)
+			}
+		}
+
+		for i := 0; i < 2; i++ {
+			if n, err := im.Import(cv); n != 0 || err != io.EOF {
+				t.Errorf("format %q: Import() at the end = %d, %v, want 0, %v", format, n, err, io.EOF)
+			}
+		}
+
+		cv.Free()
+	}
+}
+
+func TestImporterTruncatedStream(t *testing.T) {
+	first := rawTestFrame(t, 2, 2, 'a')
+	second := rawTestFrame(t, 2, 2, 'b')
+
+	cv, err := CreateCanvas(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	tests := []struct {
+		format Format
+		cut    int
+	}{
+		{FormatCaca, 1},
+		{FormatAuto, 2},
+		{FormatAuto, 3},
+		{FormatCaca, len(second) / 2},
+		{FormatAuto, len(second) / 2},
+		{FormatAuto, len(second) - 1},
+	}
+
+	for _, tt := range tests {
+		stream := append(append([]byte(nil), first...), second[:tt.cut]...)
+		im := NewImporter(bytes.NewReader(stream), tt.format)
+
+		if _, err := im.Import(cv); err != nil {
+			t.Fatalf("format %q, %d bytes of the second canvas: first canvas: %v", tt.format, tt.cut, err)
+		}
+
+		if _, err := im.Import(cv); err != io.ErrUnexpectedEOF {
+			t.Errorf("format %q, %d bytes of the second canvas: Import() error %v, want %v", tt.format, tt.cut, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestImporterUnframedFormat(t *testing.T) {
+	const text = "hello\nworld"
+
+	cv, err := CreateCanvas(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	im := NewImporter(iotest.OneByteReader(strings.NewReader(text)), FormatText)
+
+	n, err := im.Import(cv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(text) {
+		t.Errorf("consumed %d bytes, want %d", n, len(text))
+	}
+
+	if got := canvasRows(cv); strings.Join(got, "|") != "hello|world" {
+		t.Errorf("canvas %q, want [\"hello\" \"world\"]", got)
+	}
+
+	if _, err := im.Import(cv); err != io.EOF {
+		t.Errorf("Import() after the text = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestImporterReaderError(t *testing.T) {
+	errRead := errors.New("read failed")
+	frame := rawTestFrame(t, 2, 1, 'a')
+
+	cv, err := CreateCanvas(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	im := NewImporter(io.MultiReader(bytes.NewReader(frame), iotest.ErrReader(errRead)), FormatCaca)
+
+	if _, err := im.Import(cv); err != nil {
+		t.Fatalf("canvas before the error: %v", err)
+	}
+
+	if _, err := im.Import(cv); !errors.Is(err, errRead) {
+		t.Errorf("Import() error %v, want %v", err, errRead)
+	}
+}
@@ -0,0 +1,94 @@
+package caca
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// tabWidth is the distance between two tab stops used by the canvas writer.
+const tabWidth = 8
+
+// Printf formats according to a format specifier and prints the resulting
+// string at the given coordinates, like PutStr() does.
+//
+// This function returns the number of cells printed by the string.
+func (cv Canvas) Printf(x int, y int, format string, args ...interface{}) int {
+	return cv.PutStr(x, y, fmt.Sprintf(format, args...))
+}
+
+// canvasWriter implements the io.Writer returned by Canvas.Writer().
+type canvasWriter struct {
+	cv      Canvas
+	wrap    bool
+	pending []byte
+}
+
+// Writer returns an io.Writer that prints UTF-8 text on the canvas at the
+// cursor position set by GoToXY(), using the default foreground and background
+// values. After each write the cursor is moved behind the printed text, so
+// consecutive writes continue where the previous one stopped.
+//
+// A newline moves the cursor to the beginning of the next line and a carriage
+// return to the beginning of the current line. A tab advances the cursor to
+// the next multiple of 8 columns.
+//
+// If wrap is true, text reaching the right edge of the canvas continues on the
+// next line. Otherwise it is clipped until the next newline. Text below the
+// last line is clipped in both cases.
+//
+// UTF-8 sequences split across several writes are handled correctly. The
+// writer never returns an error.
+func (cv Canvas) Writer(wrap bool) io.Writer {
+	return &canvasWriter{cv: cv, wrap: wrap}
+}
+
+func (w *canvasWriter) Write(p []byte) (int, error) {
+	buf := append(w.pending, p...)
+	w.pending = nil
+
+	x, y := w.cv.WhereX(), w.cv.WhereY()
+	width := w.cv.GetWidth()
+
+	for len(buf) > 0 {
+		if !utf8.FullRune(buf) {
+			w.pending = append([]byte(nil), buf...)
+
+			break
+		}
+
+		ch, size := utf8.DecodeRune(buf)
+		buf = buf[size:]
+
+		switch ch {
+		case '\n':
+			x = 0
+			y++
+		case '\r':
+			x = 0
+		case '\t':
+			x = (x/tabWidth + 1) * tabWidth
+			if w.wrap && x >= width {
+				x = 0
+				y++
+			}
+		default:
+			cw := 1
+			if UTF32IsFullwidth(uint32(ch)) {
+				cw = 2
+			}
+
+			if w.wrap && x > 0 && x+cw > width {
+				x = 0
+				y++
+			}
+
+			w.cv.PutChar(x, y, ch)
+			x += cw
+		}
+	}
+
+	w.cv.GoToXY(x, y)
+
+	return len(p), nil
+}
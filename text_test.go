@@ -0,0 +1,113 @@
+package caca
+
+import (
+	"strings"
+	"testing"
+)
+
+// canvasRows returns the characters of the canvas' current frame, one string
+// per row without trailing spaces. The right halves of fullwidth characters
+// are left out.
+func canvasRows(cv Canvas) []string {
+	chars := cv.GetChars()
+	width := cv.GetWidth()
+	rows := make([]string, cv.GetHeight())
+
+	for y := range rows {
+		var b strings.Builder
+
+		for _, ch := range chars[y*width : (y+1)*width] {
+			if ch != MagicFullwidth {
+				b.WriteRune(ch)
+			}
+		}
+
+		rows[y] = strings.TrimRight(b.String(), " ")
+	}
+
+	return rows
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		wrap  bool
+		input string
+		want  []string
+		x, y  int
+	}{
+		{"plain", false, "héllo", []string{"héllo", "", ""}, 5, 0},
+		{"newline", false, "ab\ncd", []string{"ab", "cd", ""}, 2, 1},
+		{"carriage return", false, "abc\rX", []string{"Xbc", "", ""}, 1, 0},
+		{"crlf", false, "ab\r\ncd", []string{"ab", "cd", ""}, 2, 1},
+		{"tab", false, "a\tb", []string{"a       b", "", ""}, 9, 0},
+		{"tab at stop", false, "abcdefgh\tX", []string{"abcdefgh", "", ""}, 17, 0},
+		{"tab wrapped", true, "abcdefghi\tX", []string{"abcdefghi", "X", ""}, 1, 1},
+		{"tab clipped", false, "abcdefghi\tX\nY", []string{"abcdefghi", "Y", ""}, 1, 1},
+		{"wrapped", true, "abcdefghijkl", []string{"abcdefghij", "kl", ""}, 2, 1},
+		{"clipped", false, "abcdefghijkl\nZ", []string{"abcdefghij", "Z", ""}, 1, 1},
+		{"fullwidth", false, "a日b", []string{"a日b", "", ""}, 4, 0},
+		{"fullwidth wrapped", true, "abcdefghi日", []string{"abcdefghi", "日", ""}, 2, 1},
+		{"below last line", true, "a\nb\nc\nd", []string{"a", "b", "c"}, 1, 3},
+	}
+
+	for _, tt := range tests {
+		// Write everything at once, then byte by byte, which splits every
+		// multibyte sequence across several writes.
+		for _, chunk := range []int{len(tt.input), 1} {
+			cv, err := CreateCanvas(10, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := cv.Writer(tt.wrap)
+
+			for i := 0; i < len(tt.input); i += chunk {
+				p := []byte(tt.input[i:minInt(i+chunk, len(tt.input))])
+
+				if n, err := w.Write(p); n != len(p) || err != nil {
+					t.Fatalf("%s: Write(%q) = %d, %v, want %d, nil", tt.name, p, n, err, len(p))
+				}
+			}
+
+			got := canvasRows(cv)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("%s, %d byte writes: canvas %q, want %q", tt.name, chunk, got, tt.want)
+			}
+
+			if x, y := cv.WhereX(), cv.WhereY(); x != tt.x || y != tt.y {
+				t.Errorf("%s, %d byte writes: cursor at (%d, %d), want (%d, %d)", tt.name, chunk, x, y, tt.x, tt.y)
+			}
+
+			cv.Free()
+		}
+	}
+}
+
+func TestWriterPendingSequence(t *testing.T) {
+	cv, err := CreateCanvas(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	w := cv.Writer(false)
+	seq := []byte("日")
+
+	// An incomplete sequence is held back until the rest of it arrives.
+	if _, err := w.Write(seq[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := canvasRows(cv); got[0] != "" || cv.WhereX() != 0 {
+		t.Errorf("canvas %q, cursor at column %d after a partial sequence, want an empty canvas and column 0", got, cv.WhereX())
+	}
+
+	if _, err := w.Write(append(seq[2:], 'x')); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := canvasRows(cv); got[0] != "日x" || cv.WhereX() != 3 {
+		t.Errorf("canvas %q, cursor at column %d, want \"日x\" and column 3", got, cv.WhereX())
+	}
+}
@@ -0,0 +1,95 @@
+package caca
+
+import (
+	"time"
+
+	"github.com/czwinzscher/libcaca-go/cacafmt"
+)
+
+// MaxFrameDuration is the longest duration accepted by SetFrameDuration(), the
+// longest duration the "caca" format can store.
+const MaxFrameDuration = cacafmt.MaxDuration
+
+// frameDurations returns the display durations of the canvas' frames, indexed
+// like the frames. libcaca keeps no per-frame metadata besides the frame
+// name, so the durations are kept with the canvas handle and are adjusted by
+// the frame functions of Canvas. The slice is resized to the frame count in
+// case libcaca changed the frames behind the package's back.
+func (cv Canvas) frameDurations() []time.Duration {
+	count := cv.GetFrameCount()
+	d := cv.h.durations
+
+	switch {
+	case len(d) > count:
+		d = d[:count]
+	case len(d) < count:
+		d = append(d, make([]time.Duration, count-len(d))...)
+	}
+
+	cv.h.durations = d
+
+	return d
+}
+
+// GetFrameDuration returns the display duration of the current frame, or zero
+// if none was set.
+func (cv Canvas) GetFrameDuration() time.Duration {
+	return cv.frameDurations()[currentFrame(cv)]
+}
+
+// SetFrameDuration sets the display duration of the current frame. A duration
+// of zero removes it. New frames have no duration.
+//
+// Durations are kept by the "caca" format: ExportToMemory() and
+// ExportAreaToMemory() store them in the exported data and ImportFromMemory()
+// and ImportFromFile() restore them. Other formats do not store durations.
+//
+// If an error occurs the according errno is returned.
+func (cv Canvas) SetFrameDuration(d time.Duration) error {
+	if d < 0 || d > MaxFrameDuration {
+		return &Error{Op: "Canvas.SetFrameDuration", Err: ErrInvalidArgument}
+	}
+
+	cv.frameDurations()[currentFrame(cv)] = d
+
+	return nil
+}
+
+// FrameDurations returns the display durations of all frames of the canvas.
+// Frames without a duration have a duration of zero.
+func (cv Canvas) FrameDurations() []time.Duration {
+	return append([]time.Duration(nil), cv.frameDurations()...)
+}
+
+// importDurations restores the frame durations stored in imported "caca"
+// data. Data in other formats leaves the durations unchanged.
+func (cv Canvas) importDurations(data []byte, format Format) {
+	if format != FormatCaca && format != FormatAuto {
+		return
+	}
+
+	if d, err := cacafmt.Durations(data); err == nil && len(d) == cv.GetFrameCount() {
+		cv.h.durations = d
+	}
+}
+
+// exportDurations stores the frame durations in exported "caca" data, which
+// holds either all frames of the canvas or, for an exported area, the
+// current frame.
+func (cv Canvas) exportDurations(data []byte) error {
+	stored, err := cacafmt.Durations(data)
+	if err != nil {
+		return err
+	}
+
+	durations := cv.frameDurations()
+
+	switch len(stored) {
+	case len(durations):
+		return cacafmt.SetDurations(data, durations)
+	case 1:
+		return cacafmt.SetDurations(data, durations[currentFrame(cv):currentFrame(cv)+1])
+	}
+
+	return nil
+}
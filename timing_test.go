@@ -0,0 +1,144 @@
+package caca
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrameDurationCacaRoundTrip(t *testing.T) {
+	cv, err := CreateCanvas(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	want := []time.Duration{150 * time.Millisecond, 0, 2 * time.Second}
+
+	for i, d := range want {
+		if i > 0 {
+			if err := cv.CreateFrame(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := cv.SetFrame(i); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cv.SetFrameDuration(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := cv.ExportToMemory(FormatCaca)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []Format{FormatCaca, FormatAuto} {
+		imported, err := CreateCanvas(1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := imported.ImportFromMemory(data, format); err != nil {
+			t.Fatal(err)
+		}
+
+		got := imported.FrameDurations()
+		imported.Free()
+
+		if len(got) != len(want) {
+			t.Fatalf("%q: %d frames imported, want %d", format, len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%q: frame %d has duration %v, want %v", format, i, got[i], want[i])
+			}
+		}
+	}
+
+	if err := cv.SetFrame(2); err != nil {
+		t.Fatal(err)
+	}
+
+	area, err := cv.ExportAreaToMemory(0, 0, 2, 2, FormatCaca)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := CreateCanvas(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Free()
+
+	if _, err := imported.ImportFromMemory(area, FormatCaca); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := imported.GetFrameDuration(); got != want[2] {
+		t.Errorf("exported area has duration %v, want %v", got, want[2])
+	}
+}
+
+func TestFrameDurationFollowsFrames(t *testing.T) {
+	cv, err := CreateCanvas(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	if err := cv.SetFrameDuration(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cv.CreateFrame(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cv.FrameDurations(); len(got) != 2 || got[0] != 0 || got[1] != time.Second {
+		t.Fatalf("durations %v after inserting a frame, want [0s 1s]", got)
+	}
+
+	if got := cv.GetFrameDuration(); got != time.Second {
+		t.Errorf("current frame has duration %v, want 1s", got)
+	}
+
+	if err := cv.FreeFrame(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cv.FrameDurations(); len(got) != 1 || got[0] != time.Second {
+		t.Errorf("durations %v after freeing a frame, want [1s]", got)
+	}
+
+	if err := cv.SetFrameDuration(-time.Second); err == nil {
+		t.Error("negative duration accepted")
+	}
+}
+
+func TestFrameDurationKeepsFrameName(t *testing.T) {
+	cv, err := CreateCanvas(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.Free()
+
+	if err := cv.SetFrameName("build@1s"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cv.GetFrameDuration(); got != 0 {
+		t.Errorf("frame name set a duration of %v", got)
+	}
+
+	if err := cv.SetFrameDuration(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cv.GetFrameName(); got != "build@1s" {
+		t.Errorf("frame name changed to %q", got)
+	}
+}
@@ -1,5 +1,18 @@
 package caca
 
+// IntPair is a pair of coordinates used by the old polyline functions.
+//
+// Deprecated: use Point instead.
 type IntPair struct {
 	First, Second int
 }
+
+// intPairsToPoints converts a slice of IntPair values to points.
+func intPairsToPoints(xy []IntPair) []Point {
+	pts := make([]Point, 0, len(xy))
+	for _, e := range xy {
+		pts = append(pts, Point{X: e.First, Y: e.Second})
+	}
+
+	return pts
+}
@@ -0,0 +1,504 @@
+package caca
+
+import (
+	"strconv"
+	"strings"
+)
+
+// vtState is the state of the escape sequence parser of vtScreen.
+type vtState int
+
+const (
+	vtGround vtState = iota
+	vtEscape
+	vtCSI
+	vtOSC
+	vtOSCEscape
+	vtCharset
+)
+
+// vtTabWidth is the distance between tab stops.
+const vtTabWidth = 8
+
+// vtColor is a colour set by an SGR sequence. Indices 0 to 15 are ANSI
+// colours in libcaca's order, see ansiOrder; rgb colours are 16-bit ARGB.
+type vtColor struct {
+	def   bool
+	index uint8
+	rgb   bool
+	argb  uint16
+}
+
+// vtScreen is a small VT100 terminal emulator used to replay terminal output,
+// such as asciicast recordings, into canvas cells. It understands printable
+// characters, the usual control characters, cursor movement, erase and SGR
+// sequences; everything else is ignored.
+type vtScreen struct {
+	width, height int
+	chars         []rune
+	attrs         []Attr
+
+	x, y         int
+	wrap         bool
+	savedX       int
+	savedY       int
+	fg, bg       vtColor
+	style        uint8
+	reverse      bool
+	state        vtState
+	params       strings.Builder
+	attr         Attr
+	attrUpToDate bool
+}
+
+func newVTScreen(width int, height int) *vtScreen {
+	vt := &vtScreen{
+		width:  width,
+		height: height,
+		chars:  make([]rune, width*height),
+		attrs:  make([]Attr, width*height),
+		fg:     vtColor{def: true},
+		bg:     vtColor{def: true},
+	}
+
+	vt.erase(0, width*height)
+
+	return vt
+}
+
+// resize changes the terminal size. The top-left part of the screen is kept
+// and new cells are blank; the cursor is moved inside the new screen.
+func (vt *vtScreen) resize(width int, height int) {
+	chars, attrs := make([]rune, width*height), make([]Attr, width*height)
+	blank := NewAttrAnsi(ColorDefault, ColorDefault, 0)
+
+	for i := range chars {
+		chars[i], attrs[i] = ' ', blank
+	}
+
+	for y := 0; y < minInt(height, vt.height); y++ {
+		w := minInt(width, vt.width)
+		copy(chars[y*width:y*width+w], vt.chars[y*vt.width:])
+		copy(attrs[y*width:y*width+w], vt.attrs[y*vt.width:])
+
+		// Do not keep the left half of a fullwidth character cut in two.
+		if w < vt.width && w > 0 && vt.chars[y*vt.width+w] == MagicFullwidth {
+			chars[y*width+w-1] = ' '
+		}
+	}
+
+	vt.width, vt.height = width, height
+	vt.chars, vt.attrs = chars, attrs
+	vt.x, vt.y = minInt(vt.x, width-1), minInt(vt.y, height-1)
+	vt.savedX, vt.savedY = minInt(vt.savedX, width-1), minInt(vt.savedY, height-1)
+	vt.wrap = false
+}
+
+// currentAttr returns the attribute for newly written cells.
+func (vt *vtScreen) currentAttr() Attr {
+	if vt.attrUpToDate {
+		return vt.attr
+	}
+
+	fg, bg := vt.fg, vt.bg
+	if vt.reverse {
+		fg, bg = bg, fg
+
+		// Reversing the default colours swaps the terminal's default
+		// foreground and background.
+		if fg.def {
+			fg = vtColor{index: ColorBlack}
+		}
+
+		if bg.def {
+			bg = vtColor{index: ColorLightgray}
+		}
+	}
+
+	a := NewAttrAnsi(ColorDefault, ColorDefault, vt.style)
+
+	switch {
+	case fg.rgb:
+		a = a.WithFgARGB(fg.argb)
+	case !fg.def:
+		a = a.WithFgAnsi(fg.index)
+	}
+
+	switch {
+	case bg.rgb:
+		a = a.WithBgARGB(bg.argb)
+	case !bg.def:
+		a = a.WithBgAnsi(bg.index)
+	}
+
+	vt.attr, vt.attrUpToDate = a, true
+
+	return a
+}
+
+// erase blanks the cells from index i to j (exclusive) with the current
+// background.
+func (vt *vtScreen) erase(i int, j int) {
+	a := vt.currentAttr()
+
+	for ; i < j; i++ {
+		vt.chars[i] = ' '
+		vt.attrs[i] = a
+	}
+}
+
+// write feeds terminal output to the emulator.
+func (vt *vtScreen) write(s string) {
+	for _, r := range s {
+		switch vt.state {
+		case vtGround:
+			vt.ground(r)
+		case vtEscape:
+			vt.escape(r)
+		case vtCSI:
+			if r >= 0x40 && r <= 0x7e {
+				vt.state = vtGround
+				vt.csi(r, vt.params.String())
+			} else {
+				vt.params.WriteRune(r)
+			}
+		case vtOSC:
+			switch r {
+			case 0x07:
+				vt.state = vtGround
+			case 0x1b:
+				vt.state = vtOSCEscape
+			}
+		case vtOSCEscape:
+			vt.state = vtGround
+		case vtCharset:
+			vt.state = vtGround
+		}
+	}
+}
+
+func (vt *vtScreen) ground(r rune) {
+	switch r {
+	case 0x1b:
+		vt.state = vtEscape
+	case '\r':
+		vt.x, vt.wrap = 0, false
+	case '\n', 0x0b, 0x0c:
+		vt.lineFeed()
+	case '\b':
+		if vt.x > 0 {
+			vt.x--
+		}
+
+		vt.wrap = false
+	case '\t':
+		vt.x = minInt((vt.x/vtTabWidth+1)*vtTabWidth, vt.width-1)
+	default:
+		if r >= 0x20 && r != 0x7f {
+			vt.print(r)
+		}
+	}
+}
+
+func (vt *vtScreen) escape(r rune) {
+	vt.state = vtGround
+
+	switch r {
+	case '[':
+		vt.state = vtCSI
+		vt.params.Reset()
+	case ']', 'P', '_', '^':
+		vt.state = vtOSC
+	case '(', ')', '*', '+':
+		vt.state = vtCharset
+	case '7':
+		vt.savedX, vt.savedY = vt.x, vt.y
+	case '8':
+		vt.x, vt.y, vt.wrap = vt.savedX, vt.savedY, false
+	case 'D':
+		vt.lineFeed()
+	case 'E':
+		vt.x = 0
+		vt.lineFeed()
+	case 'M':
+		if vt.y == 0 {
+			vt.scrollDown()
+		} else {
+			vt.y--
+		}
+	case 'c':
+		*vt = *newVTScreen(vt.width, vt.height)
+	}
+}
+
+// print writes a character at the cursor and advances it, wrapping at the
+// end of the line.
+func (vt *vtScreen) print(r rune) {
+	width := 1
+	if UTF32IsFullwidth(uint32(r)) {
+		width = 2
+	}
+
+	if vt.wrap || vt.x+width > vt.width {
+		vt.x = 0
+		vt.lineFeed()
+	}
+
+	if width > vt.width {
+		return
+	}
+
+	i := vt.y*vt.width + vt.x
+	a := vt.currentAttr()
+
+	// Like caca_put_char(), blank the halves of fullwidth characters that
+	// are cut in two: the left half when writing onto its right half, and
+	// the right half when writing onto its left half.
+	if vt.x > 0 && vt.chars[i] == MagicFullwidth {
+		vt.chars[i-1] = ' '
+	}
+
+	if vt.x+width < vt.width && vt.chars[i+width] == MagicFullwidth {
+		vt.chars[i+width] = ' '
+	}
+
+	vt.chars[i], vt.attrs[i] = r, a
+	if width == 2 {
+		vt.chars[i+1], vt.attrs[i+1] = MagicFullwidth, a
+	}
+
+	vt.x += width
+	if vt.x == vt.width {
+		vt.x, vt.wrap = vt.width-1, true
+	}
+}
+
+func (vt *vtScreen) lineFeed() {
+	vt.wrap = false
+
+	if vt.y == vt.height-1 {
+		vt.scrollUp()
+	} else {
+		vt.y++
+	}
+}
+
+func (vt *vtScreen) scrollUp() {
+	copy(vt.chars, vt.chars[vt.width:])
+	copy(vt.attrs, vt.attrs[vt.width:])
+	vt.erase((vt.height-1)*vt.width, vt.height*vt.width)
+}
+
+func (vt *vtScreen) scrollDown() {
+	copy(vt.chars[vt.width:], vt.chars)
+	copy(vt.attrs[vt.width:], vt.attrs)
+	vt.erase(0, vt.width)
+}
+
+// csi executes a control sequence with the given final character.
+func (vt *vtScreen) csi(final rune, params string) {
+	// Private sequences such as mode switches are ignored.
+	if strings.HasPrefix(params, "?") || strings.HasPrefix(params, ">") {
+		return
+	}
+
+	args := vtParams(params)
+	arg := func(i int, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+
+		return def
+	}
+
+	vt.wrap = false
+
+	switch final {
+	case 'A':
+		vt.y = maxInt(vt.y-arg(0, 1), 0)
+	case 'B', 'e':
+		vt.y = minInt(vt.y+arg(0, 1), vt.height-1)
+	case 'C', 'a':
+		vt.x = minInt(vt.x+arg(0, 1), vt.width-1)
+	case 'D':
+		vt.x = maxInt(vt.x-arg(0, 1), 0)
+	case 'E':
+		vt.x, vt.y = 0, minInt(vt.y+arg(0, 1), vt.height-1)
+	case 'F':
+		vt.x, vt.y = 0, maxInt(vt.y-arg(0, 1), 0)
+	case 'G', '`':
+		vt.x = clampInt(arg(0, 1)-1, 0, vt.width-1)
+	case 'd':
+		vt.y = clampInt(arg(0, 1)-1, 0, vt.height-1)
+	case 'H', 'f':
+		vt.y = clampInt(arg(0, 1)-1, 0, vt.height-1)
+		vt.x = clampInt(arg(1, 1)-1, 0, vt.width-1)
+	case 'J':
+		vt.eraseDisplay(arg(0, 0))
+	case 'K':
+		vt.eraseLine(arg(0, 0))
+	case 'X':
+		i := vt.y*vt.width + vt.x
+		vt.erase(i, i+minInt(arg(0, 1), vt.width-vt.x))
+	case 'm':
+		vt.sgr(args)
+	case 's':
+		vt.savedX, vt.savedY = vt.x, vt.y
+	case 'u':
+		vt.x, vt.y = vt.savedX, vt.savedY
+	}
+}
+
+func (vt *vtScreen) eraseDisplay(mode int) {
+	i := vt.y*vt.width + vt.x
+
+	switch mode {
+	case 0:
+		vt.erase(i, len(vt.chars))
+	case 1:
+		vt.erase(0, i+1)
+	case 2, 3:
+		vt.erase(0, len(vt.chars))
+	}
+}
+
+func (vt *vtScreen) eraseLine(mode int) {
+	row := vt.y * vt.width
+
+	switch mode {
+	case 0:
+		vt.erase(row+vt.x, row+vt.width)
+	case 1:
+		vt.erase(row, row+vt.x+1)
+	case 2:
+		vt.erase(row, row+vt.width)
+	}
+}
+
+// sgr applies a Select Graphic Rendition sequence.
+func (vt *vtScreen) sgr(args []int) {
+	vt.attrUpToDate = false
+
+	if len(args) == 0 {
+		args = []int{0}
+	}
+
+	for i := 0; i < len(args); i++ {
+		switch n := args[i]; {
+		case n == 0:
+			vt.fg, vt.bg = vtColor{def: true}, vtColor{def: true}
+			vt.style, vt.reverse = 0, false
+		case n == 1:
+			vt.style |= StyleBold
+		case n == 3:
+			vt.style |= StyleItalics
+		case n == 4:
+			vt.style |= StyleUnderline
+		case n == 5:
+			vt.style |= StyleBlink
+		case n == 7:
+			vt.reverse = true
+		case n == 22:
+			vt.style &^= StyleBold
+		case n == 23:
+			vt.style &^= StyleItalics
+		case n == 24:
+			vt.style &^= StyleUnderline
+		case n == 25:
+			vt.style &^= StyleBlink
+		case n == 27:
+			vt.reverse = false
+		case n >= 30 && n <= 37:
+			vt.fg = vtColor{index: uint8(ansiOrder[n-30])}
+		case n >= 90 && n <= 97:
+			vt.fg = vtColor{index: uint8(8 + ansiOrder[n-90])}
+		case n == 39:
+			vt.fg = vtColor{def: true}
+		case n >= 40 && n <= 47:
+			vt.bg = vtColor{index: uint8(ansiOrder[n-40])}
+		case n >= 100 && n <= 107:
+			vt.bg = vtColor{index: uint8(8 + ansiOrder[n-100])}
+		case n == 49:
+			vt.bg = vtColor{def: true}
+		case n == 38 || n == 48:
+			c, used := vtExtendedColor(args[i+1:])
+			i += used
+
+			if n == 38 {
+				vt.fg = c
+			} else {
+				vt.bg = c
+			}
+		}
+	}
+}
+
+// vtExtendedColor parses the arguments following SGR 38 or 48, either
+// "5;index" or "2;r;g;b", and returns the colour and the number of arguments
+// used.
+func vtExtendedColor(args []int) (vtColor, int) {
+	if len(args) >= 2 && args[0] == 5 {
+		return vtPaletteColor(args[1]), 2
+	}
+
+	if len(args) >= 4 && args[0] == 2 {
+		r, g, b := clampInt(args[1], 0, 255), clampInt(args[2], 0, 255), clampInt(args[3], 0, 255)
+
+		return vtColor{rgb: true, argb: 0xf000 | uint16(r>>4)<<8 | uint16(g>>4)<<4 | uint16(b>>4)}, 4
+	}
+
+	return vtColor{def: true}, len(args)
+}
+
+// vtPaletteColor converts an index of the xterm 256-colour palette.
+func vtPaletteColor(n int) vtColor {
+	switch {
+	case n < 0 || n > 255:
+		return vtColor{def: true}
+	case n < 8:
+		return vtColor{index: uint8(ansiOrder[n])}
+	case n < 16:
+		return vtColor{index: uint8(8 + ansiOrder[n-8])}
+	case n < 232:
+		n -= 16
+		level := func(v int) uint16 {
+			if v == 0 {
+				return 0
+			}
+
+			return uint16((55 + 40*v) >> 4)
+		}
+
+		return vtColor{rgb: true, argb: 0xf000 | level(n/36)<<8 | level(n/6%6)<<4 | level(n%6)}
+	default:
+		gray := uint16((8 + 10*(n-232)) >> 4)
+
+		return vtColor{rgb: true, argb: 0xf000 | gray<<8 | gray<<4 | gray}
+	}
+}
+
+// vtParams parses the semicolon-separated numeric parameters of a control
+// sequence. Missing parameters are zero.
+func vtParams(s string) []int {
+	if s == "" {
+		return nil
+	}
+
+	fields := strings.Split(s, ";")
+	args := make([]int, len(fields))
+
+	for i, f := range fields {
+		// Colon-separated sub-parameters are reduced to their first value.
+		if j := strings.IndexByte(f, ':'); j != -1 {
+			f = f[:j]
+		}
+
+		args[i], _ = strconv.Atoi(f)
+	}
+
+	return args
+}
+
+func clampInt(v int, lo int, hi int) int {
+	return maxInt(lo, minInt(v, hi))
+}
@@ -0,0 +1,252 @@
+package caca
+
+import (
+	"strings"
+	"testing"
+)
+
+// vtRows returns the characters of the screen, one string per row. The right
+// halves of fullwidth characters are left out.
+func vtRows(vt *vtScreen) []string {
+	rows := make([]string, vt.height)
+
+	for y := range rows {
+		var b strings.Builder
+
+		for _, ch := range vt.chars[y*vt.width : (y+1)*vt.width] {
+			if ch != MagicFullwidth {
+				b.WriteRune(ch)
+			}
+		}
+
+		rows[y] = b.String()
+	}
+
+	return rows
+}
+
+func checkVTRows(t *testing.T, name string, vt *vtScreen, want ...string) {
+	t.Helper()
+
+	got := vtRows(vt)
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("%s: screen %q, want %q", name, got, want)
+	}
+}
+
+func TestVTCursorMovement(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		x, y  int
+	}{
+		{"position", "\x1b[2;3HX", []string{"     ", "  X  ", "     "}, 3, 1},
+		{"position default", "ab\x1b[HX", []string{"Xb   ", "     ", "     "}, 1, 0},
+		{"position clamped", "\x1b[9;9HX", []string{"     ", "     ", "    X"}, 4, 2},
+		{"relative", "\x1b[3;3H\x1b[2AX\x1b[BY\x1b[3DZ\x1b[CW", []string{"  X  ", " Z W ", "     "}, 4, 1},
+		{"relative clamped", "\x1b[9B\x1b[9CX\x1b[9A\x1b[9DY", []string{"Y    ", "     ", "    X"}, 1, 0},
+		{"column and line", "\x1b[4GX\x1b[3dY", []string{"   X ", "     ", "    Y"}, 4, 2},
+		{"next and previous line", "\x1b[2;4H\x1b[EX\x1b[2FY", []string{"Y    ", "     ", "X    "}, 1, 0},
+		{"carriage return and line feed", "ab\r\ncd\bX", []string{"ab   ", "cX   ", "     "}, 2, 1},
+		{"tab", "a\tX", []string{"a   X", "     ", "     "}, 4, 0},
+		{"save and restore", "\x1b[2;2H\x1b7\x1b[HX\x1b8Y\x1b[3;3H\x1b[s\x1b[1;5H\x1b[uZ", []string{"X    ", " Y   ", "  Z  "}, 3, 2},
+		{"reverse index", "\x1b[2;1HX\x1bM\x1bMY", []string{" Y   ", "     ", "X    "}, 2, 0},
+		{"scroll", "a\r\nb\r\nc\r\nd", []string{"b    ", "c    ", "d    "}, 1, 2},
+		{"private sequences ignored", "\x1b[?25lX\x1b[>1mY\x1b]0;title\x07Z", []string{"XYZ  ", "     ", "     "}, 3, 0},
+	}
+
+	for _, tt := range tests {
+		vt := newVTScreen(5, 3)
+		vt.write(tt.input)
+
+		checkVTRows(t, tt.name, vt, tt.want...)
+
+		if vt.x != tt.x || vt.y != tt.y {
+			t.Errorf("%s: cursor at (%d, %d), want (%d, %d)", tt.name, vt.x, vt.y, tt.x, tt.y)
+		}
+	}
+}
+
+func TestVTErase(t *testing.T) {
+	const fill = "abcde\r\nfghij\r\nklmno"
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"line to end", "\x1b[2;3H\x1b[K", []string{"abcde", "fg   ", "klmno"}},
+		{"line to start", "\x1b[2;3H\x1b[1K", []string{"abcde", "   ij", "klmno"}},
+		{"whole line", "\x1b[2;3H\x1b[2K", []string{"abcde", "     ", "klmno"}},
+		{"display to end", "\x1b[2;3H\x1b[J", []string{"abcde", "fg   ", "     "}},
+		{"display to start", "\x1b[2;3H\x1b[1J", []string{"     ", "   ij", "klmno"}},
+		{"whole display", "\x1b[2;3H\x1b[2J", []string{"     ", "     ", "     "}},
+		{"characters", "\x1b[2;2H\x1b[2X", []string{"abcde", "f  ij", "klmno"}},
+		{"characters clamped", "\x1b[2;4H\x1b[9X", []string{"abcde", "fgh  ", "klmno"}},
+		{"reset", "\x1bc", []string{"     ", "     ", "     "}},
+	}
+
+	for _, tt := range tests {
+		vt := newVTScreen(5, 3)
+		vt.write(fill + tt.input)
+
+		checkVTRows(t, tt.name, vt, tt.want...)
+	}
+
+	// Erased cells take the current background.
+	vt := newVTScreen(3, 1)
+	vt.write("\x1b[44m\x1b[2K")
+
+	if want := NewAttrAnsi(ColorDefault, ColorBlue, 0); vt.attrs[1] != want {
+		t.Errorf("erased cell has attr %#x, want %#x", vt.attrs[1], want)
+	}
+}
+
+func TestVTSGR(t *testing.T) {
+	def := NewAttrAnsi(ColorDefault, ColorDefault, 0)
+
+	tests := []struct {
+		name  string
+		input string
+		want  Attr
+	}{
+		{"default", "", def},
+		{"ansi", "\x1b[31;44m", NewAttrAnsi(ColorRed, ColorBlue, 0)},
+		{"bright", "\x1b[93;102m", NewAttrAnsi(ColorYellow, ColorLightgreen, 0)},
+		{"styles", "\x1b[1;3;4;5m", NewAttrAnsi(ColorDefault, ColorDefault, StyleBold|StyleItalics|StyleUnderline|StyleBlink)},
+		{"styles off", "\x1b[1;3;4;5m\x1b[22;23;24;25m", def},
+		{"reset", "\x1b[1;31;44m\x1b[m", def},
+		{"default colours", "\x1b[31;44m\x1b[39;49m", def},
+		{"reverse", "\x1b[31;44;7m", NewAttrAnsi(ColorBlue, ColorRed, 0)},
+		{"reverse default", "\x1b[7m", NewAttrAnsi(ColorBlack, ColorLightgray, 0)},
+		{"reverse off", "\x1b[31;7;27m", NewAttrAnsi(ColorRed, ColorDefault, 0)},
+		{"256 ansi", "\x1b[38;5;1;48;5;12m", NewAttrAnsi(ColorRed, ColorLightblue, 0)},
+		{"256 cube", "\x1b[38;5;196m", def.WithFgARGB(0xff00)},
+		{"256 cube mixed", "\x1b[48;5;67m", def.WithBgARGB(0xf58a)},
+		{"256 grey", "\x1b[38;5;232;48;5;255m", def.WithFgARGB(0xf000).WithBgARGB(0xfeee)},
+		{"256 out of range", "\x1b[31m\x1b[38;5;300m", def},
+		{"truecolor", "\x1b[38;2;255;128;0;48;2;16;32;48m", def.WithFgARGB(0xff80).WithBgARGB(0xf123)},
+		{"truecolor clamped", "\x1b[38;2;999;-1;0m", def.WithFgARGB(0xff00)},
+		{"truecolor colons", "\x1b[38:2:255:0:0m", def},
+		{"extended then style", "\x1b[38;5;196;1m", def.WithFgARGB(0xff00).WithStyle(StyleBold)},
+	}
+
+	for _, tt := range tests {
+		vt := newVTScreen(2, 1)
+		vt.write(tt.input + "X")
+
+		if vt.attrs[0] != tt.want {
+			t.Errorf("%s: attr %#x, want %#x", tt.name, vt.attrs[0], tt.want)
+		}
+
+		if vt.attrs[1] != def {
+			t.Errorf("%s: untouched cell has attr %#x, want %#x", tt.name, vt.attrs[1], def)
+		}
+	}
+}
+
+func TestVTWrap(t *testing.T) {
+	vt := newVTScreen(4, 3)
+
+	// The cursor stays on the last column until the next character.
+	vt.write("abcd")
+	checkVTRows(t, "full line", vt, "abcd", "    ", "    ")
+
+	if vt.x != 3 || vt.y != 0 || !vt.wrap {
+		t.Errorf("cursor at (%d, %d), wrap %v, want (3, 0) pending wrap", vt.x, vt.y, vt.wrap)
+	}
+
+	vt.write("ef")
+	checkVTRows(t, "wrapped", vt, "abcd", "ef  ", "    ")
+
+	// A carriage return cancels the pending wrap.
+	vt = newVTScreen(4, 2)
+	vt.write("abcd\rX")
+	checkVTRows(t, "carriage return", vt, "Xbcd", "    ")
+
+	// So does cursor movement.
+	vt = newVTScreen(4, 2)
+	vt.write("abcd\x1b[DX")
+	checkVTRows(t, "cursor movement", vt, "abXd", "    ")
+
+	// Wrapping on the last line scrolls.
+	vt = newVTScreen(3, 2)
+	vt.write("abcdefgh")
+	checkVTRows(t, "scroll", vt, "def", "gh ")
+}
+
+func TestVTFullwidth(t *testing.T) {
+	vt := newVTScreen(5, 2)
+	vt.write("a日b")
+
+	want := []rune{'a', '日', MagicFullwidth, 'b', ' '}
+	for i, ch := range want {
+		if vt.chars[i] != ch {
+			t.Errorf("cell %d = %q, want %q", i, vt.chars[i], ch)
+		}
+	}
+
+	if vt.x != 4 {
+		t.Errorf("cursor at column %d, want 4", vt.x)
+	}
+
+	// A fullwidth character that does not fit wraps as a whole.
+	vt = newVTScreen(5, 2)
+	vt.write("abcd日")
+	checkVTRows(t, "wrap", vt, "abcd ", "日   ")
+
+	// It is dropped on a screen too narrow for it.
+	vt = newVTScreen(1, 2)
+	vt.write("日")
+	checkVTRows(t, "too narrow", vt, " ", " ")
+
+	// Overwriting half of a fullwidth character blanks the other half.
+	tests := []struct {
+		name  string
+		input string
+		want  []rune
+	}{
+		{"right half", "a日b[1;3HX", []rune{'a', ' ', 'X', 'b', ' '}},
+		{"left half", "a日b[1;2HX", []rune{'a', 'X', ' ', 'b', ' '}},
+		{"two halves", "日本[1;2H字", []rune{' ', '字', MagicFullwidth, ' ', ' '}},
+		{"same position", "日本[1;3H字", []rune{'日', MagicFullwidth, '字', MagicFullwidth, ' '}},
+	}
+
+	for _, tt := range tests {
+		vt := newVTScreen(5, 1)
+		vt.write(tt.input)
+
+		for i, ch := range tt.want {
+			if vt.chars[i] != ch {
+				t.Errorf("%s: cell %d = %q, want %q", tt.name, i, vt.chars[i], ch)
+			}
+		}
+	}
+}
+
+func TestVTResize(t *testing.T) {
+	vt := newVTScreen(4, 2)
+	vt.write("ab日\r\ncdef\x1b[2;4H")
+
+	vt.resize(6, 3)
+	checkVTRows(t, "grow", vt, "ab日  ", "cdef  ", "      ")
+
+	if vt.x != 3 || vt.y != 1 {
+		t.Errorf("grow: cursor at (%d, %d), want (3, 1)", vt.x, vt.y)
+	}
+
+	vt.write("XYZ")
+	checkVTRows(t, "write after grow", vt, "ab日  ", "cdeXYZ", "      ")
+
+	// Shrinking cuts the fullwidth character in two and drops its left half.
+	vt.resize(3, 1)
+	checkVTRows(t, "shrink", vt, "ab ")
+
+	if vt.x != 2 || vt.y != 0 {
+		t.Errorf("shrink: cursor at (%d, %d), want (2, 0)", vt.x, vt.y)
+	}
+
+	vt.write("\r\nZ")
+	checkVTRows(t, "write after shrink", vt, "Z  ")
+}